@@ -0,0 +1,127 @@
+// Package backup reads and writes backup archives: ZIP files holding a user's data as JSON and the
+// contents of their attachments.
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"mini-money/internal/models"
+	"mini-money/internal/storage"
+)
+
+// dataFile is the file of an archive holding the data
+const dataFile = "backup.json"
+
+// maxDataSize is the largest data file read, after decompression, so that a small archive can't
+// expand into more than the server can hold
+const maxDataSize = 100 << 20
+
+// ErrInvalidArchive is returned for a file that isn't a backup archive
+var ErrInvalidArchive = errors.New("file is not a backup archive")
+
+// Write writes a backup archive. The attachments are read from their StorageKey using open;
+// those whose content is missing are left out.
+func Write(w io.Writer, b *models.Backup, open func(key string) (io.ReadCloser, error)) error {
+	z := zip.NewWriter(w)
+
+	attachments := b.Attachments[:0]
+	for _, a := range b.Attachments {
+		content, err := open(a.StorageKey)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		a.File = fmt.Sprintf("attachments/%d%s", a.ID, strings.ToLower(path.Ext(a.FileName)))
+		// Attachments are mostly images and PDFs, which don't get smaller
+		part, err := z.CreateHeader(&zip.FileHeader{Name: a.File, Method: zip.Store, Modified: a.CreatedAt})
+		if err == nil {
+			_, err = io.Copy(part, content)
+		}
+		content.Close()
+		if err != nil {
+			return err
+		}
+		attachments = append(attachments, a)
+	}
+	b.Attachments = attachments
+
+	part, err := z.CreateHeader(&zip.FileHeader{Name: dataFile, Method: zip.Deflate, Modified: b.CreatedAt})
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(part)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(b); err != nil {
+		return err
+	}
+	return z.Close()
+}
+
+// Archive is a backup archive that has been read
+type Archive struct {
+	Backup *models.Backup
+	files  map[string]*zip.File
+}
+
+// Read reads a backup archive. The JSON of the data on its own is accepted too, as a backup
+// without attachments.
+func Read(data []byte) (*Archive, error) {
+	archive := &Archive{Backup: &models.Backup{}, files: make(map[string]*zip.File)}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if len(trimmed) > maxDataSize {
+			return nil, fmt.Errorf("%w: %s is larger than %d bytes", ErrInvalidArchive, dataFile, maxDataSize)
+		}
+		if err := json.Unmarshal(trimmed, archive.Backup); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+		return archive, nil
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, ErrInvalidArchive
+	}
+	for _, f := range z.File {
+		archive.files[f.Name] = f
+	}
+	f, ok := archive.files[dataFile]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is missing", ErrInvalidArchive, dataFile)
+	}
+	reader, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer reader.Close()
+	// The size in the archive's directory can't be trusted, so one byte more than allowed is read
+	content, err := io.ReadAll(io.LimitReader(reader, maxDataSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	if len(content) > maxDataSize {
+		return nil, fmt.Errorf("%w: %s is larger than %d bytes", ErrInvalidArchive, dataFile, maxDataSize)
+	}
+	if err := json.Unmarshal(content, archive.Backup); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	return archive, nil
+}
+
+// Open opens the content of an attachment; it returns storage.ErrNotFound when the archive doesn't
+// have it
+func (a *Archive) Open(attachment models.BackupAttachment) (io.ReadCloser, error) {
+	f, ok := a.files[attachment.File]
+	if !ok || attachment.File == dataFile {
+		return nil, storage.ErrNotFound
+	}
+	return f.Open()
+}
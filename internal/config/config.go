@@ -2,8 +2,11 @@ package config
 
 // Config holds application configuration
 type Config struct {
-	Server   ServerConfig   `json:"server"`
-	Database DatabaseConfig `json:"database"`
+	Server        ServerConfig       `json:"server"`
+	Database      DatabaseConfig     `json:"database"`
+	ExchangeRates ExchangeRateConfig `json:"exchangeRates"`
+	Storage       StorageConfig      `json:"storage"`
+	Trash         TrashConfig        `json:"trash"`
 }
 
 // ServerConfig holds server-related configuration
@@ -17,6 +20,30 @@ type DatabaseConfig struct {
 	Path string `json:"path"`
 }
 
+// ExchangeRateConfig holds exchange rate feed configuration
+type ExchangeRateConfig struct {
+	// FeedPath is a local CSV file (date,from,to,rate) shared by all users.
+	// It is re-imported whenever it changes; a missing file is ignored.
+	FeedPath string `json:"feedPath"`
+}
+
+// StorageConfig holds attachment storage configuration
+type StorageConfig struct {
+	// Path is the directory where attachment files are stored
+	Path string `json:"path"`
+	// MaxAttachmentSize is the largest accepted upload in bytes
+	MaxAttachmentSize int64 `json:"maxAttachmentSize"`
+	// AllowedTypes lists the accepted MIME types, detected from the file content
+	AllowedTypes []string `json:"allowedTypes"`
+}
+
+// TrashConfig holds configuration for deleted data
+type TrashConfig struct {
+	// RetentionDays is how long deleted items stay in the trash before they are
+	// purged for good; 0 keeps them until the user empties the trash
+	RetentionDays int `json:"retentionDays"`
+}
+
 // GetDefaultConfig returns default configuration
 func GetDefaultConfig() *Config {
 	return &Config{
@@ -27,5 +54,22 @@ func GetDefaultConfig() *Config {
 		Database: DatabaseConfig{
 			Path: "./data/finance.db",
 		},
+		ExchangeRates: ExchangeRateConfig{
+			FeedPath: "./data/exchange_rates.csv",
+		},
+		Storage: StorageConfig{
+			Path:              "./data/attachments",
+			MaxAttachmentSize: 10 << 20, // 10 MB
+			AllowedTypes: []string{
+				"image/jpeg",
+				"image/png",
+				"image/gif",
+				"image/webp",
+				"application/pdf",
+			},
+		},
+		Trash: TrashConfig{
+			RetentionDays: 30,
+		},
 	}
 }
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"mini-money/internal/models"
+)
+
+// isLiabilityAsset reports whether an asset belongs to a liability category.
+// Assets without a category are treated as regular assets.
+func isLiabilityAsset(assetID int64) (bool, error) {
+	var categoryType string
+	err := db.QueryRow(`
+		SELECT COALESCE(ac.type, 'asset')
+		FROM assets a
+		LEFT JOIN asset_categories ac ON a.category_id = ac.id
+		WHERE a.id = ?
+	`, assetID).Scan(&categoryType)
+	if err != nil {
+		return false, err
+	}
+	return categoryType == "liability", nil
+}
+
+// IsLiabilityAsset reports whether an asset belongs to a liability category
+func IsLiabilityAsset(assetID int64) (bool, error) {
+	return isLiabilityAsset(assetID)
+}
+
+// assetChange returns how a transaction moves the balance of the asset it is linked to.
+// Income and incoming transfers increase an asset, expenses and outgoing transfers
+// decrease it; a liability moves the other way.
+func assetChange(transactionType string, amount models.Money, incoming, liability bool) models.Money {
+	change := amount
+	if transactionType == "expense" || (transactionType == "transfer" && !incoming) {
+		change = -amount
+	}
+	if liability {
+		change = -change
+	}
+	return change
+}
+
+// GetAssetLedger retrieves the movements of an asset since its latest record with running balances
+func GetAssetLedger(assetID, userID int64) (*models.AssetLedger, error) {
+	asset, err := GetAssetByID(assetID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := GetAssetRecordsByAssetID(assetID)
+	if err != nil {
+		return nil, err
+	}
+
+	var anchor *models.AssetRecord
+	if len(records) > 0 {
+		anchor = &records[0]
+	}
+	return buildAssetLedger(*asset, anchor)
+}
+
+// buildAssetLedger applies the transactions linked to an asset after the anchor record.
+// A record is the balance at the end of its day, so transactions on that day are already included.
+func buildAssetLedger(asset models.Asset, anchor *models.AssetRecord) (*models.AssetLedger, error) {
+	liability, err := isLiabilityAsset(asset.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger := &models.AssetLedger{
+		Asset:   asset,
+		Anchor:  anchor,
+		Entries: []models.AssetLedgerEntry{},
+	}
+
+	anchorDate := ""
+	if anchor != nil {
+		anchorDate = anchor.Date
+		ledger.OpeningBalance = anchor.Amount
+	}
+
+	rows, err := db.Query(`
+		SELECT id, description, amount, type, category_key, date, asset_id, to_asset_id
+		FROM transactions
+		WHERE user_id = ? AND (asset_id = ? OR to_asset_id = ?) AND deleted_at IS NULL AND local_date > ?
+		ORDER BY date ASC, id ASC
+	`, asset.UserID, asset.ID, asset.ID, anchorDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balance := ledger.OpeningBalance
+	for rows.Next() {
+		var entry models.AssetLedgerEntry
+		var amount models.Money
+		var fromID, toID *int64
+		if err := rows.Scan(&entry.TransactionID, &entry.Description, &amount, &entry.Type, &entry.CategoryKey, &entry.Date, &fromID, &toID); err != nil {
+			return nil, err
+		}
+
+		incoming := toID != nil && *toID == asset.ID
+		if entry.Type == "transfer" {
+			if incoming {
+				entry.TransferAssetID = fromID
+			} else {
+				entry.TransferAssetID = toID
+			}
+		}
+		entry.Change = assetChange(entry.Type, amount, incoming, liability)
+		balance += entry.Change
+		entry.Balance = balance
+		ledger.Entries = append(ledger.Entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ledger.Balance = balance
+	return ledger, nil
+}
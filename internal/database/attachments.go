@@ -0,0 +1,72 @@
+package database
+
+import (
+	"time"
+
+	"mini-money/internal/models"
+)
+
+// CreateAttachment records an attachment whose content has already been stored
+func CreateAttachment(a *models.Attachment) error {
+	now := time.Now()
+	res, err := db.Exec(`
+		INSERT INTO transaction_attachments (transaction_id, user_id, file_name, content_type, size, storage_key, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, a.TransactionID, a.UserID, a.FileName, a.ContentType, a.Size, a.StorageKey, now)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	a.ID = id
+	a.CreatedAt = now
+	return nil
+}
+
+// GetAttachments retrieves the attachments of a transaction owned by the user
+func GetAttachments(transactionID, userID int64) ([]models.Attachment, error) {
+	rows, err := db.Query(`
+		SELECT id, transaction_id, user_id, file_name, content_type, size, storage_key, created_at
+		FROM transaction_attachments
+		WHERE transaction_id = ? AND user_id = ?
+		ORDER BY id
+	`, transactionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		var a models.Attachment
+		if err := rows.Scan(&a.ID, &a.TransactionID, &a.UserID, &a.FileName, &a.ContentType, &a.Size, &a.StorageKey, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+// GetAttachment retrieves a single attachment of a transaction owned by the user
+func GetAttachment(id, transactionID, userID int64) (*models.Attachment, error) {
+	var a models.Attachment
+	err := db.QueryRow(`
+		SELECT id, transaction_id, user_id, file_name, content_type, size, storage_key, created_at
+		FROM transaction_attachments
+		WHERE id = ? AND transaction_id = ? AND user_id = ?
+	`, id, transactionID, userID).
+		Scan(&a.ID, &a.TransactionID, &a.UserID, &a.FileName, &a.ContentType, &a.Size, &a.StorageKey, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// DeleteAttachment deletes the record of an attachment; the caller removes its content
+func DeleteAttachment(id, userID int64) error {
+	_, err := db.Exec("DELETE FROM transaction_attachments WHERE id = ? AND user_id = ?", id, userID)
+	return err
+}
@@ -0,0 +1,798 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"mini-money/internal/models"
+)
+
+// ErrUnsupportedBackup is returned for a backup written by a newer version of the app
+var ErrUnsupportedBackup = errors.New("backup version is not supported")
+
+// ErrInvalidBackup is returned for a backup with data that can't be restored
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// Ways of resolving an income or expense category of a backup whose key the account already uses
+// for a category with another name or icon
+const (
+	CategoryConflictMerge     = "merge"     // keep the account's category and file the backup's entries under it
+	CategoryConflictOverwrite = "overwrite" // give the account's category the backup's name and icon
+	CategoryConflictRename    = "rename"    // restore the backup's category under a new key
+)
+
+// ExportBackup collects everything a user has recorded. Items in the trash are left out.
+func ExportBackup(userID int64) (*models.Backup, error) {
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	b := &models.Backup{
+		Version:   models.BackupVersion,
+		CreatedAt: time.Now().UTC(),
+		Profile: models.BackupProfile{
+			Username:     user.Username,
+			Email:        user.Email,
+			Avatar:       user.Avatar,
+			BaseCurrency: user.BaseCurrency,
+			Timezone:     user.Timezone,
+		},
+		TransactionCategories: []models.BackupCategory{},
+		AssetRecords:          []models.AssetRecord{},
+		Attachments:           []models.BackupAttachment{},
+		ExchangeRates:         []models.ExchangeRate{},
+	}
+
+	categories, err := GetTransactionCategories(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, kind := range []string{"expense", "income"} {
+		for _, c := range categories[kind] {
+			b.TransactionCategories = append(b.TransactionCategories, models.BackupCategory{Key: c.Key, Name: c.Name, Icon: c.Icon, Type: kind})
+		}
+	}
+
+	if b.AssetCategories, err = GetAssetCategories(userID); err != nil {
+		return nil, err
+	}
+	if b.Assets, err = GetAssetsByUserID(userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT r.id, r.asset_id, r.date, r.amount, r.created_at, r.updated_at
+		FROM asset_records r
+		JOIN assets a ON a.id = r.asset_id
+		WHERE a.user_id = ? AND a.deleted_at IS NULL AND r.deleted_at IS NULL
+		ORDER BY r.asset_id, r.date
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r models.AssetRecord
+		if err := rows.Scan(&r.ID, &r.AssetID, &r.Date, &r.Amount, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		b.AssetRecords = append(b.AssetRecords, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if b.Tags, err = GetTags(userID); err != nil {
+		return nil, err
+	}
+	if b.Payees, err = GetPayees(userID); err != nil {
+		return nil, err
+	}
+	if b.Transactions, err = GetAllTransactions(userID); err != nil {
+		return nil, err
+	}
+	// A category may have been deleted while transactions are still filed under it; it is saved by
+	// its key so that the backup can be restored
+	saved := make(map[string]bool)
+	for _, c := range b.TransactionCategories {
+		saved[c.Type+":"+c.Key] = true
+	}
+	for _, t := range b.Transactions {
+		kind := categoryType(t, saved)
+		if kind != "income" && kind != "expense" {
+			continue
+		}
+		keys := []string{t.CategoryKey}
+		for _, s := range t.Splits {
+			keys = append(keys, s.CategoryKey)
+		}
+		for _, key := range keys {
+			if key != "" && !saved[kind+":"+key] {
+				saved[kind+":"+key] = true
+				b.TransactionCategories = append(b.TransactionCategories, models.BackupCategory{Key: key, Name: key, Type: kind})
+			}
+		}
+	}
+
+	attachmentRows, err := db.Query(`
+		SELECT a.id, a.transaction_id, a.file_name, a.content_type, a.size, a.storage_key, a.created_at
+		FROM transaction_attachments a
+		JOIN transactions t ON t.id = a.transaction_id
+		WHERE a.user_id = ? AND t.deleted_at IS NULL
+		ORDER BY a.id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer attachmentRows.Close()
+	for attachmentRows.Next() {
+		var a models.BackupAttachment
+		if err := attachmentRows.Scan(&a.ID, &a.TransactionID, &a.FileName, &a.ContentType, &a.Size, &a.StorageKey, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		b.Attachments = append(b.Attachments, a)
+	}
+	if err := attachmentRows.Err(); err != nil {
+		return nil, err
+	}
+
+	if b.Templates, err = GetTemplates(userID); err != nil {
+		return nil, err
+	}
+	if b.AutoTransactions, err = GetAutoTransactions(userID); err != nil {
+		return nil, err
+	}
+
+	// Shared rates come from the rate feed of each server and aren't the user's data
+	rates, err := GetExchangeRates(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rates {
+		if r.UserID == userID {
+			b.ExchangeRates = append(b.ExchangeRates, r)
+		}
+	}
+	return b, nil
+}
+
+// RestoreOptions controls how a backup is restored
+type RestoreOptions struct {
+	CategoryConflict string // one of the CategoryConflict constants; merge when empty
+	// NewUser is created and restored into instead of an existing account
+	NewUser *models.User
+	// StoreAttachment stores the content of an attachment for the account restored into and sets
+	// its StorageKey and Size; attachments without a StorageKey are left out
+	StoreAttachment func(userID int64, a *models.BackupAttachment) error
+}
+
+// restore holds the state of restoring a backup: the account restored into and the IDs the
+// backup's items were given in it
+type restore struct {
+	tx       *sql.Tx
+	userID   int64
+	options  RestoreOptions
+	result   *models.RestoreResult
+	keys     map[string]string // "type:key" of the backup's categories to their key in the account
+	assetIDs map[int64]int64
+	tagIDs   map[int64]int64
+	payeeIDs map[int64]int64
+	txIDs    map[int64]int64 // transactions
+	// categories holds the "type:key" of every category of the account once they are restored,
+	// assetCurrencies the currency of each restored asset by the ID it was given
+	categories      map[string]bool
+	assetCurrencies map[int64]string
+}
+
+// RestoreBackup restores a backup into the account of userID, or into options.NewUser, in a single
+// transaction. Everything gets a new ID. Categories, asset categories, tags and payees the account
+// already has are reused; everything else is added, so restoring the same backup twice doubles the
+// transactions, except those imported from bills, which are only kept once. References to items
+// missing from the backup are dropped.
+func RestoreBackup(userID int64, b *models.Backup, options RestoreOptions) (*models.RestoreResult, error) {
+	if b.Version < 1 || b.Version > models.BackupVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedBackup, b.Version)
+	}
+	if options.CategoryConflict == "" {
+		options.CategoryConflict = CategoryConflictMerge
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if options.NewUser != nil {
+		if err := createUser(tx, options.NewUser); err != nil {
+			return nil, err
+		}
+		userID = options.NewUser.ID
+	}
+
+	r := &restore{
+		tx:      tx,
+		userID:  userID,
+		options: options,
+		result: &models.RestoreResult{
+			Created: make(map[string]int),
+			Merged:  make(map[string]int),
+		},
+		keys:            make(map[string]string),
+		categories:      make(map[string]bool),
+		assetIDs:        make(map[int64]int64),
+		assetCurrencies: make(map[int64]string),
+		tagIDs:          make(map[int64]int64),
+		payeeIDs:        make(map[int64]int64),
+		txIDs:           make(map[int64]int64),
+	}
+	steps := []func(*models.Backup) error{
+		r.transactionCategories,
+		r.assets,
+		r.tags,
+		r.payees,
+		r.transactions,
+		r.attachments,
+		r.templates,
+		r.autoTransactions,
+		r.exchangeRates,
+	}
+	for _, step := range steps {
+		if err := step(b); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.result, nil
+}
+
+// categoryType is the type of the categories a transaction is filed under, given the "type:key" of
+// the categories there are. A refund is an income filed under a category of the expense it pays
+// back, unless it was given an income category.
+func categoryType(t models.Transaction, categories map[string]bool) string {
+	if t.OriginalTransactionID != nil && !categories["income:"+t.CategoryKey] {
+		return "expense"
+	}
+	return t.Type
+}
+
+// categoryKey returns the key a category of the backup has in the account
+func (r *restore) categoryKey(kind, key string) string {
+	if mapped, ok := r.keys[kind+":"+key]; ok {
+		return mapped
+	}
+	return key
+}
+
+// remapID maps an optional reference to the ID its target was given, dropping it if the target is missing
+func remapID(ids map[int64]int64, ref *int64) *int64 {
+	if ref == nil {
+		return nil
+	}
+	if mapped, ok := ids[*ref]; ok {
+		return &mapped
+	}
+	return nil
+}
+
+// transactionCategories restores the income and expense categories. A category is matched by key
+// and type, and otherwise by name and type.
+func (r *restore) transactionCategories(b *models.Backup) error {
+	rows, err := r.tx.Query("SELECT key, name, icon, type FROM transaction_categories WHERE user_id = ?", r.userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	byKey := make(map[string]models.BackupCategory)
+	byName := make(map[string]string)
+	for rows.Next() {
+		var c models.BackupCategory
+		if err := rows.Scan(&c.Key, &c.Name, &c.Icon, &c.Type); err != nil {
+			return err
+		}
+		byKey[c.Type+":"+c.Key] = c
+		byName[c.Type+":"+c.Name] = c.Key
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	insert := func(c models.BackupCategory) error {
+		_, err := r.tx.Exec("INSERT INTO transaction_categories (user_id, key, name, icon, type, is_default) VALUES (?, ?, ?, ?, ?, FALSE)",
+			r.userID, c.Key, c.Name, c.Icon, c.Type)
+		if err != nil {
+			return err
+		}
+		byKey[c.Type+":"+c.Key] = c
+		byName[c.Type+":"+c.Name] = c.Key
+		r.result.Created["transactionCategories"]++
+		return nil
+	}
+
+	for _, c := range b.TransactionCategories {
+		if c.Type != "income" && c.Type != "expense" || c.Key == "" {
+			return fmt.Errorf("%w: category %q of type %q", ErrInvalidBackup, c.Key, c.Type)
+		}
+		existing, ok := byKey[c.Type+":"+c.Key]
+		switch {
+		case !ok:
+			if key, ok := byName[c.Type+":"+c.Name]; ok {
+				r.keys[c.Type+":"+c.Key] = key
+				r.result.Merged["transactionCategories"]++
+				continue
+			}
+			if err := insert(c); err != nil {
+				return err
+			}
+		case existing.Name == c.Name && existing.Icon == c.Icon, r.options.CategoryConflict == CategoryConflictMerge:
+			r.result.Merged["transactionCategories"]++
+		case r.options.CategoryConflict == CategoryConflictOverwrite:
+			_, err := r.tx.Exec("UPDATE transaction_categories SET name = ?, icon = ?, updated_at = ? WHERE user_id = ? AND key = ? AND type = ?",
+				c.Name, c.Icon, time.Now(), r.userID, c.Key, c.Type)
+			if err != nil {
+				return err
+			}
+			r.result.Merged["transactionCategories"]++
+		default:
+			// A category of the same name may already have been restored under a new key
+			if key, ok := byName[c.Type+":"+c.Name]; ok {
+				r.keys[c.Type+":"+c.Key] = key
+				r.result.Merged["transactionCategories"]++
+				continue
+			}
+			renamed := c
+			for n := 2; ; n++ {
+				renamed.Key = fmt.Sprintf("%s_%d", c.Key, n)
+				if _, taken := byKey[c.Type+":"+renamed.Key]; !taken {
+					break
+				}
+			}
+			if err := insert(renamed); err != nil {
+				return err
+			}
+			r.keys[c.Type+":"+c.Key] = renamed.Key
+			if r.result.RenamedCategories == nil {
+				r.result.RenamedCategories = make(map[string]string)
+			}
+			r.result.RenamedCategories[c.Type+":"+c.Key] = renamed.Key
+		}
+	}
+	for key := range byKey {
+		r.categories[key] = true
+	}
+	return nil
+}
+
+// assets restores the asset categories, matched by name and type, then the assets and their records
+func (r *restore) assets(b *models.Backup) error {
+	categoryIDs := make(map[int64]int64)
+	categoryNames := make(map[int64]string)
+	for _, c := range b.AssetCategories {
+		if c.Type != "asset" && c.Type != "liability" {
+			return fmt.Errorf("%w: asset category type %q", ErrInvalidBackup, c.Type)
+		}
+		var existingID int64
+		var icon string
+		err := r.tx.QueryRow("SELECT id, icon FROM asset_categories WHERE user_id = ? AND name = ? AND type = ?", r.userID, c.Name, c.Type).
+			Scan(&existingID, &icon)
+		switch {
+		case err == sql.ErrNoRows:
+			res, err := r.tx.Exec("INSERT INTO asset_categories (user_id, name, icon, type) VALUES (?, ?, ?, ?)", r.userID, c.Name, c.Icon, c.Type)
+			if err != nil {
+				return err
+			}
+			if existingID, err = res.LastInsertId(); err != nil {
+				return err
+			}
+			r.result.Created["assetCategories"]++
+		case err != nil:
+			return err
+		default:
+			if icon != c.Icon && r.options.CategoryConflict == CategoryConflictOverwrite {
+				if _, err := r.tx.Exec("UPDATE asset_categories SET icon = ?, updated_at = ? WHERE id = ?", c.Icon, time.Now(), existingID); err != nil {
+					return err
+				}
+			}
+			r.result.Merged["assetCategories"]++
+		}
+		categoryIDs[c.ID] = existingID
+		categoryNames[c.ID] = c.Name
+	}
+
+	for _, a := range b.Assets {
+		var categoryID *int64
+		category := a.Category
+		if a.CategoryID != nil {
+			categoryID = remapID(categoryIDs, a.CategoryID)
+			if name, ok := categoryNames[*a.CategoryID]; ok {
+				category = name
+			}
+		}
+		currency, err := models.NormalizeCurrency(a.Currency)
+		if err != nil {
+			return fmt.Errorf("%w: asset %q: %v", ErrInvalidBackup, a.Name, err)
+		}
+		res, err := r.tx.Exec("INSERT INTO assets (user_id, name, category, category_id, currency, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			r.userID, a.Name, category, categoryID, currency, a.CreatedAt, a.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		if r.assetIDs[a.ID], err = res.LastInsertId(); err != nil {
+			return err
+		}
+		r.assetCurrencies[r.assetIDs[a.ID]] = currency
+		r.result.Created["assets"]++
+	}
+
+	for _, record := range b.AssetRecords {
+		assetID, ok := r.assetIDs[record.AssetID]
+		if !ok {
+			continue
+		}
+		record.AssetID = assetID
+		if err := createAssetRecord(r.tx, &record); err != nil {
+			return err
+		}
+		r.result.Created["assetRecords"]++
+	}
+	return nil
+}
+
+// tags restores the tags, reusing those of the same name
+func (r *restore) tags(b *models.Backup) error {
+	for _, t := range b.Tags {
+		var existingID int64
+		err := r.tx.QueryRow("SELECT id FROM tags WHERE user_id = ? AND name = ?", r.userID, t.Name).Scan(&existingID)
+		switch {
+		case err == sql.ErrNoRows:
+			res, err := r.tx.Exec("INSERT INTO tags (user_id, name, color, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+				r.userID, t.Name, t.Color, t.CreatedAt, t.UpdatedAt)
+			if err != nil {
+				return err
+			}
+			if existingID, err = res.LastInsertId(); err != nil {
+				return err
+			}
+			r.result.Created["tags"]++
+		case err != nil:
+			return err
+		default:
+			r.result.Merged["tags"]++
+		}
+		r.tagIDs[t.ID] = existingID
+	}
+	return nil
+}
+
+// payees restores the payees, reusing those of the same name and adding the aliases they lack
+func (r *restore) payees(b *models.Backup) error {
+	for _, p := range b.Payees {
+		var existingID int64
+		err := r.tx.QueryRow("SELECT id FROM payees WHERE user_id = ? AND name = ?", r.userID, p.Name).Scan(&existingID)
+		switch {
+		case err == sql.ErrNoRows:
+			res, err := r.tx.Exec("INSERT INTO payees (user_id, name, created_at, updated_at) VALUES (?, ?, ?, ?)",
+				r.userID, p.Name, p.CreatedAt, p.UpdatedAt)
+			if err != nil {
+				return err
+			}
+			if existingID, err = res.LastInsertId(); err != nil {
+				return err
+			}
+			if err := replacePayeeAliases(r.tx, existingID, p.Aliases); err != nil {
+				return err
+			}
+			r.result.Created["payees"]++
+		case err != nil:
+			return err
+		default:
+			for _, a := range p.Aliases {
+				if a.MatchType == "" {
+					a.MatchType = "contains"
+				}
+				_, err := r.tx.Exec(`
+					INSERT INTO payee_aliases (payee_id, pattern, match_type)
+					SELECT ?, ?, ?
+					WHERE NOT EXISTS (SELECT 1 FROM payee_aliases WHERE payee_id = ? AND pattern = ? AND match_type = ?)
+				`, existingID, a.Pattern, a.MatchType, existingID, a.Pattern, a.MatchType)
+				if err != nil {
+					return err
+				}
+			}
+			r.result.Merged["payees"]++
+		}
+		r.payeeIDs[p.ID] = existingID
+	}
+	return nil
+}
+
+// transactions restores the transactions with their splits and tags. Refunds are checked and
+// linked to the expense they pay back once all transactions have their new IDs.
+func (r *restore) transactions(b *models.Backup) error {
+	categories := make(map[string]bool)
+	for _, c := range b.TransactionCategories {
+		categories[c.Type+":"+c.Key] = true
+	}
+	inserted := make(map[int64]models.Transaction)
+	for _, t := range b.Transactions {
+		if t.ExternalID != "" {
+			var exists bool
+			err := r.tx.QueryRow("SELECT EXISTS (SELECT 1 FROM transactions WHERE user_id = ? AND external_source = ? AND external_id = ?)",
+				r.userID, t.ExternalSource, t.ExternalID).Scan(&exists)
+			if err != nil {
+				return err
+			}
+			if exists {
+				r.result.Skipped++
+				continue
+			}
+		}
+
+		restored := t
+		restored.UserID = r.userID
+		kind := categoryType(t, categories)
+		restored.CategoryKey = r.categoryKey(kind, t.CategoryKey)
+		restored.AssetID = remapID(r.assetIDs, t.AssetID)
+		restored.ToAssetID = remapID(r.assetIDs, t.ToAssetID)
+		restored.PayeeID = remapID(r.payeeIDs, t.PayeeID)
+		restored.OriginalTransactionID = nil
+		restored.Splits = make([]models.TransactionSplit, len(t.Splits))
+		for i, s := range t.Splits {
+			restored.Splits[i] = models.TransactionSplit{CategoryKey: r.categoryKey(kind, s.CategoryKey), Amount: s.Amount, Note: s.Note}
+		}
+		restored.Tags = nil
+		for _, tag := range t.Tags {
+			if tagID, ok := r.tagIDs[tag.ID]; ok {
+				restored.Tags = append(restored.Tags, models.Tag{ID: tagID})
+			}
+		}
+
+		if err := r.validateTransaction(kind, t, &restored); err != nil {
+			return fmt.Errorf("%w: transaction %d: %v", ErrInvalidBackup, t.ID, err)
+		}
+
+		newID, err := insertTransaction(r.tx, &restored)
+		if err != nil {
+			return err
+		}
+		r.txIDs[t.ID] = newID
+		inserted[t.ID] = restored
+		r.result.Created["transactions"]++
+	}
+
+	refunded := make(map[int64]models.Money)
+	for _, t := range b.Transactions {
+		refund, ok := inserted[t.ID]
+		if !ok || t.OriginalTransactionID == nil {
+			continue
+		}
+		originalID := *t.OriginalTransactionID
+		original, ok := inserted[originalID]
+		if !ok {
+			continue
+		}
+		if err := validateRefundLink(refund, original, refunded[originalID]); err != nil {
+			return fmt.Errorf("%w: transaction %d: %v", ErrInvalidBackup, t.ID, err)
+		}
+		refunded[originalID] += refund.Amount
+
+		_, err := r.tx.Exec("UPDATE transactions SET original_transaction_id = ? WHERE id = ?", r.txIDs[originalID], r.txIDs[t.ID])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateRefundLink checks a refund of the backup against the expense it pays back, given what
+// earlier refunds of that expense add up to, the way a new refund is checked
+func validateRefundLink(refund, original models.Transaction, refunded models.Money) error {
+	if refund.Type != "income" {
+		return errors.New("refunds and reimbursements must be income")
+	}
+	if len(refund.Splits) > 0 {
+		return errors.New("refunds cannot be split")
+	}
+	if original.Type != "expense" {
+		return errors.New("only expenses can be refunded")
+	}
+	if original.Currency != refund.Currency {
+		return fmt.Errorf("refund currency must match the original transaction (%s)", original.Currency)
+	}
+	if refunded+refund.Amount > original.Amount {
+		return fmt.Errorf("refunds cannot exceed the original amount; %s is left to refund", original.Amount-refunded)
+	}
+	return nil
+}
+
+// validateTransaction checks a transaction of the backup the way a new transaction is checked,
+// and that its categories of type kind and its assets exist in the account. original is the
+// transaction as it is in the backup, restored the one about to be inserted.
+func (r *restore) validateTransaction(kind string, original models.Transaction, restored *models.Transaction) error {
+	if !models.ValidTransactionType(restored.Type) {
+		return fmt.Errorf("unknown type %q", restored.Type)
+	}
+	var keys []string
+	if restored.Type != "transfer" {
+		keys = []string{restored.CategoryKey}
+		for _, s := range restored.Splits {
+			keys = append(keys, s.CategoryKey)
+		}
+	}
+	if err := r.validateEntry(kind, restored.Amount, &restored.Currency, keys, restored.AssetID, restored.ToAssetID); err != nil {
+		return err
+	}
+	if err := models.ValidateSplits(restored.Amount, restored.Splits); err != nil {
+		return err
+	}
+	// The backup's own asset IDs are checked, as references to assets left out of it are dropped
+	return models.ValidateTransfer(&original)
+}
+
+// validatePlanned checks a template or auto transaction of the backup, which is an income or
+// expense with a category
+func (r *restore) validatePlanned(kind string, amount models.Money, currency *string, categoryKey string, assetID *int64) error {
+	if kind != "income" && kind != "expense" {
+		return fmt.Errorf("unknown type %q", kind)
+	}
+	if categoryKey == "" {
+		return errors.New("categoryKey is required")
+	}
+	return r.validateEntry(kind, amount, currency, []string{categoryKey}, assetID)
+}
+
+// validateEntry checks what transactions, templates and auto transactions have in common: a
+// positive amount in a valid currency, which it normalizes, and categories and assets that exist
+// in the account, the assets in that currency. Empty category keys are left alone.
+func (r *restore) validateEntry(kind string, amount models.Money, currency *string, categoryKeys []string, assetIDs ...*int64) error {
+	normalized, err := models.NormalizeCurrency(*currency)
+	if err != nil {
+		return err
+	}
+	*currency = normalized
+	if amount <= 0 {
+		return fmt.Errorf("amount %s is not positive", amount)
+	}
+	for _, key := range categoryKeys {
+		if key != "" && !r.categories[kind+":"+key] {
+			return fmt.Errorf("unknown %s category %q", kind, key)
+		}
+	}
+	for _, assetID := range assetIDs {
+		if assetID != nil && r.assetCurrencies[*assetID] != normalized {
+			return fmt.Errorf("currency %s does not match asset currency %s", normalized, r.assetCurrencies[*assetID])
+		}
+	}
+	return nil
+}
+
+// attachments stores the content of the attachments and restores their records
+func (r *restore) attachments(b *models.Backup) error {
+	for _, a := range b.Attachments {
+		transactionID, ok := r.txIDs[a.TransactionID]
+		if !ok || r.options.StoreAttachment == nil {
+			continue
+		}
+		a.StorageKey = ""
+		if err := r.options.StoreAttachment(r.userID, &a); err != nil {
+			return err
+		}
+		if a.StorageKey == "" {
+			continue
+		}
+		_, err := r.tx.Exec(`
+			INSERT INTO transaction_attachments (transaction_id, user_id, file_name, content_type, size, storage_key, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, transactionID, r.userID, a.FileName, a.ContentType, a.Size, a.StorageKey, a.CreatedAt)
+		if err != nil {
+			return err
+		}
+		r.result.Created["attachments"]++
+	}
+	return nil
+}
+
+// templates restores the transaction templates with their usage
+func (r *restore) templates(b *models.Backup) error {
+	for _, t := range b.Templates {
+		categoryKey, assetID := r.categoryKey(t.Type, t.CategoryKey), remapID(r.assetIDs, t.AssetID)
+		if err := r.validatePlanned(t.Type, t.Amount, &t.Currency, categoryKey, assetID); err != nil {
+			return fmt.Errorf("%w: template %d: %v", ErrInvalidBackup, t.ID, err)
+		}
+		_, err := r.tx.Exec(`
+			INSERT INTO transaction_templates (user_id, description, amount, currency, type, category_key, asset_id, usage_count, last_used_at, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, r.userID, t.Description, t.Amount, t.Currency, t.Type, categoryKey, assetID,
+			t.UsageCount, t.LastUsedAt, t.CreatedAt, t.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		r.result.Created["templates"]++
+	}
+	return nil
+}
+
+// autoTransactions restores the recurring transaction rules as they were, active or paused
+func (r *restore) autoTransactions(b *models.Backup) error {
+	for _, at := range b.AutoTransactions {
+		categoryKey, assetID := r.categoryKey(at.Type, at.CategoryKey), remapID(r.assetIDs, at.AssetID)
+		if err := r.validatePlanned(at.Type, at.Amount, &at.Currency, categoryKey, assetID); err != nil {
+			return fmt.Errorf("%w: auto transaction %d: %v", ErrInvalidBackup, at.ID, err)
+		}
+		switch at.Frequency {
+		case "daily", "weekly", "monthly", "yearly":
+		default:
+			return fmt.Errorf("%w: auto transaction %d: unknown frequency %q", ErrInvalidBackup, at.ID, at.Frequency)
+		}
+		_, err := r.tx.Exec(`
+			INSERT INTO auto_transactions (
+				user_id, type, amount, currency, category_key, description, asset_id, frequency,
+				day_of_month, day_of_week, next_execution_date, last_execution_date, is_active,
+				created_at, updated_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, r.userID, at.Type, at.Amount, at.Currency, categoryKey, at.Description, assetID, at.Frequency,
+			at.DayOfMonth, at.DayOfWeek, at.NextExecutionDate, at.LastExecutionDate, at.IsActive,
+			at.CreatedAt, at.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		r.result.Created["autoTransactions"]++
+	}
+	return nil
+}
+
+// exchangeRates restores the user's own exchange rates; a rate the account already has for the
+// same day is kept
+func (r *restore) exchangeRates(b *models.Backup) error {
+	for _, rate := range b.ExchangeRates {
+		if err := validateRestoredRate(&rate); err != nil {
+			return fmt.Errorf("%w: exchange rate %d: %v", ErrInvalidBackup, rate.ID, err)
+		}
+		res, err := r.tx.Exec(`
+			INSERT OR IGNORE INTO exchange_rates (user_id, from_currency, to_currency, rate, date, source, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, r.userID, rate.FromCurrency, rate.ToCurrency, rate.Rate, rate.Date, rate.Source, rate.CreatedAt, rate.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			r.result.Created["exchangeRates"]++
+		} else {
+			r.result.Merged["exchangeRates"]++
+		}
+	}
+	return nil
+}
+
+// validateRestoredRate checks an exchange rate of the backup the way a new rate is checked and
+// normalizes its currencies
+func validateRestoredRate(rate *models.ExchangeRate) error {
+	from, err := models.NormalizeCurrency(rate.FromCurrency)
+	if err != nil {
+		return err
+	}
+	to, err := models.NormalizeCurrency(rate.ToCurrency)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		return errors.New("fromCurrency and toCurrency must differ")
+	}
+	if rate.Rate <= 0 {
+		return fmt.Errorf("rate %v is not positive", rate.Rate)
+	}
+	if _, err := time.Parse("2006-01-02", rate.Date); err != nil {
+		return errors.New("date must be in YYYY-MM-DD format")
+	}
+	rate.FromCurrency, rate.ToCurrency = from, to
+	return nil
+}
@@ -2,7 +2,13 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"mini-money/internal/config"
@@ -61,7 +67,7 @@ func createTable() error {
 		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
 		user_id INTEGER NOT NULL,
 		description TEXT,
-		amount REAL,
+		amount INTEGER, -- minor units (分)
 		type TEXT,
 		category_key TEXT,
 		date DATETIME,
@@ -74,6 +80,45 @@ func createTable() error {
 		return err
 	}
 
+	// Create transaction_revisions table to keep the history of edited transactions
+	transactionRevisionTableSQL := `
+	CREATE TABLE IF NOT EXISTS transaction_revisions (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		transaction_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		changed_fields TEXT NOT NULL DEFAULT '',
+		old_data TEXT NOT NULL,
+		new_data TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (transaction_id) REFERENCES transactions (id),
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_transaction_revisions_transaction_id ON transaction_revisions (transaction_id);
+	`
+	_, err = db.Exec(transactionRevisionTableSQL)
+	if err != nil {
+		log.Printf("Error creating transaction_revisions table: %v", err)
+		return err
+	}
+
+	// Create transaction_splits table for transactions spread across several categories
+	transactionSplitTableSQL := `
+	CREATE TABLE IF NOT EXISTS transaction_splits (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		transaction_id INTEGER NOT NULL,
+		category_key TEXT NOT NULL,
+		amount INTEGER NOT NULL, -- minor units (分)
+		note TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY (transaction_id) REFERENCES transactions (id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_transaction_splits_transaction_id ON transaction_splits (transaction_id);
+	`
+	_, err = db.Exec(transactionSplitTableSQL)
+	if err != nil {
+		log.Printf("Error creating transaction_splits table: %v", err)
+		return err
+	}
+
 	// Create assets table
 	assetTableSQL := `
 	CREATE TABLE IF NOT EXISTS assets (
@@ -98,11 +143,10 @@ func createTable() error {
 		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
 		asset_id INTEGER NOT NULL,
 		date TEXT NOT NULL,
-		amount REAL NOT NULL,
+		amount INTEGER NOT NULL, -- minor units (分)
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (asset_id) REFERENCES assets (id) ON DELETE CASCADE,
-		UNIQUE(asset_id, date)
+		FOREIGN KEY (asset_id) REFERENCES assets (id) ON DELETE CASCADE
 	);
 	`
 	_, err = db.Exec(assetRecordTableSQL)
@@ -177,7 +221,7 @@ func createTable() error {
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		user_id INTEGER NOT NULL,
 		type TEXT NOT NULL CHECK(type IN ('income', 'expense')),
-		amount REAL NOT NULL,
+		amount INTEGER NOT NULL, -- minor units (分)
 		category_key TEXT NOT NULL,
 		description TEXT,
 		frequency TEXT NOT NULL CHECK(frequency IN ('daily', 'weekly', 'monthly', 'yearly')),
@@ -196,6 +240,253 @@ func createTable() error {
 		return err
 	}
 
+	// Migrate amounts stored as REAL by older versions to integer minor units
+	for _, table := range []string{"transactions", "asset_records", "auto_transactions"} {
+		if err := migrateAmountToMinorUnits(table); err != nil {
+			log.Printf("Error migrating %s amounts to minor units: %v", table, err)
+			return err
+		}
+	}
+
+	// Add currency columns; existing rows are assumed to be in the default currency
+	currencyColumns := []struct {
+		Table  string
+		Column string
+	}{
+		{"users", "base_currency"},
+		{"transactions", "currency"},
+		{"assets", "currency"},
+		{"auto_transactions", "currency"},
+	}
+	for _, col := range currencyColumns {
+		err := addColumnIfMissing(col.Table, col.Column, "TEXT NOT NULL DEFAULT '"+models.DefaultCurrency+"'")
+		if err != nil {
+			log.Printf("Error adding %s column to %s table: %v", col.Column, col.Table, err)
+			return err
+		}
+	}
+
+	// Timezone of each user; existing users keep UTC
+	if err := addColumnIfMissing("users", "timezone", "TEXT NOT NULL DEFAULT '"+models.DefaultTimezone+"'"); err != nil {
+		log.Printf("Error adding timezone column to users table: %v", err)
+		return err
+	}
+
+	// Link transactions and auto transactions to asset accounts
+	for _, table := range []string{"transactions", "auto_transactions"} {
+		if err := addColumnIfMissing(table, "asset_id", "INTEGER REFERENCES assets(id)"); err != nil {
+			log.Printf("Error adding asset_id column to %s table: %v", table, err)
+			return err
+		}
+	}
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_transactions_asset_id ON transactions (asset_id)"); err != nil {
+		log.Printf("Error creating transactions asset_id index: %v", err)
+		return err
+	}
+
+	// Destination asset of transfers
+	if err := addColumnIfMissing("transactions", "to_asset_id", "INTEGER REFERENCES assets(id)"); err != nil {
+		log.Printf("Error adding to_asset_id column to transactions table: %v", err)
+		return err
+	}
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_transactions_to_asset_id ON transactions (to_asset_id)"); err != nil {
+		log.Printf("Error creating transactions to_asset_id index: %v", err)
+		return err
+	}
+
+	// Create exchange_rates table. Rates with user_id 0 are shared and come from the CSV feed.
+	exchangeRateTableSQL := `
+	CREATE TABLE IF NOT EXISTS exchange_rates (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL DEFAULT 0,
+		from_currency TEXT NOT NULL,
+		to_currency TEXT NOT NULL,
+		rate REAL NOT NULL CHECK (rate > 0),
+		date TEXT NOT NULL,
+		source TEXT NOT NULL DEFAULT 'manual',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(user_id, from_currency, to_currency, date)
+	);
+	`
+	if _, err := db.Exec(exchangeRateTableSQL); err != nil {
+		log.Printf("Error creating exchange_rates table: %v", err)
+		return err
+	}
+
+	// Create tags and the link table between tags and transactions
+	tagTablesSQL := `
+	CREATE TABLE IF NOT EXISTS tags (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		color TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users (id),
+		UNIQUE(user_id, name)
+	);
+	CREATE TABLE IF NOT EXISTS transaction_tags (
+		transaction_id INTEGER NOT NULL,
+		tag_id INTEGER NOT NULL,
+		PRIMARY KEY (transaction_id, tag_id),
+		FOREIGN KEY (transaction_id) REFERENCES transactions (id),
+		FOREIGN KEY (tag_id) REFERENCES tags (id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_transaction_tags_tag_id ON transaction_tags (tag_id);
+	`
+	if _, err := db.Exec(tagTablesSQL); err != nil {
+		log.Printf("Error creating tags tables: %v", err)
+		return err
+	}
+
+	// Create transaction_attachments table; the file contents live in the blob store
+	attachmentTableSQL := `
+	CREATE TABLE IF NOT EXISTS transaction_attachments (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		transaction_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		file_name TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		storage_key TEXT NOT NULL UNIQUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (transaction_id) REFERENCES transactions (id),
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_transaction_attachments_transaction_id ON transaction_attachments (transaction_id);
+	`
+	if _, err := db.Exec(attachmentTableSQL); err != nil {
+		log.Printf("Error creating transaction_attachments table: %v", err)
+		return err
+	}
+
+	// Refunds and reimbursements point at the expense they pay back
+	if err := addColumnIfMissing("transactions", "original_transaction_id", "INTEGER REFERENCES transactions(id)"); err != nil {
+		log.Printf("Error adding original_transaction_id column to transactions table: %v", err)
+		return err
+	}
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_transactions_original_transaction_id ON transactions (original_transaction_id)"); err != nil {
+		log.Printf("Error creating transactions original_transaction_id index: %v", err)
+		return err
+	}
+	if err := addColumnIfMissing("transactions", "reimbursable", "BOOLEAN NOT NULL DEFAULT 0"); err != nil {
+		log.Printf("Error adding reimbursable column to transactions table: %v", err)
+		return err
+	}
+
+	// Payees and the alias rules that map descriptions to them
+	payeeTablesSQL := `
+	CREATE TABLE IF NOT EXISTS payees (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users (id),
+		UNIQUE(user_id, name)
+	);
+	CREATE TABLE IF NOT EXISTS payee_aliases (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		payee_id INTEGER NOT NULL,
+		pattern TEXT NOT NULL,
+		match_type TEXT NOT NULL DEFAULT 'contains',
+		FOREIGN KEY (payee_id) REFERENCES payees (id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_payee_aliases_payee_id ON payee_aliases (payee_id);
+	`
+	if _, err := db.Exec(payeeTablesSQL); err != nil {
+		log.Printf("Error creating payees tables: %v", err)
+		return err
+	}
+	if err := addColumnIfMissing("transactions", "payee_id", "INTEGER REFERENCES payees(id)"); err != nil {
+		log.Printf("Error adding payee_id column to transactions table: %v", err)
+		return err
+	}
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_transactions_payee_id ON transactions (payee_id)"); err != nil {
+		log.Printf("Error creating transactions payee_id index: %v", err)
+		return err
+	}
+
+	// Create transaction_templates table for one-tap entry of frequent transactions
+	templateTableSQL := `
+	CREATE TABLE IF NOT EXISTS transaction_templates (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		description TEXT NOT NULL,
+		amount INTEGER NOT NULL, -- minor units (分)
+		currency TEXT NOT NULL,
+		type TEXT NOT NULL CHECK(type IN ('income', 'expense')),
+		category_key TEXT NOT NULL,
+		asset_id INTEGER REFERENCES assets(id),
+		usage_count INTEGER NOT NULL DEFAULT 0,
+		last_used_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_transaction_templates_user_id ON transaction_templates (user_id);
+	`
+	if _, err := db.Exec(templateTableSQL); err != nil {
+		log.Printf("Error creating transaction_templates table: %v", err)
+		return err
+	}
+
+	// Soft delete: trashed rows keep their data until they are purged
+	for _, table := range []string{"transactions", "assets", "asset_records", "auto_transactions"} {
+		if err := addColumnIfMissing(table, "deleted_at", "DATETIME"); err != nil {
+			log.Printf("Error adding deleted_at column to %s table: %v", table, err)
+			return err
+		}
+	}
+
+	// An asset has one record per day, not counting those in the trash
+	if err := migrateAssetRecordsUniqueDate(); err != nil {
+		log.Printf("Error migrating asset_records unique date constraint: %v", err)
+		return err
+	}
+	if _, err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_asset_records_asset_date ON asset_records (asset_id, date) WHERE deleted_at IS NULL"); err != nil {
+		log.Printf("Error creating asset_records date index: %v", err)
+		return err
+	}
+
+	// Transaction dates are stored in transactionDateLayout together with their local calendar day
+	if err := addColumnIfMissing("transactions", "local_date", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		log.Printf("Error adding local_date column to transactions table: %v", err)
+		return err
+	}
+	if err := migrateTransactionDates(); err != nil {
+		log.Printf("Error migrating transaction dates: %v", err)
+		return err
+	}
+	transactionDateIndexesSQL := `
+	CREATE INDEX IF NOT EXISTS idx_transactions_user_date ON transactions (user_id, date);
+	CREATE INDEX IF NOT EXISTS idx_transactions_user_type_date ON transactions (user_id, type, date);
+	CREATE INDEX IF NOT EXISTS idx_transactions_user_local_date ON transactions (user_id, local_date);
+	`
+	if _, err := db.Exec(transactionDateIndexesSQL); err != nil {
+		log.Printf("Error creating transactions date indexes: %v", err)
+		return err
+	}
+
+	// Imported transactions remember where they came from; an entry can only be imported once
+	for _, column := range []string{"external_source", "external_id"} {
+		if err := addColumnIfMissing("transactions", column, "TEXT NOT NULL DEFAULT ''"); err != nil {
+			log.Printf("Error adding %s column to transactions table: %v", column, err)
+			return err
+		}
+	}
+	if _, err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_external ON transactions (user_id, external_source, external_id) WHERE external_id != ''"); err != nil {
+		log.Printf("Error creating transactions external index: %v", err)
+		return err
+	}
+
+	// Full-text search index; created last because its triggers reference the other tables
+	if err := createSearchIndex(); err != nil {
+		log.Printf("Error creating search index: %v", err)
+		return err
+	}
+
 	return nil
 }
 
@@ -225,6 +516,143 @@ func migrateAssetsCategoryToID() error {
 	return nil
 }
 
+// addColumnIfMissing adds a column to an existing table unless it is already there
+func addColumnIfMissing(table, column, definition string) error {
+	var columnExists bool
+	err := db.QueryRow("SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&columnExists)
+	if err != nil {
+		return err
+	}
+	if columnExists {
+		return nil
+	}
+
+	_, err = db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	if err != nil {
+		return err
+	}
+	log.Printf("Added %s column to %s table", column, table)
+	return nil
+}
+
+// migrateAmountToMinorUnits converts the REAL amount column of a table into INTEGER minor units.
+// SQLite can't change the type of a column in place, so the table is rebuilt from its own
+// definition with the amount column retyped. Tables that already store INTEGER amounts are
+// left untouched, which makes this a one-time migration.
+func migrateAmountToMinorUnits(table string) error {
+	var columnType string
+	err := db.QueryRow("SELECT type FROM pragma_table_info(?) WHERE name = 'amount'", table).Scan(&columnType)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(columnType, "REAL") {
+		return nil
+	}
+
+	var createSQL string
+	err = db.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&createSQL)
+	if err != nil {
+		return err
+	}
+
+	newCreateSQL := amountRealPattern.ReplaceAllString(createSQL, "amount INTEGER")
+	if err := rebuildTable(table, newCreateSQL, map[string]string{"amount": "CAST(ROUND(amount * 100) AS INTEGER)"}); err != nil {
+		return err
+	}
+	log.Printf("Migrated %s amounts to integer minor units", table)
+	return nil
+}
+
+// migrateAssetRecordsUniqueDate drops the UNIQUE(asset_id, date) constraint of older asset_records
+// tables, which also counted records in the trash. The partial index replacing it can't be added
+// to the table definition, so the table is rebuilt without the constraint.
+func migrateAssetRecordsUniqueDate() error {
+	var createSQL string
+	err := db.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'asset_records'").Scan(&createSQL)
+	if err != nil {
+		return err
+	}
+	if !assetRecordsUniquePattern.MatchString(createSQL) {
+		return nil
+	}
+
+	if err := rebuildTable("asset_records", assetRecordsUniquePattern.ReplaceAllString(createSQL, ""), nil); err != nil {
+		return err
+	}
+	log.Printf("Migrated asset_records to allow trashed records on the same day")
+	return nil
+}
+
+// assetRecordsUniquePattern matches the table constraint of legacy asset_records tables
+var assetRecordsUniquePattern = regexp.MustCompile(`(?i),\s*UNIQUE\s*\(\s*asset_id\s*,\s*date\s*\)`)
+
+// rebuildTable replaces a table with one created by newCreateSQL, a definition of the same name
+// and columns, copying its rows and recreating its indexes. columnExprs gives the expressions
+// that convert the values of columns whose type changes.
+func rebuildTable(table, newCreateSQL string, columnExprs map[string]string) error {
+	// Indexes are dropped together with the old table and have to be recreated
+	var indexSQL []string
+	indexRows, err := db.Query("SELECT sql FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL", table)
+	if err != nil {
+		return err
+	}
+	for indexRows.Next() {
+		var stmt string
+		if err := indexRows.Scan(&stmt); err != nil {
+			indexRows.Close()
+			return err
+		}
+		indexSQL = append(indexSQL, stmt)
+	}
+	indexRows.Close()
+
+	var columns, selectExprs []string
+	columnRows, err := db.Query("SELECT name FROM pragma_table_info(?) ORDER BY cid", table)
+	if err != nil {
+		return err
+	}
+	for columnRows.Next() {
+		var name string
+		if err := columnRows.Scan(&name); err != nil {
+			columnRows.Close()
+			return err
+		}
+		columns = append(columns, name)
+		if expr, ok := columnExprs[name]; ok {
+			selectExprs = append(selectExprs, expr)
+		} else {
+			selectExprs = append(selectExprs, name)
+		}
+	}
+	columnRows.Close()
+
+	tmpTable := table + "_rebuilt"
+	newCreateSQL = strings.Replace(newCreateSQL, table, tmpTable, 1)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		newCreateSQL,
+		"INSERT INTO " + tmpTable + " (" + strings.Join(columns, ", ") + ") SELECT " + strings.Join(selectExprs, ", ") + " FROM " + table,
+		"DROP TABLE " + table,
+		"ALTER TABLE " + tmpTable + " RENAME TO " + table,
+	}
+	statements = append(statements, indexSQL...)
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// amountRealPattern matches the REAL amount column definition of legacy tables
+var amountRealPattern = regexp.MustCompile(`(?i)\bamount\s+REAL\b`)
+
 // InitializeDefaultAssetCategories creates default asset categories for a new user
 func InitializeDefaultAssetCategories(userID int64) error {
 	defaultCategories := []struct {
@@ -269,9 +697,26 @@ func InitializeDefaultAssetCategories(userID int64) error {
 	return nil
 }
 
+// transactionColumns lists the transaction columns in the order expected by scanTransaction
+const transactionColumns = "id, user_id, description, amount, currency, type, category_key, date, asset_id, to_asset_id, original_transaction_id, reimbursable, payee_id, external_source, external_id"
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTransaction scans a row selected with transactionColumns into a transaction.
+// Columns selected after transactionColumns are scanned into extra.
+func scanTransaction(row rowScanner, extra ...interface{}) (models.Transaction, error) {
+	var t models.Transaction
+	dest := []interface{}{&t.ID, &t.UserID, &t.Description, &t.Amount, &t.Currency, &t.Type, &t.CategoryKey, &t.Date, &t.AssetID, &t.ToAssetID, &t.OriginalTransactionID, &t.Reimbursable, &t.PayeeID, &t.ExternalSource, &t.ExternalID}
+	err := row.Scan(append(dest, extra...)...)
+	return t, err
+}
+
 // GetAllTransactions retrieves all transactions from database for a specific user
 func GetAllTransactions(userID int64) ([]models.Transaction, error) {
-	rows, err := db.Query("SELECT id, user_id, description, amount, type, category_key, date FROM transactions WHERE user_id = ? ORDER BY date DESC", userID)
+	rows, err := db.Query("SELECT "+transactionColumns+" FROM transactions WHERE user_id = ? AND deleted_at IS NULL ORDER BY date DESC", userID)
 	if err != nil {
 		return nil, err
 	}
@@ -279,61 +724,195 @@ func GetAllTransactions(userID int64) ([]models.Transaction, error) {
 
 	transactions := []models.Transaction{}
 	for rows.Next() {
-		var t models.Transaction
-		if err := rows.Scan(&t.ID, &t.UserID, &t.Description, &t.Amount, &t.Type, &t.CategoryKey, &t.Date); err != nil {
+		t, err := scanTransaction(rows)
+		if err != nil {
 			return nil, err
 		}
 		transactions = append(transactions, t)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := loadTransactionDetails(db, transactions); err != nil {
+		return nil, err
+	}
 	return transactions, nil
 }
 
-// GetFilteredTransactions retrieves filtered transactions from database for a specific user
-func GetFilteredTransactions(userID int64, transactionType, month, search, limit, date, startDate, endDate string) ([]models.Transaction, error) {
-	query := "SELECT id, user_id, description, amount, type, category_key, date FROM transactions WHERE user_id = ?"
-	args := []interface{}{userID}
+// GetTransactionByID retrieves a single transaction by ID for a specific user
+func GetTransactionByID(id, userID int64) (*models.Transaction, error) {
+	return getTransactionByID(db, id, userID)
+}
+
+// getTransactionByID retrieves a transaction with its splits using the given queryer
+func getTransactionByID(q queryer, id, userID int64) (*models.Transaction, error) {
+	row := q.QueryRow("SELECT "+transactionColumns+" FROM transactions WHERE id = ? AND user_id = ? AND deleted_at IS NULL", id, userID)
+	t, err := scanTransaction(row)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := []models.Transaction{t}
+	if err := loadTransactionDetails(q, transactions); err != nil {
+		return nil, err
+	}
+	return &transactions[0], nil
+}
+
+// TransactionFilter describes which transactions to list and in which order
+type TransactionFilter struct {
+	Type      string  // "income", "expense", "transfer"; empty or "all" for every type
+	Month     string  // YYYY-MM
+	Date      string  // YYYY-MM-DD, takes precedence over the range and month
+	StartDate string  // YYYY-MM-DD, used together with EndDate
+	EndDate   string  // YYYY-MM-DD, inclusive
+	Search    string  // full-text search, see ftsQuery
+	TagIDs    []int64 // matches transactions with any of the tags
+	PayeeIDs  []int64 // matches transactions of any of the payees
+	SortBy    string  // "date" (default), "amount", "category" or "relevance" (requires Search)
+	Ascending bool
+	Limit     int // 0 means no limit
+}
+
+// transactionSortColumns maps the supported sort fields to their columns
+var transactionSortColumns = map[string]string{
+	"date":     "date",
+	"amount":   "amount",
+	"category": "category_key",
+}
+
+// ErrInvalidSort is returned for unsupported sort fields
+var ErrInvalidSort = errors.New("sort must be one of date, amount, category or relevance")
+
+// ErrRelevanceWithoutSearch is returned when sorting by relevance without a search
+var ErrRelevanceWithoutSearch = errors.New("sort=relevance requires a search")
+
+// sortExpr returns the SQL expression to order by for the filter and its arguments.
+// Relevance is the FTS5 rank of the search, where lower values are better matches.
+func (f TransactionFilter) sortExpr() (string, []interface{}, error) {
+	if f.SortBy == "relevance" {
+		if f.Search == "" {
+			return "", nil, ErrRelevanceWithoutSearch
+		}
+		expr := "(SELECT rank FROM transactions_fts WHERE transactions_fts MATCH ? AND rowid = transactions.id)"
+		return expr, []interface{}{ftsQuery(f.Search)}, nil
+	}
+	if f.SortBy == "" {
+		return "date", nil, nil
+	}
+	column, ok := transactionSortColumns[f.SortBy]
+	if !ok {
+		return "", nil, ErrInvalidSort
+	}
+	return column, nil, nil
+}
+
+// orderBy returns the ORDER BY clause for the filter and its arguments; id breaks ties so the order is stable
+func (f TransactionFilter) orderBy() (string, []interface{}, error) {
+	expr, args, err := f.sortExpr()
+	if err != nil {
+		return "", nil, err
+	}
+	direction := "DESC"
+	if f.Ascending {
+		direction = "ASC"
+	}
+	return " ORDER BY " + expr + " " + direction + ", id " + direction, args, nil
+}
+
+// updateSearchIndex brings the search index up to date when the filter searches
+func (f TransactionFilter) updateSearchIndex() error {
+	if f.Search == "" {
+		return nil
+	}
+	return updateSearchIndex()
+}
+
+// conditions returns the SQL conditions of the filter, each starting with " AND ".
+// Trashed transactions never match.
+func (f TransactionFilter) conditions() (string, []interface{}) {
+	query := " AND deleted_at IS NULL"
+	args := []interface{}{}
 
 	// Add type filter
-	if transactionType != "" && transactionType != "all" {
+	if f.Type != "" && f.Type != "all" {
 		query += " AND type = ?"
-		args = append(args, transactionType)
+		args = append(args, f.Type)
 	}
 
-	// Add date filter (priority: specific date > date range > month)
-	if date != "" {
+	// Add date filter (priority: specific date > date range > month).
+	// Dates are calendar days of the user, matched against local_date.
+	if f.Date != "" {
 		// date format is "YYYY-MM-DD"
-		// SQLite stores Go time.Time as full timestamp like "2025-07-21 13:07:14.189539231 +0000 UTC"
-		// Use LIKE to match the date portion at the beginning
-		query += " AND date LIKE ?"
-		args = append(args, date+" %")
-	} else if startDate != "" && endDate != "" {
+		query += " AND local_date = ?"
+		args = append(args, f.Date)
+	} else if f.StartDate != "" && f.EndDate != "" {
 		// date range filter for multi-month queries like "最近三个月"
 		// startDate and endDate format is "YYYY-MM-DD"
-		query += " AND date >= ? AND date <= ?"
-		// Convert dates to proper format for comparison
-		startDateStr := startDate + " 00:00:00"
-		endDateStr := endDate + " 23:59:59"
-		args = append(args, startDateStr, endDateStr)
-	} else if month != "" && month != "all" {
+		query += " AND local_date >= ? AND local_date <= ?"
+		args = append(args, f.StartDate, f.EndDate)
+	} else if f.Month != "" && f.Month != "all" {
 		// month format is "YYYY-MM"
-		// Use LIKE to match the beginning of the date string since Go stores dates as full timestamp
-		query += " AND date LIKE ?"
-		args = append(args, month+"-%")
+		month, err := time.Parse("2006-01", f.Month)
+		if err != nil {
+			// Not a month, nothing matches
+			query += " AND 0"
+		} else {
+			query += " AND local_date >= ? AND local_date < ?"
+			args = append(args, month.Format(localDateLayout), month.AddDate(0, 1, 0).Format(localDateLayout))
+		}
+	}
+
+	// Add full-text search filter over descriptions, category names, tags and split notes
+	if f.Search != "" {
+		if match := ftsQuery(f.Search); match != "" {
+			query += " AND id IN (SELECT rowid FROM transactions_fts WHERE transactions_fts MATCH ?)"
+			args = append(args, match)
+		} else {
+			// Nothing searchable, e.g. only punctuation
+			query += " AND 0"
+		}
+	}
+
+	// Add tag filter, matching transactions with any of the tags
+	if len(f.TagIDs) > 0 {
+		query += " AND id IN (SELECT transaction_id FROM transaction_tags WHERE tag_id IN (" + placeholders(len(f.TagIDs)) + "))"
+		for _, id := range f.TagIDs {
+			args = append(args, id)
+		}
 	}
 
-	// Add search filter
-	if search != "" {
-		query += " AND (description LIKE ? OR category_key LIKE ?)"
-		searchPattern := "%" + search + "%"
-		args = append(args, searchPattern, searchPattern)
+	// Add payee filter
+	if len(f.PayeeIDs) > 0 {
+		query += " AND payee_id IN (" + placeholders(len(f.PayeeIDs)) + ")"
+		for _, id := range f.PayeeIDs {
+			args = append(args, id)
+		}
+	}
+
+	return query, args
+}
+
+// GetFilteredTransactions retrieves filtered transactions from database for a specific user
+func GetFilteredTransactions(userID int64, f TransactionFilter) ([]models.Transaction, error) {
+	if err := f.updateSearchIndex(); err != nil {
+		return nil, err
+	}
+	conditions, conditionArgs := f.conditions()
+	orderBy, orderArgs, err := f.orderBy()
+	if err != nil {
+		return nil, err
 	}
 
-	query += " ORDER BY date DESC"
+	query := "SELECT " + transactionColumns + " FROM transactions WHERE user_id = ?" + conditions + orderBy
+	args := append([]interface{}{userID}, conditionArgs...)
+	args = append(args, orderArgs...)
 
 	// Add limit if specified
-	if limit != "" {
+	if f.Limit > 0 {
 		query += " LIMIT ?"
-		args = append(args, limit)
+		args = append(args, f.Limit)
 	}
 
 	rows, err := db.Query(query, args...)
@@ -344,117 +923,496 @@ func GetFilteredTransactions(userID int64, transactionType, month, search, limit
 
 	transactions := []models.Transaction{}
 	for rows.Next() {
-		var t models.Transaction
-		if err := rows.Scan(&t.ID, &t.UserID, &t.Description, &t.Amount, &t.Type, &t.CategoryKey, &t.Date); err != nil {
+		t, err := scanTransaction(rows)
+		if err != nil {
 			return nil, err
 		}
 		transactions = append(transactions, t)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := loadTransactionDetails(db, transactions); err != nil {
+		return nil, err
+	}
 	return transactions, nil
 }
 
-// InsertTransaction inserts a new transaction into database
+// InsertTransaction inserts a new transaction into database.
+// Transactions without a currency are recorded in the user's base currency.
 func InsertTransaction(t *models.Transaction) error {
-	stmt, err := db.Prepare("INSERT INTO transactions(user_id, description, amount, type, category_key, date) VALUES(?, ?, ?, ?, ?, ?)")
+	if t.Currency == "" {
+		baseCurrency, err := GetUserBaseCurrency(t.UserID)
+		if err != nil {
+			return err
+		}
+		t.Currency = baseCurrency
+	}
+
+	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
-	res, err := stmt.Exec(t.UserID, t.Description, t.Amount, t.Type, t.CategoryKey, t.Date)
+	id, err := insertTransaction(tx, t)
 	if err != nil {
 		return err
 	}
 
-	id, err := res.LastInsertId()
-	if err != nil {
+	if err := tx.Commit(); err != nil {
 		return err
 	}
 	t.ID = id
 	return nil
 }
 
-// DeleteTransaction deletes a transaction by ID for a specific user
-func DeleteTransaction(id string, userID int64) (int64, error) {
-	stmt, err := db.Prepare("DELETE FROM transactions WHERE id = ? AND user_id = ?")
+// insertTransaction inserts a transaction with its splits and tags and returns its ID.
+// The currency must already be set.
+func insertTransaction(q queryer, t *models.Transaction) (int64, error) {
+	// Keep the date as it will be read back
+	t.Date = t.Date.UTC().Truncate(time.Millisecond)
+	localDate, err := transactionLocalDate(q, t.UserID, t.Date)
 	if err != nil {
 		return 0, err
 	}
-	defer stmt.Close()
 
-	result, err := stmt.Exec(id, userID)
+	res, err := q.Exec("INSERT INTO transactions(user_id, description, amount, currency, type, category_key, date, local_date, asset_id, to_asset_id, original_transaction_id, reimbursable, payee_id, external_source, external_id) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		t.UserID, t.Description, t.Amount, t.Currency, t.Type, t.CategoryKey, transactionDate(t.Date), localDate, t.AssetID, t.ToAssetID, t.OriginalTransactionID, t.Reimbursable, t.PayeeID, t.ExternalSource, t.ExternalID)
 	if err != nil {
 		return 0, err
 	}
 
-	return result.RowsAffected()
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := replaceSplits(q, id, t.Splits); err != nil {
+		return 0, err
+	}
+	if err := replaceTags(q, id, t.UserID, t.Tags); err != nil {
+		return 0, err
+	}
+	return id, nil
 }
 
-// GetSummaryForPeriod calculates summary for a specific time period for a user
-func GetSummaryForPeriod(userID int64, start, end time.Time) (models.Summary, error) {
-	var summary models.Summary
-	query := "SELECT COALESCE(SUM(amount), 0) FROM transactions WHERE user_id = ? AND type = ? AND date >= ? AND date < ?"
+// UpdateTransaction saves the new values of an existing transaction and records
+// the previous version in transaction_revisions. editorID is the user making the change.
+// It returns sql.ErrNoRows if the transaction doesn't exist for t.UserID.
+func UpdateTransaction(t *models.Transaction, editorID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := updateTransaction(tx, t, editorID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
-	err := db.QueryRow(query, userID, "income", start, end).Scan(&summary.TotalIncome)
+// updateTransaction saves the changes to a transaction and records a revision
+func updateTransaction(q queryer, t *models.Transaction, editorID int64) error {
+	old, err := getTransactionByID(q, t.ID, t.UserID)
 	if err != nil {
-		return summary, err
+		return err
+	}
+
+	// Keep the date as it will be read back
+	t.Date = t.Date.UTC().Truncate(time.Millisecond)
+	changedFields := changedTransactionFields(*old, *t)
+	if len(changedFields) == 0 {
+		// Nothing to save, don't record an empty revision
+		return nil
 	}
 
-	err = db.QueryRow(query, userID, "expense", start, end).Scan(&summary.TotalExpense)
+	localDate, err := transactionLocalDate(q, t.UserID, t.Date)
 	if err != nil {
-		return summary, err
+		return err
 	}
 
-	summary.Balance = summary.TotalIncome - summary.TotalExpense
-	return summary, nil
+	_, err = q.Exec(`
+		UPDATE transactions
+		SET description = ?, amount = ?, currency = ?, type = ?, category_key = ?, date = ?, local_date = ?, asset_id = ?, to_asset_id = ?,
+			original_transaction_id = ?, reimbursable = ?, payee_id = ?
+		WHERE id = ? AND user_id = ?
+	`, t.Description, t.Amount, t.Currency, t.Type, t.CategoryKey, transactionDate(t.Date), localDate, t.AssetID, t.ToAssetID,
+		t.OriginalTransactionID, t.Reimbursable, t.PayeeID, t.ID, t.UserID)
+	if err != nil {
+		return err
+	}
+
+	if !splitsEqual(old.Splits, t.Splits) {
+		if err := replaceSplits(q, t.ID, t.Splits); err != nil {
+			return err
+		}
+	}
+	if !tagsEqual(old.Tags, t.Tags) {
+		if err := replaceTags(q, t.ID, t.UserID, t.Tags); err != nil {
+			return err
+		}
+	}
+
+	oldData, err := json.Marshal(old)
+	if err != nil {
+		return err
+	}
+	newData, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(`
+		INSERT INTO transaction_revisions (transaction_id, user_id, changed_fields, old_data, new_data, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, t.ID, editorID, strings.Join(changedFields, ","), string(oldData), string(newData), time.Now())
+	return err
+}
+
+// changedTransactionFields returns the JSON names of the fields that differ between two versions of a transaction
+func changedTransactionFields(old, updated models.Transaction) []string {
+	var fields []string
+	if old.Description != updated.Description {
+		fields = append(fields, "description")
+	}
+	if old.Amount != updated.Amount {
+		fields = append(fields, "amount")
+	}
+	if old.Currency != updated.Currency {
+		fields = append(fields, "currency")
+	}
+	if old.Type != updated.Type {
+		fields = append(fields, "type")
+	}
+	if old.CategoryKey != updated.CategoryKey {
+		fields = append(fields, "categoryKey")
+	}
+	if !old.Date.Equal(updated.Date) {
+		fields = append(fields, "date")
+	}
+	if !int64PtrEqual(old.AssetID, updated.AssetID) {
+		fields = append(fields, "assetId")
+	}
+	if !int64PtrEqual(old.ToAssetID, updated.ToAssetID) {
+		fields = append(fields, "toAssetId")
+	}
+	if !int64PtrEqual(old.OriginalTransactionID, updated.OriginalTransactionID) {
+		fields = append(fields, "originalTransactionId")
+	}
+	if old.Reimbursable != updated.Reimbursable {
+		fields = append(fields, "reimbursable")
+	}
+	if !int64PtrEqual(old.PayeeID, updated.PayeeID) {
+		fields = append(fields, "payeeId")
+	}
+	if !tagsEqual(old.Tags, updated.Tags) {
+		fields = append(fields, "tags")
+	}
+	if !splitsEqual(old.Splits, updated.Splits) {
+		fields = append(fields, "splits")
+	}
+	return fields
+}
+
+// int64PtrEqual reports whether two optional IDs are both unset or hold the same value
+func int64PtrEqual(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// GetTransactionRevisions retrieves the change history of a transaction, newest first
+func GetTransactionRevisions(transactionID, userID int64) ([]models.TransactionRevision, error) {
+	rows, err := db.Query(`
+		SELECT r.id, r.transaction_id, r.user_id, r.changed_fields, r.old_data, r.new_data, r.created_at
+		FROM transaction_revisions r
+		JOIN transactions t ON t.id = r.transaction_id
+		WHERE r.transaction_id = ? AND t.user_id = ?
+		ORDER BY r.id DESC
+	`, transactionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.TransactionRevision{}
+	for rows.Next() {
+		var r models.TransactionRevision
+		var changedFields, oldData, newData string
+		if err := rows.Scan(&r.ID, &r.TransactionID, &r.UserID, &changedFields, &oldData, &newData, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		r.ChangedFields = []string{}
+		if changedFields != "" {
+			r.ChangedFields = strings.Split(changedFields, ",")
+		}
+		if err := json.Unmarshal([]byte(oldData), &r.OldValue); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(newData), &r.NewValue); err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, r)
+	}
+	return revisions, nil
+}
+
+// DeleteTransaction moves a transaction to the trash by ID for a specific user
+func DeleteTransaction(id string, userID int64) (int64, error) {
+	transactionID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rowsAffected, err := deleteTransaction(tx, transactionID, userID)
+	if err != nil || rowsAffected == 0 {
+		return rowsAffected, err
+	}
+	return rowsAffected, tx.Commit()
+}
+
+// deleteTransaction moves a transaction to the trash. Its splits, tags and attachments
+// are kept so it can be restored; they are removed when the trash is purged.
+func deleteTransaction(q queryer, id, userID int64) (int64, error) {
+	result, err := q.Exec("UPDATE transactions SET deleted_at = ? WHERE id = ? AND user_id = ? AND deleted_at IS NULL",
+		trashTime(time.Now()), id, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetSummaryForPeriod calculates summary for a specific time period for a user.
+// Amounts are converted to the user's base currency with the rate of each transaction date; those
+// without a rate are left out of the totals and listed as unconverted.
+func GetSummaryForPeriod(userID int64, start, end time.Time) (models.Summary, error) {
+	return getSummary(userID, "AND date >= ? AND date < ?", transactionDate(start), transactionDate(end))
 }
 
 // GetOverallSummary calculates summary for all transactions for a user
 func GetOverallSummary(userID int64) (models.Summary, error) {
-	var summary models.Summary
+	return getSummary(userID, "")
+}
 
-	err := db.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM transactions WHERE user_id = ? AND type = 'income'", userID).Scan(&summary.TotalIncome)
+// getSummary calculates the income and expense totals of the transactions matching the extra condition
+func getSummary(userID int64, condition string, args ...interface{}) (models.Summary, error) {
+	summary := models.Summary{Unconverted: []models.UnconvertedAmount{}}
+
+	converter, err := newCurrencyConverter(userID)
 	if err != nil {
 		return summary, err
 	}
+	summary.Currency = converter.baseCurrency
+	unconverted := make(map[[2]string]models.Money) // by type and currency
 
-	err = db.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM transactions WHERE user_id = ? AND type = 'expense'", userID).Scan(&summary.TotalExpense)
+	// Group by currency and day so every group is converted with a single rate.
+	// Refunds reduce expenses rather than adding to income.
+	query := `
+		SELECT ` + netTypeSQL + ` AS net_type, currency, local_date AS day, SUM(` + netAmountSQL + `)
+		FROM transactions t
+		WHERE user_id = ? AND type IN ('income', 'expense') AND deleted_at IS NULL ` + condition + `
+		GROUP BY net_type, currency, day
+	`
+	rows, err := db.Query(query, append([]interface{}{userID}, args...)...)
 	if err != nil {
 		return summary, err
 	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transType, currency, day string
+		var amount models.Money
+		if err := rows.Scan(&transType, &currency, &day, &amount); err != nil {
+			return summary, err
+		}
+
+		converted, ok, err := converter.tryConvert(amount, currency, day)
+		if err != nil {
+			return summary, err
+		}
+		if !ok {
+			unconverted[[2]string{transType, currency}] += amount
+			continue
+		}
+		if transType == "income" {
+			summary.TotalIncome += converted
+		} else {
+			summary.TotalExpense += converted
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return summary, err
+	}
+
+	for group, amount := range unconverted {
+		if amount != 0 {
+			summary.Unconverted = append(summary.Unconverted, models.UnconvertedAmount{Type: group[0], Currency: group[1], Amount: amount})
+		}
+	}
+	sort.Slice(summary.Unconverted, func(i, j int) bool {
+		a, b := summary.Unconverted[i], summary.Unconverted[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Currency < b.Currency
+	})
 
 	summary.Balance = summary.TotalIncome - summary.TotalExpense
 	return summary, nil
 }
 
-// GetBreakdownForPeriod gets category breakdown for a specific period for a user
-func GetBreakdownForPeriod(userID int64, transType string, start, end time.Time, total float64) ([]models.CategoryStat, error) {
+// GetBreakdownForPeriod gets category breakdown for a specific period for a user.
+// Split transactions count towards the category of each split line.
+// Amounts are converted to the user's base currency; total must be in the base currency too.
+// Amounts without an exchange rate are left out, as they are from the summary.
+func GetBreakdownForPeriod(userID int64, transType string, start, end time.Time, total models.Money) ([]models.CategoryStat, error) {
+	converter, err := newCurrencyConverter(userID)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT category_key, SUM(amount) as total
-		FROM transactions
+		SELECT category_key, currency, local_date AS day, SUM(amount)
+		FROM (` + categoryLinesSQL + `)
 		WHERE user_id = ? AND type = ? AND date >= ? AND date < ?
-		GROUP BY category_key
-		ORDER BY total DESC
+		GROUP BY category_key, currency, day
 	`
-	rows, err := db.Query(query, userID, transType, start, end)
+	rows, err := db.Query(query, userID, transType, transactionDate(start), transactionDate(end))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	breakdown := make([]models.CategoryStat, 0)
+	totals := make(map[string]models.Money)
 	for rows.Next() {
-		var stat models.CategoryStat
-		if err := rows.Scan(&stat.CategoryKey, &stat.Amount); err != nil {
+		var categoryKey, currency, day string
+		var amount models.Money
+		if err := rows.Scan(&categoryKey, &currency, &day, &amount); err != nil {
+			return nil, err
+		}
+
+		converted, ok, err := converter.tryConvert(amount, currency, day)
+		if err != nil {
 			return nil, err
 		}
+		if !ok {
+			continue
+		}
+		totals[categoryKey] += converted
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	breakdown := make([]models.CategoryStat, 0, len(totals))
+	for categoryKey, amount := range totals {
+		// A category whose expenses were fully refunded drops out
+		if amount == 0 {
+			continue
+		}
+		stat := models.CategoryStat{CategoryKey: categoryKey, Amount: amount}
 		if total > 0 {
-			stat.Percentage = (stat.Amount / total) * 100
+			stat.Percentage = float64(stat.Amount) / float64(total) * 100
 		} else {
 			stat.Percentage = 0
 		}
 		breakdown = append(breakdown, stat)
 	}
+	sort.Slice(breakdown, func(i, j int) bool {
+		if breakdown[i].Amount != breakdown[j].Amount {
+			return breakdown[i].Amount > breakdown[j].Amount
+		}
+		return breakdown[i].CategoryKey < breakdown[j].CategoryKey
+	})
+	return breakdown, nil
+}
+
+// namedTotal is the total of one payee or tag in a breakdown
+type namedTotal struct {
+	id         int64
+	name       string
+	amount     models.Money
+	count      int
+	percentage float64
+}
+
+// getNamedBreakdown gets the totals of a transaction type for a period grouped by idColumn, largest
+// first. join links the transactions t to the table holding idColumn and nameColumn. Refunds count
+// as negative expenses, and amounts are converted to the user's base currency like those of
+// GetBreakdownForPeriod.
+func getNamedBreakdown(userID int64, transType string, start, end time.Time, total models.Money, join, idColumn, nameColumn string) ([]namedTotal, error) {
+	converter, err := newCurrencyConverter(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT `+idColumn+`, `+nameColumn+`, t.currency, t.local_date AS day, SUM(`+netAmountSQL+`), COUNT(*)
+		FROM transactions t
+		`+join+`
+		WHERE t.user_id = ? AND `+netTypeSQL+` = ? AND t.deleted_at IS NULL AND t.date >= ? AND t.date < ?
+		GROUP BY `+idColumn+`, t.currency, day
+	`, userID, transType, transactionDate(start), transactionDate(end))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := make(map[int64]*namedTotal)
+	for rows.Next() {
+		var id int64
+		var name, currency, day string
+		var amount models.Money
+		var count int
+		if err := rows.Scan(&id, &name, &currency, &day, &amount, &count); err != nil {
+			return nil, err
+		}
+
+		converted, ok, err := converter.tryConvert(amount, currency, day)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if totals[id] == nil {
+			totals[id] = &namedTotal{id: id, name: name}
+		}
+		totals[id].amount += converted
+		totals[id].count += count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	breakdown := make([]namedTotal, 0, len(totals))
+	for _, t := range totals {
+		if t.amount == 0 {
+			continue
+		}
+		if total > 0 {
+			t.percentage = float64(t.amount) / float64(total) * 100
+		}
+		breakdown = append(breakdown, *t)
+	}
+	sort.Slice(breakdown, func(i, j int) bool {
+		if breakdown[i].amount != breakdown[j].amount {
+			return breakdown[i].amount > breakdown[j].amount
+		}
+		return breakdown[i].name < breakdown[j].name
+	})
 	return breakdown, nil
 }
 
@@ -462,14 +1420,21 @@ func GetBreakdownForPeriod(userID int64, transType string, start, end time.Time,
 
 // CreateUser creates a new user
 func CreateUser(user *models.User) error {
-	stmt, err := db.Prepare("INSERT INTO users(username, email, avatar, password, created_at, updated_at) VALUES(?, ?, ?, ?, ?, ?)")
-	if err != nil {
-		return err
+	return createUser(db, user)
+}
+
+// createUser creates a new user using the given queryer
+func createUser(q queryer, user *models.User) error {
+	if user.BaseCurrency == "" {
+		user.BaseCurrency = models.DefaultCurrency
+	}
+	if user.Timezone == "" {
+		user.Timezone = models.DefaultTimezone
 	}
-	defer stmt.Close()
 
 	now := time.Now()
-	res, err := stmt.Exec(user.Username, user.Email, user.Avatar, user.Password, now, now)
+	res, err := q.Exec("INSERT INTO users(username, email, avatar, password, base_currency, timezone, created_at, updated_at) VALUES(?, ?, ?, ?, ?, ?, ?, ?)",
+		user.Username, user.Email, user.Avatar, user.Password, user.BaseCurrency, user.Timezone, now, now)
 	if err != nil {
 		return err
 	}
@@ -487,8 +1452,8 @@ func CreateUser(user *models.User) error {
 // GetUserByUsername retrieves a user by username
 func GetUserByUsername(username string) (*models.User, error) {
 	var user models.User
-	err := db.QueryRow("SELECT id, username, email, avatar, password, created_at, updated_at FROM users WHERE username = ?", username).
-		Scan(&user.ID, &user.Username, &user.Email, &user.Avatar, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+	err := db.QueryRow("SELECT id, username, email, avatar, password, base_currency, timezone, created_at, updated_at FROM users WHERE username = ?", username).
+		Scan(&user.ID, &user.Username, &user.Email, &user.Avatar, &user.Password, &user.BaseCurrency, &user.Timezone, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -498,8 +1463,8 @@ func GetUserByUsername(username string) (*models.User, error) {
 // GetUserByEmail retrieves a user by email
 func GetUserByEmail(email string) (*models.User, error) {
 	var user models.User
-	err := db.QueryRow("SELECT id, username, email, avatar, password, created_at, updated_at FROM users WHERE email = ?", email).
-		Scan(&user.ID, &user.Username, &user.Email, &user.Avatar, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+	err := db.QueryRow("SELECT id, username, email, avatar, password, base_currency, timezone, created_at, updated_at FROM users WHERE email = ?", email).
+		Scan(&user.ID, &user.Username, &user.Email, &user.Avatar, &user.Password, &user.BaseCurrency, &user.Timezone, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -509,8 +1474,8 @@ func GetUserByEmail(email string) (*models.User, error) {
 // GetUserByID retrieves a user by ID
 func GetUserByID(id int64) (*models.User, error) {
 	var user models.User
-	err := db.QueryRow("SELECT id, username, email, avatar, password, created_at, updated_at FROM users WHERE id = ?", id).
-		Scan(&user.ID, &user.Username, &user.Email, &user.Avatar, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+	err := db.QueryRow("SELECT id, username, email, avatar, password, base_currency, timezone, created_at, updated_at FROM users WHERE id = ?", id).
+		Scan(&user.ID, &user.Username, &user.Email, &user.Avatar, &user.Password, &user.BaseCurrency, &user.Timezone, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -553,6 +1518,55 @@ func UpdateUserEmail(userID int64, email string) error {
 	return err
 }
 
+// UpdateUserBaseCurrency updates a user's base currency
+func UpdateUserBaseCurrency(userID int64, currency string) error {
+	stmt, err := db.Prepare("UPDATE users SET base_currency = ?, updated_at = ? WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(currency, time.Now(), userID)
+	return err
+}
+
+// UpdateUserTimezone updates a user's timezone and the local dates of their transactions
+func UpdateUserTimezone(userID int64, timezone string) error {
+	loc, err := models.LoadTimezone(timezone)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE users SET timezone = ?, updated_at = ? WHERE id = ?", timezone, time.Now(), userID); err != nil {
+		return err
+	}
+	if err := refreshLocalDates(tx, userID, loc); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetUserLocation loads the timezone of a user
+func GetUserLocation(userID int64) (*time.Location, error) {
+	return userLocation(db, userID)
+}
+
+// GetUserBaseCurrency retrieves the base currency of a user
+func GetUserBaseCurrency(userID int64) (string, error) {
+	var currency string
+	err := db.QueryRow("SELECT base_currency FROM users WHERE id = ?", userID).Scan(&currency)
+	if err != nil {
+		return "", err
+	}
+	return currency, nil
+}
+
 // Asset-related database operations
 
 // CreateAsset creates a new asset
@@ -569,14 +1583,23 @@ func CreateAsset(asset *models.Asset) error {
 		asset.Category = categoryName
 	}
 
-	stmt, err := db.Prepare("INSERT INTO assets(user_id, name, category, category_id, created_at, updated_at) VALUES(?, ?, ?, ?, ?, ?)")
+	// Assets without a currency are held in the user's base currency
+	if asset.Currency == "" {
+		baseCurrency, err := GetUserBaseCurrency(asset.UserID)
+		if err != nil {
+			return err
+		}
+		asset.Currency = baseCurrency
+	}
+
+	stmt, err := db.Prepare("INSERT INTO assets(user_id, name, category, category_id, currency, created_at, updated_at) VALUES(?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
 	now := time.Now()
-	res, err := stmt.Exec(asset.UserID, asset.Name, asset.Category, asset.CategoryID, now, now)
+	res, err := stmt.Exec(asset.UserID, asset.Name, asset.Category, asset.CategoryID, asset.Currency, now, now)
 	if err != nil {
 		return err
 	}
@@ -596,11 +1619,11 @@ func GetAssetsByUserID(userID int64) ([]models.Asset, error) {
 	query := `
 		SELECT a.id, a.user_id, a.name, 
 			   COALESCE(ac.name, a.category) as category_name,
-			   a.category_id,
+			   a.category_id, a.currency,
 			   a.created_at, a.updated_at 
 		FROM assets a
 		LEFT JOIN asset_categories ac ON a.category_id = ac.id
-		WHERE a.user_id = ? 
+		WHERE a.user_id = ? AND a.deleted_at IS NULL
 		ORDER BY a.created_at DESC
 	`
 	rows, err := db.Query(query, userID)
@@ -612,7 +1635,7 @@ func GetAssetsByUserID(userID int64) ([]models.Asset, error) {
 	assets := []models.Asset{}
 	for rows.Next() {
 		var asset models.Asset
-		if err := rows.Scan(&asset.ID, &asset.UserID, &asset.Name, &asset.Category, &asset.CategoryID, &asset.CreatedAt, &asset.UpdatedAt); err != nil {
+		if err := rows.Scan(&asset.ID, &asset.UserID, &asset.Name, &asset.Category, &asset.CategoryID, &asset.Currency, &asset.CreatedAt, &asset.UpdatedAt); err != nil {
 			return nil, err
 		}
 		assets = append(assets, asset)
@@ -636,13 +1659,25 @@ func GetAssetsWithRecordsByUserID(userID int64) ([]models.AssetWithRecords, erro
 			return nil, err
 		}
 
+		// Derive the current balance from the latest record and the transactions after it
+		var anchor *models.AssetRecord
+		if len(records) > 0 {
+			anchor = &records[0]
+		}
+		ledger, err := buildAssetLedger(asset, anchor)
+		if err != nil {
+			return nil, err
+		}
+
 		result[i] = models.AssetWithRecords{
 			ID:         asset.ID,
 			UserID:     asset.UserID,
 			Name:       asset.Name,
 			Category:   asset.Category,
 			CategoryID: asset.CategoryID,
+			Currency:   asset.Currency,
 			Records:    records,
+			Balance:    ledger.Balance,
 			CreatedAt:  asset.CreatedAt,
 			UpdatedAt:  asset.UpdatedAt,
 		}
@@ -654,36 +1689,41 @@ func GetAssetsWithRecordsByUserID(userID int64) ([]models.AssetWithRecords, erro
 // GetAssetByID retrieves an asset by ID and verifies user ownership
 func GetAssetByID(assetID, userID int64) (*models.Asset, error) {
 	var asset models.Asset
-	err := db.QueryRow("SELECT id, user_id, name, created_at, updated_at FROM assets WHERE id = ? AND user_id = ?", assetID, userID).
-		Scan(&asset.ID, &asset.UserID, &asset.Name, &asset.CreatedAt, &asset.UpdatedAt)
+	err := db.QueryRow(`
+		SELECT a.id, a.user_id, a.name,
+			   COALESCE(ac.name, a.category) as category_name,
+			   a.category_id, a.currency,
+			   a.created_at, a.updated_at
+		FROM assets a
+		LEFT JOIN asset_categories ac ON a.category_id = ac.id
+		WHERE a.id = ? AND a.user_id = ? AND a.deleted_at IS NULL
+	`, assetID, userID).
+		Scan(&asset.ID, &asset.UserID, &asset.Name, &asset.Category, &asset.CategoryID, &asset.Currency, &asset.CreatedAt, &asset.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return &asset, nil
 }
 
-// DeleteAsset deletes an asset and all its records
+// DeleteAsset moves an asset to the trash. Linked transactions keep their link
+// so restoring the asset restores its ledger; the links are removed when it is purged.
 func DeleteAsset(assetID, userID int64) error {
-	stmt, err := db.Prepare("DELETE FROM assets WHERE id = ? AND user_id = ?")
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-
-	_, err = stmt.Exec(assetID, userID)
+	_, err := db.Exec("UPDATE assets SET deleted_at = ? WHERE id = ? AND user_id = ? AND deleted_at IS NULL",
+		trashTime(time.Now()), assetID, userID)
 	return err
 }
 
 // CreateAssetRecord creates a new asset record
 func CreateAssetRecord(record *models.AssetRecord) error {
-	stmt, err := db.Prepare("INSERT OR REPLACE INTO asset_records(asset_id, date, amount, created_at, updated_at) VALUES(?, ?, ?, ?, ?)")
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
+	return createAssetRecord(db, record)
+}
 
+// createAssetRecord creates an asset record, replacing the record of the same day. Records of
+// that day in the trash are kept.
+func createAssetRecord(q queryer, record *models.AssetRecord) error {
 	now := time.Now()
-	res, err := stmt.Exec(record.AssetID, record.Date, record.Amount, now, now)
+	res, err := q.Exec("INSERT OR REPLACE INTO asset_records(asset_id, date, amount, created_at, updated_at) VALUES(?, ?, ?, ?, ?)",
+		record.AssetID, record.Date, record.Amount, now, now)
 	if err != nil {
 		return err
 	}
@@ -700,7 +1740,7 @@ func CreateAssetRecord(record *models.AssetRecord) error {
 
 // GetAssetRecordsByAssetID retrieves all records for a specific asset
 func GetAssetRecordsByAssetID(assetID int64) ([]models.AssetRecord, error) {
-	rows, err := db.Query("SELECT id, asset_id, date, amount, created_at, updated_at FROM asset_records WHERE asset_id = ? ORDER BY date DESC", assetID)
+	rows, err := db.Query("SELECT id, asset_id, date, amount, created_at, updated_at FROM asset_records WHERE asset_id = ? AND deleted_at IS NULL ORDER BY date DESC", assetID)
 	if err != nil {
 		return nil, err
 	}
@@ -717,11 +1757,11 @@ func GetAssetRecordsByAssetID(assetID int64) ([]models.AssetRecord, error) {
 	return records, nil
 }
 
-// DeleteAssetRecord deletes a specific asset record
+// DeleteAssetRecord moves a specific asset record to the trash
 func DeleteAssetRecord(recordID, assetID, userID int64) error {
 	stmt, err := db.Prepare(`
-		DELETE FROM asset_records 
-		WHERE id = ? AND asset_id = ? AND asset_id IN (
+		UPDATE asset_records SET deleted_at = ?
+		WHERE id = ? AND asset_id = ? AND deleted_at IS NULL AND asset_id IN (
 			SELECT id FROM assets WHERE user_id = ?
 		)
 	`)
@@ -730,16 +1770,16 @@ func DeleteAssetRecord(recordID, assetID, userID int64) error {
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(recordID, assetID, userID)
+	_, err = stmt.Exec(trashTime(time.Now()), recordID, assetID, userID)
 	return err
 }
 
 // UpdateAssetRecord updates a specific asset record
-func UpdateAssetRecord(recordID, assetID, userID int64, date string, amount float64) (*models.AssetRecord, error) {
+func UpdateAssetRecord(recordID, assetID, userID int64, date string, amount models.Money) (*models.AssetRecord, error) {
 	stmt, err := db.Prepare(`
 		UPDATE asset_records 
 		SET date = ?, amount = ?, updated_at = CURRENT_TIMESTAMP
-		WHERE id = ? AND asset_id = ? AND asset_id IN (
+		WHERE id = ? AND asset_id = ? AND deleted_at IS NULL AND asset_id IN (
 			SELECT id FROM assets WHERE user_id = ?
 		)
 	`)
@@ -1036,11 +2076,11 @@ func DeleteTransactionCategory(userID int64, key, categoryType string) error {
 // GetAutoTransactions retrieves all auto transactions for a user
 func GetAutoTransactions(userID int64) ([]models.AutoTransaction, error) {
 	rows, err := db.Query(`
-		SELECT id, user_id, type, amount, category_key, description, frequency, 
+		SELECT id, user_id, type, amount, currency, category_key, description, asset_id, frequency, 
 		       day_of_month, day_of_week, next_execution_date, last_execution_date,
 		       is_active, created_at, updated_at
 		FROM auto_transactions 
-		WHERE user_id = ?
+		WHERE user_id = ? AND deleted_at IS NULL
 		ORDER BY created_at DESC
 	`, userID)
 	if err != nil {
@@ -1055,8 +2095,8 @@ func GetAutoTransactions(userID int64) ([]models.AutoTransaction, error) {
 		var lastExecutionDate *string
 
 		err := rows.Scan(
-			&at.ID, &at.UserID, &at.Type, &at.Amount, &at.CategoryKey,
-			&at.Description, &at.Frequency, &at.DayOfMonth, &at.DayOfWeek,
+			&at.ID, &at.UserID, &at.Type, &at.Amount, &at.Currency, &at.CategoryKey,
+			&at.Description, &at.AssetID, &at.Frequency, &at.DayOfMonth, &at.DayOfWeek,
 			&at.NextExecutionDate, &lastExecutionDate, &at.IsActive,
 			&at.CreatedAt, &at.UpdatedAt,
 		)
@@ -1082,11 +2122,11 @@ func GetAutoTransactions(userID int64) ([]models.AutoTransaction, error) {
 func CreateAutoTransaction(at models.AutoTransaction) (int64, error) {
 	result, err := db.Exec(`
 		INSERT INTO auto_transactions (
-			user_id, type, amount, category_key, description, frequency,
+			user_id, type, amount, currency, category_key, description, asset_id, frequency,
 			day_of_month, day_of_week, next_execution_date, is_active,
 			created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, at.UserID, at.Type, at.Amount, at.CategoryKey, at.Description, at.Frequency,
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, at.UserID, at.Type, at.Amount, at.Currency, at.CategoryKey, at.Description, at.AssetID, at.Frequency,
 		at.DayOfMonth, at.DayOfWeek, at.NextExecutionDate, at.IsActive,
 		time.Now(), time.Now())
 
@@ -1101,23 +2141,23 @@ func CreateAutoTransaction(at models.AutoTransaction) (int64, error) {
 func UpdateAutoTransaction(at models.AutoTransaction) error {
 	_, err := db.Exec(`
 		UPDATE auto_transactions SET
-			type = ?, amount = ?, category_key = ?, description = ?,
-			frequency = ?, day_of_month = ?, day_of_week = ?,
+			type = ?, amount = ?, currency = ?, category_key = ?, description = ?,
+			asset_id = ?, frequency = ?, day_of_month = ?, day_of_week = ?,
 			next_execution_date = ?, is_active = ?, updated_at = ?
-		WHERE id = ? AND user_id = ?
-	`, at.Type, at.Amount, at.CategoryKey, at.Description, at.Frequency,
+		WHERE id = ? AND user_id = ? AND deleted_at IS NULL
+	`, at.Type, at.Amount, at.Currency, at.CategoryKey, at.Description, at.AssetID, at.Frequency,
 		at.DayOfMonth, at.DayOfWeek, at.NextExecutionDate, at.IsActive,
 		time.Now(), at.ID, at.UserID)
 
 	return err
 }
 
-// DeleteAutoTransaction deletes an auto transaction
+// DeleteAutoTransaction moves an auto transaction to the trash
 func DeleteAutoTransaction(userID, id int64) error {
 	_, err := db.Exec(`
-		DELETE FROM auto_transactions 
-		WHERE id = ? AND user_id = ?
-	`, id, userID)
+		UPDATE auto_transactions SET deleted_at = ?
+		WHERE id = ? AND user_id = ? AND deleted_at IS NULL
+	`, trashTime(time.Now()), id, userID)
 	return err
 }
 
@@ -1127,7 +2167,7 @@ func ToggleAutoTransaction(userID, id int64) error {
 		UPDATE auto_transactions SET
 			is_active = CASE WHEN is_active = 1 THEN 0 ELSE 1 END,
 			updated_at = ?
-		WHERE id = ? AND user_id = ?
+		WHERE id = ? AND user_id = ? AND deleted_at IS NULL
 	`, time.Now(), id, userID)
 	return err
 }
@@ -1135,13 +2175,13 @@ func ToggleAutoTransaction(userID, id int64) error {
 // GetDueAutoTransactions retrieves auto transactions that are due for execution
 func GetDueAutoTransactions() ([]models.AutoTransaction, error) {
 	rows, err := db.Query(`
-		SELECT id, user_id, type, amount, category_key, description, frequency, 
+		SELECT id, user_id, type, amount, currency, category_key, description, asset_id, frequency, 
 		       day_of_month, day_of_week, next_execution_date, last_execution_date,
 		       is_active, created_at, updated_at
 		FROM auto_transactions 
-		WHERE is_active = 1 AND next_execution_date <= ?
+		WHERE is_active = 1 AND deleted_at IS NULL AND next_execution_date <= ?
 		ORDER BY next_execution_date ASC
-	`, time.Now())
+	`, time.Now().UTC())
 	if err != nil {
 		return []models.AutoTransaction{}, err
 	}
@@ -1154,8 +2194,8 @@ func GetDueAutoTransactions() ([]models.AutoTransaction, error) {
 		var lastExecutionDate *string
 
 		err := rows.Scan(
-			&at.ID, &at.UserID, &at.Type, &at.Amount, &at.CategoryKey,
-			&at.Description, &at.Frequency, &at.DayOfMonth, &at.DayOfWeek,
+			&at.ID, &at.UserID, &at.Type, &at.Amount, &at.Currency, &at.CategoryKey,
+			&at.Description, &at.AssetID, &at.Frequency, &at.DayOfMonth, &at.DayOfWeek,
 			&at.NextExecutionDate, &lastExecutionDate, &at.IsActive,
 			&at.CreatedAt, &at.UpdatedAt,
 		)
@@ -1185,6 +2225,6 @@ func UpdateAutoTransactionExecution(id int64, nextExecutionDate time.Time) error
 			next_execution_date = ?,
 			updated_at = ?
 		WHERE id = ?
-	`, time.Now(), nextExecutionDate, time.Now(), id)
+	`, time.Now().UTC(), nextExecutionDate.UTC(), time.Now(), id)
 	return err
 }
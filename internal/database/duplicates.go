@@ -0,0 +1,324 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"mini-money/internal/models"
+)
+
+var (
+	// ErrDuplicateNotFound is returned when a transaction to merge doesn't exist for the user
+	ErrDuplicateNotFound = errors.New("duplicate transaction not found")
+	// ErrMergeMismatch is returned when merging transactions of a different type, currency or amount
+	ErrMergeMismatch = errors.New("only transactions of the same type, currency and amount can be merged")
+	// ErrMergeOverRefunded is returned when the refunds of the merged transactions add up to more than its amount
+	ErrMergeOverRefunded = errors.New("the refunds of the merged transactions exceed its amount")
+	// ErrMergeIntoItself is returned when a transaction is listed as its own duplicate
+	ErrMergeIntoItself = errors.New("a transaction can't be merged into itself")
+)
+
+// duplicateWindow is how far apart the dates of two duplicates may be
+const duplicateWindow = 24 * time.Hour
+
+// duplicateSimilarity is how similar two descriptions must at least be, where 1 is identical
+const duplicateSimilarity = 0.8
+
+// isDuplicate reports whether two transactions look like the same entry: the same amount, type,
+// currency, category and accounts, dates within duplicateWindow and near-identical descriptions
+func isDuplicate(a, b models.Transaction) bool {
+	if a.Amount != b.Amount || a.Type != b.Type || a.Currency != b.Currency || a.CategoryKey != b.CategoryKey {
+		return false
+	}
+	if a.Type == "transfer" && (!int64PtrEqual(a.AssetID, b.AssetID) || !int64PtrEqual(a.ToAssetID, b.ToAssetID)) {
+		return false
+	}
+	if gap := a.Date.Sub(b.Date); gap > duplicateWindow || gap < -duplicateWindow {
+		return false
+	}
+	return similarDescriptions(a.Description, b.Description)
+}
+
+// similarDescriptions compares descriptions ignoring case, full-width characters, spacing and
+// punctuation. One description containing the other counts as similar.
+func similarDescriptions(a, b string) bool {
+	a, b = descriptionLetters(a), descriptionLetters(b)
+	if a == b {
+		return true
+	}
+	if a == "" || b == "" {
+		return false
+	}
+	if strings.Contains(a, b) || strings.Contains(b, a) {
+		return true
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	return 1-float64(editDistance(ra, rb))/float64(longest) >= duplicateSimilarity
+}
+
+// descriptionLetters normalizes a description and keeps only its letters and digits
+func descriptionLetters(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return r
+		}
+		return -1
+	}, normalizePayeeText(s))
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// FindDuplicates retrieves the transactions of t's owner that look like the same entry as t,
+// most recent first. t itself is left out when it has been saved.
+func FindDuplicates(t *models.Transaction) ([]models.Transaction, error) {
+	rows, err := db.Query(`
+		SELECT `+transactionColumns+`
+		FROM transactions
+		WHERE user_id = ? AND type = ? AND date >= ? AND date <= ? AND deleted_at IS NULL
+			AND amount = ? AND currency = ? AND category_key = ? AND id != ?
+		ORDER BY date DESC, id DESC
+	`, t.UserID, t.Type, transactionDate(t.Date.Add(-duplicateWindow)), transactionDate(t.Date.Add(duplicateWindow)),
+		t.Amount, t.Currency, t.CategoryKey, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	duplicates := []models.Transaction{}
+	for rows.Next() {
+		candidate, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		if isDuplicate(*t, candidate) {
+			duplicates = append(duplicates, candidate)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := loadTransactionDetails(db, duplicates); err != nil {
+		return nil, err
+	}
+	return duplicates, nil
+}
+
+// GetDuplicateGroups scans all transactions of a user for entries recorded more than once.
+// Transactions that are duplicates of each other, directly or through another one, form a group.
+// Groups with the most recent transactions come first.
+func GetDuplicateGroups(userID int64) ([]models.DuplicateGroup, error) {
+	// Duplicates share type, currency, amount and category, so after sorting they are close together
+	rows, err := db.Query(`
+		SELECT `+transactionColumns+`
+		FROM transactions
+		WHERE user_id = ? AND deleted_at IS NULL
+		ORDER BY type, currency, amount, category_key, date, id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []models.Transaction
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Union-find over the indexes of transactions
+	parent := make([]int, len(transactions))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range transactions {
+		a := transactions[i]
+		for j := i + 1; j < len(transactions); j++ {
+			b := transactions[j]
+			if b.Type != a.Type || b.Currency != a.Currency || b.Amount != a.Amount || b.CategoryKey != a.CategoryKey ||
+				b.Date.Sub(a.Date) > duplicateWindow {
+				break
+			}
+			if isDuplicate(a, b) {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	members := make(map[int][]int)
+	for i := range transactions {
+		root := find(i)
+		members[root] = append(members[root], i)
+	}
+
+	var grouped []models.Transaction
+	var sizes []int
+	for _, indexes := range members {
+		if len(indexes) < 2 {
+			continue
+		}
+		for _, i := range indexes {
+			grouped = append(grouped, transactions[i])
+		}
+		sizes = append(sizes, len(indexes))
+	}
+	if err := loadTransactionDetails(db, grouped); err != nil {
+		return nil, err
+	}
+
+	groups := make([]models.DuplicateGroup, 0, len(sizes))
+	for _, size := range sizes {
+		group := models.DuplicateGroup{Transactions: grouped[:size]}
+		grouped = grouped[size:]
+		sort.Slice(group.Transactions, func(i, j int) bool {
+			return newerTransaction(group.Transactions[i], group.Transactions[j])
+		})
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return newerTransaction(groups[i].Transactions[0], groups[j].Transactions[0])
+	})
+	return groups, nil
+}
+
+// newerTransaction reports whether a comes before b in a most-recent-first list
+func newerTransaction(a, b models.Transaction) bool {
+	if !a.Date.Equal(b.Date) {
+		return a.Date.After(b.Date)
+	}
+	return a.ID > b.ID
+}
+
+// MergeTransactions merges duplicates into the transaction with ID keepID and moves the duplicates
+// to the trash; they must have the same type, currency and amount. The kept transaction gains their
+// tags and attachments, their payee or the expense they refund when it has none itself, and refunds
+// of the duplicates are linked to it instead, as long as they don't exceed its amount.
+// It returns sql.ErrNoRows if the kept transaction doesn't exist for the user.
+func MergeTransactions(keepID int64, duplicateIDs []int64, userID int64) (*models.Transaction, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	keep, err := getTransactionByID(tx, keepID, userID)
+	if err != nil {
+		return nil, err
+	}
+	merged := *keep
+	merged.Tags = append([]models.Tag(nil), keep.Tags...)
+
+	seen := make(map[int64]bool)
+	for _, id := range duplicateIDs {
+		if id == keepID {
+			return nil, ErrMergeIntoItself
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		duplicate, err := getTransactionByID(tx, id, userID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %d", ErrDuplicateNotFound, id)
+		}
+		if duplicate.Type != keep.Type || duplicate.Currency != keep.Currency || duplicate.Amount != keep.Amount {
+			return nil, ErrMergeMismatch
+		}
+
+		for _, tag := range duplicate.Tags {
+			if !containsTag(merged.Tags, tag.ID) {
+				merged.Tags = append(merged.Tags, tag)
+			}
+		}
+		if merged.PayeeID == nil {
+			merged.PayeeID = duplicate.PayeeID
+		}
+		if merged.OriginalTransactionID == nil {
+			merged.OriginalTransactionID = duplicate.OriginalTransactionID
+		}
+		merged.Reimbursable = merged.Reimbursable || duplicate.Reimbursable
+
+		if _, err := tx.Exec("UPDATE transaction_attachments SET transaction_id = ? WHERE transaction_id = ?", keepID, id); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("UPDATE transactions SET original_transaction_id = ? WHERE original_transaction_id = ? AND user_id = ?", keepID, id, userID); err != nil {
+			return nil, err
+		}
+		if _, err := deleteTransaction(tx, id, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Each duplicate may have been refunded in full, so together they may pay back too much
+	var refunded models.Money
+	err = tx.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM transactions WHERE original_transaction_id = ? AND user_id = ? AND deleted_at IS NULL",
+		keepID, userID).Scan(&refunded)
+	if err != nil {
+		return nil, err
+	}
+	if refunded > keep.Amount {
+		return nil, fmt.Errorf("%w: %s refunded of %s", ErrMergeOverRefunded, refunded, keep.Amount)
+	}
+
+	if err := updateTransaction(tx, &merged, userID); err != nil {
+		return nil, err
+	}
+	result, err := getTransactionByID(tx, keepID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return result, tx.Commit()
+}
+
+// containsTag reports whether tags contains the tag with the given ID
+func containsTag(tags []models.Tag, id int64) bool {
+	for _, tag := range tags {
+		if tag.ID == id {
+			return true
+		}
+	}
+	return false
+}
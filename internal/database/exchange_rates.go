@@ -0,0 +1,163 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"mini-money/internal/models"
+)
+
+// ErrMissingExchangeRate is returned when an amount can't be converted to the base currency
+var ErrMissingExchangeRate = errors.New("missing exchange rate")
+
+// currencyConverter converts amounts into a user's base currency, caching rate lookups
+type currencyConverter struct {
+	userID       int64
+	baseCurrency string
+	rates        map[string]float64
+}
+
+// newCurrencyConverter creates a converter into the base currency of a user
+func newCurrencyConverter(userID int64) (*currencyConverter, error) {
+	baseCurrency, err := GetUserBaseCurrency(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &currencyConverter{
+		userID:       userID,
+		baseCurrency: baseCurrency,
+		rates:        make(map[string]float64),
+	}, nil
+}
+
+// convert converts an amount in currency on date (YYYY-MM-DD) into the base currency
+func (c *currencyConverter) convert(amount models.Money, currency, date string) (models.Money, error) {
+	if currency == c.baseCurrency || amount == 0 {
+		return amount, nil
+	}
+
+	key := currency + "|" + date
+	rate, ok := c.rates[key]
+	if !ok {
+		var err error
+		rate, err = GetExchangeRate(c.userID, currency, c.baseCurrency, date)
+		if err != nil {
+			return 0, err
+		}
+		c.rates[key] = rate
+	}
+	return amount.Convert(rate), nil
+}
+
+// tryConvert converts like convert, but reports a missing rate by returning false instead of an error
+func (c *currencyConverter) tryConvert(amount models.Money, currency, date string) (models.Money, bool, error) {
+	converted, err := c.convert(amount, currency, date)
+	if errors.Is(err, ErrMissingExchangeRate) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return converted, true, nil
+}
+
+// GetExchangeRate finds the most recent rate on or before date to convert from one currency into another.
+// The user's own rates take precedence over shared ones, even newer shared ones, and inverse rates are
+// used when no direct rate exists.
+func GetExchangeRate(userID int64, from, to, date string) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+
+	var rate float64
+	err := db.QueryRow(`
+		SELECT rate FROM (
+			SELECT rate, date, user_id, 0 AS inverted
+			FROM exchange_rates
+			WHERE user_id IN (0, ?) AND from_currency = ? AND to_currency = ? AND date <= ?
+			UNION ALL
+			SELECT 1.0 / rate, date, user_id, 1 AS inverted
+			FROM exchange_rates
+			WHERE user_id IN (0, ?) AND from_currency = ? AND to_currency = ? AND date <= ?
+		)
+		ORDER BY user_id DESC, date DESC, inverted ASC
+		LIMIT 1
+	`, userID, from, to, date, userID, to, from, date).Scan(&rate)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("%w: %s to %s on %s", ErrMissingExchangeRate, from, to, date)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return rate, nil
+}
+
+// GetExchangeRates retrieves the rates visible to a user: their own and the shared ones
+func GetExchangeRates(userID int64) ([]models.ExchangeRate, error) {
+	rows, err := db.Query(`
+		SELECT id, user_id, from_currency, to_currency, rate, date, source, created_at, updated_at
+		FROM exchange_rates
+		WHERE user_id IN (0, ?)
+		ORDER BY date DESC, from_currency, to_currency, user_id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []models.ExchangeRate{}
+	for rows.Next() {
+		var r models.ExchangeRate
+		if err := rows.Scan(&r.ID, &r.UserID, &r.FromCurrency, &r.ToCurrency, &r.Rate, &r.Date, &r.Source, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rates = append(rates, r)
+	}
+	return rates, nil
+}
+
+// UpsertExchangeRates creates or replaces exchange rates in a single transaction.
+// A rate is identified by its owner, currency pair and date. The stored ID and
+// timestamps are written back into rates.
+func UpsertExchangeRates(rates []models.ExchangeRate) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO exchange_rates (user_id, from_currency, to_currency, rate, date, source, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id, from_currency, to_currency, date)
+		DO UPDATE SET rate = excluded.rate, source = excluded.source, updated_at = excluded.updated_at
+		RETURNING id, created_at, updated_at
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	for i := range rates {
+		r := &rates[i]
+		err := stmt.QueryRow(r.UserID, r.FromCurrency, r.ToCurrency, r.Rate, r.Date, r.Source, now, now).
+			Scan(&r.ID, &r.CreatedAt, &r.UpdatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteExchangeRate deletes one of the user's own exchange rates
+func DeleteExchangeRate(id, userID int64) (int64, error) {
+	result, err := db.Exec("DELETE FROM exchange_rates WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
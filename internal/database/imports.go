@@ -0,0 +1,21 @@
+package database
+
+// GetImportedIDs retrieves the external IDs of the transactions a user imported from a source,
+// including those in the trash, so that importing a file again skips them
+func GetImportedIDs(userID int64, source string) (map[string]bool, error) {
+	rows, err := db.Query("SELECT external_id FROM transactions WHERE user_id = ? AND external_source = ? AND external_id != ''", userID, source)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
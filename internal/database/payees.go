@@ -0,0 +1,372 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"mini-money/internal/models"
+)
+
+var (
+	// ErrPayeeNotFound is returned when a payee doesn't exist for the user
+	ErrPayeeNotFound = errors.New("payee not found")
+	// ErrPayeeExists is returned when the user already has a payee with the same name
+	ErrPayeeExists = errors.New("a payee with this name already exists")
+)
+
+// payeeMatchOrder ranks the alias match types; more specific rules win
+var payeeMatchOrder = map[string]int{"exact": 0, "prefix": 1, "contains": 2}
+
+// payeeRule is a normalized alias of a payee
+type payeeRule struct {
+	payeeID   int64
+	pattern   string
+	matchType string
+}
+
+// normalizePayeeText folds text for alias matching: full-width characters become
+// their ASCII forms, letters are lower-cased and runs of spaces collapse into one
+func normalizePayeeText(s string) string {
+	folded := strings.Map(func(r rune) rune {
+		switch {
+		case r == '　':
+			return ' '
+		case r >= '！' && r <= '～':
+			return r - 0xFEE0
+		}
+		return r
+	}, s)
+	return strings.Join(strings.Fields(strings.ToLower(folded)), " ")
+}
+
+// matches reports whether a normalized description matches the rule
+func (r payeeRule) matches(description string) bool {
+	switch r.matchType {
+	case "exact":
+		return description == r.pattern
+	case "prefix":
+		return strings.HasPrefix(description, r.pattern)
+	default:
+		return strings.Contains(description, r.pattern)
+	}
+}
+
+// loadPayeeRules retrieves the alias rules of a user, most specific first.
+// A payee's own name always matches exactly.
+func loadPayeeRules(q queryer, userID int64) ([]payeeRule, error) {
+	rows, err := q.Query(`
+		SELECT p.id, p.name, 'exact' FROM payees p WHERE p.user_id = ?
+		UNION ALL
+		SELECT a.payee_id, a.pattern, a.match_type
+		FROM payee_aliases a
+		JOIN payees p ON p.id = a.payee_id
+		WHERE p.user_id = ?
+	`, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []payeeRule
+	for rows.Next() {
+		var r payeeRule
+		if err := rows.Scan(&r.payeeID, &r.pattern, &r.matchType); err != nil {
+			return nil, err
+		}
+		r.pattern = normalizePayeeText(r.pattern)
+		if r.pattern != "" {
+			rules = append(rules, r)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].matchType != rules[j].matchType {
+			return payeeMatchOrder[rules[i].matchType] < payeeMatchOrder[rules[j].matchType]
+		}
+		if len(rules[i].pattern) != len(rules[j].pattern) {
+			return len(rules[i].pattern) > len(rules[j].pattern)
+		}
+		return rules[i].payeeID < rules[j].payeeID
+	})
+	return rules, nil
+}
+
+// matchPayeeRules returns the payee of the first rule matching the description, or nil
+func matchPayeeRules(rules []payeeRule, description string) *int64 {
+	description = normalizePayeeText(description)
+	if description == "" {
+		return nil
+	}
+	for _, r := range rules {
+		if r.matches(description) {
+			id := r.payeeID
+			return &id
+		}
+	}
+	return nil
+}
+
+// MatchPayee finds the payee whose alias rules match a transaction description.
+// It returns nil when no rule matches.
+func MatchPayee(userID int64, description string) (*int64, error) {
+	rules, err := loadPayeeRules(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	return matchPayeeRules(rules, description), nil
+}
+
+// ApplyPayeeRules assigns payees to the user's transactions by their descriptions.
+// Transactions that already have a payee are only reassigned when overwrite is set;
+// they are never unassigned. It returns the number of transactions that changed.
+func ApplyPayeeRules(userID int64, overwrite bool) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rules, err := loadPayeeRules(tx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	query := "SELECT id, description, payee_id FROM transactions WHERE user_id = ? AND deleted_at IS NULL"
+	if !overwrite {
+		query += " AND payee_id IS NULL"
+	}
+	rows, err := tx.Query(query, userID)
+	if err != nil {
+		return 0, err
+	}
+	type assignment struct {
+		id      int64
+		payeeID int64
+	}
+	var assignments []assignment
+	for rows.Next() {
+		var id int64
+		var description string
+		var current *int64
+		if err := rows.Scan(&id, &description, &current); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if matched := matchPayeeRules(rules, description); matched != nil && !int64PtrEqual(matched, current) {
+			assignments = append(assignments, assignment{id: id, payeeID: *matched})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, a := range assignments {
+		if _, err := tx.Exec("UPDATE transactions SET payee_id = ? WHERE id = ?", a.payeeID, a.id); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(assignments)), tx.Commit()
+}
+
+// GetPayees retrieves all payees of a user with their aliases, ordered by name
+func GetPayees(userID int64) ([]models.Payee, error) {
+	rows, err := db.Query("SELECT id, user_id, name, created_at, updated_at FROM payees WHERE user_id = ? ORDER BY name", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payees := []models.Payee{}
+	index := make(map[int64]int)
+	for rows.Next() {
+		p := models.Payee{Aliases: []models.PayeeAlias{}}
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Name, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		index[p.ID] = len(payees)
+		payees = append(payees, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	aliasRows, err := db.Query(`
+		SELECT a.id, a.payee_id, a.pattern, a.match_type
+		FROM payee_aliases a
+		JOIN payees p ON p.id = a.payee_id
+		WHERE p.user_id = ?
+		ORDER BY a.id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer aliasRows.Close()
+
+	for aliasRows.Next() {
+		var alias models.PayeeAlias
+		var payeeID int64
+		if err := aliasRows.Scan(&alias.ID, &payeeID, &alias.Pattern, &alias.MatchType); err != nil {
+			return nil, err
+		}
+		if i, ok := index[payeeID]; ok {
+			payees[i].Aliases = append(payees[i].Aliases, alias)
+		}
+	}
+	return payees, aliasRows.Err()
+}
+
+// GetPayeeByID retrieves a payee of a user without its aliases
+func GetPayeeByID(id, userID int64) (*models.Payee, error) {
+	var p models.Payee
+	err := db.QueryRow("SELECT id, user_id, name, created_at, updated_at FROM payees WHERE id = ? AND user_id = ?", id, userID).
+		Scan(&p.ID, &p.UserID, &p.Name, &p.CreatedAt, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrPayeeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// payeeNameTaken reports whether the user has another payee with the given name
+func payeeNameTaken(q queryer, userID int64, name string, exceptID int64) (bool, error) {
+	var count int
+	err := q.QueryRow("SELECT COUNT(*) FROM payees WHERE user_id = ? AND name = ? AND id != ?", userID, name, exceptID).Scan(&count)
+	return count > 0, err
+}
+
+// replacePayeeAliases replaces the aliases of a payee and fills in their IDs
+func replacePayeeAliases(q queryer, payeeID int64, aliases []models.PayeeAlias) error {
+	if _, err := q.Exec("DELETE FROM payee_aliases WHERE payee_id = ?", payeeID); err != nil {
+		return err
+	}
+	for i := range aliases {
+		a := &aliases[i]
+		if a.MatchType == "" {
+			a.MatchType = "contains"
+		}
+		res, err := q.Exec("INSERT INTO payee_aliases (payee_id, pattern, match_type) VALUES (?, ?, ?)", payeeID, a.Pattern, a.MatchType)
+		if err != nil {
+			return err
+		}
+		if a.ID, err = res.LastInsertId(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreatePayee creates a new payee with its aliases
+func CreatePayee(p *models.Payee) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	taken, err := payeeNameTaken(tx, p.UserID, p.Name, 0)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrPayeeExists
+	}
+
+	now := time.Now()
+	res, err := tx.Exec("INSERT INTO payees (user_id, name, created_at, updated_at) VALUES (?, ?, ?, ?)", p.UserID, p.Name, now, now)
+	if err != nil {
+		return err
+	}
+	if p.ID, err = res.LastInsertId(); err != nil {
+		return err
+	}
+	if err := replacePayeeAliases(tx, p.ID, p.Aliases); err != nil {
+		return err
+	}
+	p.CreatedAt = now
+	p.UpdatedAt = now
+	return tx.Commit()
+}
+
+// UpdatePayee renames a payee and replaces its aliases
+func UpdatePayee(p *models.Payee) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	taken, err := payeeNameTaken(tx, p.UserID, p.Name, p.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrPayeeExists
+	}
+
+	err = tx.QueryRow(`
+		UPDATE payees SET name = ?, updated_at = ?
+		WHERE id = ? AND user_id = ?
+		RETURNING created_at, updated_at
+	`, p.Name, time.Now(), p.ID, p.UserID).Scan(&p.CreatedAt, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrPayeeNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := replacePayeeAliases(tx, p.ID, p.Aliases); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeletePayee deletes a payee with its aliases and unlinks its transactions
+func DeletePayee(id, userID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM payees WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrPayeeNotFound
+	}
+
+	if _, err := tx.Exec("DELETE FROM payee_aliases WHERE payee_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE transactions SET payee_id = NULL WHERE payee_id = ? AND user_id = ?", id, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetPayeeBreakdownForPeriod gets the per-payee totals of a transaction type for a period.
+// Transactions without a payee are left out.
+func GetPayeeBreakdownForPeriod(userID int64, transType string, start, end time.Time, total models.Money) ([]models.PayeeStat, error) {
+	totals, err := getNamedBreakdown(userID, transType, start, end, total, "JOIN payees p ON p.id = t.payee_id", "p.id", "p.name")
+	if err != nil {
+		return nil, err
+	}
+
+	breakdown := make([]models.PayeeStat, len(totals))
+	for i, t := range totals {
+		breakdown[i] = models.PayeeStat{PayeeID: t.id, Name: t.name, Amount: t.amount, Count: t.count, Percentage: t.percentage}
+	}
+	return breakdown, nil
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"mini-money/internal/models"
+)
+
+// netTypeSQL and netAmountSQL give the type and amount a transaction t counts with in statistics:
+// a refund or reimbursement is an expense with a negative amount, so it nets against what it pays back
+const (
+	netTypeSQL   = "CASE WHEN t.original_transaction_id IS NULL THEN t.type ELSE 'expense' END"
+	netAmountSQL = "CASE WHEN t.original_transaction_id IS NULL THEN t.amount ELSE -t.amount END"
+)
+
+// GetRefundedAmount sums the refunds and reimbursements linked to an expense,
+// leaving out the transaction excludeID so it can be re-validated while it is edited
+func GetRefundedAmount(originalID, userID, excludeID int64) (models.Money, error) {
+	return getRefundedAmount(db, originalID, userID, excludeID)
+}
+
+// getRefundedAmount sums the refunds of an expense using the given queryer
+func getRefundedAmount(q queryer, originalID, userID, excludeID int64) (models.Money, error) {
+	var total models.Money
+	err := q.QueryRow(`
+		SELECT COALESCE(SUM(amount), 0)
+		FROM transactions
+		WHERE original_transaction_id = ? AND user_id = ? AND id != ? AND deleted_at IS NULL
+	`, originalID, userID, excludeID).Scan(&total)
+	return total, err
+}
+
+// GetOutstandingReimbursements retrieves the reimbursable expenses of a user that haven't been
+// fully paid back yet, oldest first. The total is converted to the user's base currency.
+func GetOutstandingReimbursements(userID int64) (models.OutstandingReimbursements, error) {
+	result := models.OutstandingReimbursements{Items: []models.OutstandingReimbursement{}}
+
+	converter, err := newCurrencyConverter(userID)
+	if err != nil {
+		return result, err
+	}
+	result.Currency = converter.baseCurrency
+
+	reimbursedSQL := "(SELECT COALESCE(SUM(r.amount), 0) FROM transactions r WHERE r.original_transaction_id = t.id AND r.deleted_at IS NULL)"
+	rows, err := db.Query(`
+		SELECT `+transactionColumns+`, `+reimbursedSQL+`, local_date
+		FROM transactions t
+		WHERE user_id = ? AND type = 'expense' AND reimbursable = 1 AND deleted_at IS NULL AND `+reimbursedSQL+` < amount
+		ORDER BY date, id
+	`, userID)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	// Outstanding amounts are converted with the rate of the day of the expense
+	var days []string
+	for rows.Next() {
+		var reimbursed models.Money
+		var day string
+		t, err := scanTransaction(rows, &reimbursed, &day)
+		if err != nil {
+			return result, err
+		}
+		days = append(days, day)
+		result.Items = append(result.Items, models.OutstandingReimbursement{
+			Transaction: t,
+			Reimbursed:  reimbursed,
+			Outstanding: t.Amount - reimbursed,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	transactions := make([]models.Transaction, len(result.Items))
+	for i, item := range result.Items {
+		transactions[i] = item.Transaction
+	}
+	if err := loadTransactionDetails(db, transactions); err != nil {
+		return result, err
+	}
+	for i := range result.Items {
+		item := &result.Items[i]
+		item.Transaction = transactions[i]
+
+		converted, err := converter.convert(item.Outstanding, item.Transaction.Currency, days[i])
+		if err != nil {
+			return result, err
+		}
+		result.Total += converted
+	}
+	return result, nil
+}
@@ -0,0 +1,257 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// isCJK reports whether r is written without spaces between words (Chinese, Japanese, Korean)
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// segmentText puts spaces around CJK characters so the FTS5 unicode61 tokenizer indexes
+// them one character at a time. Searching for a word then becomes a phrase query over
+// consecutive characters, which matches it anywhere in a sentence.
+func segmentText(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) * 2)
+	for _, r := range s {
+		if isCJK(r) {
+			b.WriteRune(' ')
+			b.WriteRune(r)
+			b.WriteRune(' ')
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// searchTerm is a word or a quoted phrase of a search
+type searchTerm struct {
+	text   string
+	phrase bool
+}
+
+// splitSearchTerms splits a search into whitespace-separated words and "quoted phrases".
+// An unterminated quote runs to the end of the search.
+func splitSearchTerms(search string) []searchTerm {
+	var terms []searchTerm
+	var current strings.Builder
+	inPhrase := false
+
+	flush := func() {
+		if current.Len() > 0 {
+			terms = append(terms, searchTerm{text: current.String(), phrase: inPhrase})
+			current.Reset()
+		}
+	}
+
+	for _, r := range search {
+		switch {
+		case r == '"':
+			flush()
+			inPhrase = !inPhrase
+		case unicode.IsSpace(r) && !inPhrase:
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return terms
+}
+
+// ftsQuery converts a user search into an FTS5 MATCH expression. Every term must match:
+// words match as prefixes ("star" finds "Starbucks") and quoted text as an exact phrase.
+// It returns an empty string when the search contains nothing searchable.
+func ftsQuery(search string) string {
+	var parts []string
+	for _, term := range splitSearchTerms(search) {
+		hasToken := false
+		for _, r := range term.text {
+			if unicode.IsLetter(r) || unicode.IsNumber(r) {
+				hasToken = true
+				break
+			}
+		}
+		if !hasToken {
+			continue
+		}
+
+		text := strings.Join(strings.Fields(segmentText(term.text)), " ")
+		part := `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+
+		// A CJK word is already matched anywhere by its character phrase
+		runes := []rune(text)
+		if !term.phrase && !isCJK(runes[len(runes)-1]) {
+			part += "*"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+// ftsCategoriesSQL lists the category keys and display names of a transaction t and its splits
+const ftsCategoriesSQL = `
+	t.category_key || ' ' ||
+	COALESCE((SELECT c.name FROM transaction_categories c
+		WHERE c.user_id = t.user_id AND c.key = t.category_key AND c.type = t.type), '') || ' ' ||
+	COALESCE((SELECT group_concat(s.category_key || ' ' || COALESCE(c.name, ''), ' ')
+		FROM transaction_splits s
+		LEFT JOIN transaction_categories c
+			ON c.user_id = t.user_id AND c.key = s.category_key AND c.type = t.type
+		WHERE s.transaction_id = t.id), '')`
+
+// ftsQueueSQL returns a statement that queues the transactions t matching condition for re-indexing
+func ftsQueueSQL(condition string) string {
+	return "INSERT OR IGNORE INTO transactions_fts_queue (transaction_id) SELECT t.id FROM transactions t WHERE " + condition + ";"
+}
+
+// ftsTriggers queue the transactions whose indexed text may have changed. They are plain SQL, so
+// the database can also be written to by tools that don't have the app's functions; the queue is
+// indexed by updateSearchIndex.
+var ftsTriggers = []struct {
+	Name string
+	When string
+	Body string
+}{
+	{"transactions_fts_insert", "AFTER INSERT ON transactions", ftsQueueSQL("t.id = NEW.id")},
+	{"transactions_fts_update", "AFTER UPDATE ON transactions", ftsQueueSQL("t.id = NEW.id")},
+	{"transactions_fts_delete", "AFTER DELETE ON transactions", "INSERT OR IGNORE INTO transactions_fts_queue (transaction_id) VALUES (OLD.id);"},
+	{"transaction_splits_fts_insert", "AFTER INSERT ON transaction_splits", ftsQueueSQL("t.id = NEW.transaction_id")},
+	{"transaction_splits_fts_update", "AFTER UPDATE ON transaction_splits", ftsQueueSQL("t.id = NEW.transaction_id")},
+	{"transaction_splits_fts_delete", "AFTER DELETE ON transaction_splits", ftsQueueSQL("t.id = OLD.transaction_id")},
+	{"transaction_tags_fts_insert", "AFTER INSERT ON transaction_tags", ftsQueueSQL("t.id = NEW.transaction_id")},
+	{"transaction_tags_fts_delete", "AFTER DELETE ON transaction_tags", ftsQueueSQL("t.id = OLD.transaction_id")},
+	{"tags_fts_update", "AFTER UPDATE OF name ON tags",
+		ftsQueueSQL("t.id IN (SELECT transaction_id FROM transaction_tags WHERE tag_id = NEW.id)")},
+	{"transaction_categories_fts_insert", "AFTER INSERT ON transaction_categories", ftsQueueSQL(ftsCategoryCondition("NEW"))},
+	{"transaction_categories_fts_update", "AFTER UPDATE OF name ON transaction_categories", ftsQueueSQL(ftsCategoryCondition("NEW"))},
+	{"transaction_categories_fts_delete", "AFTER DELETE ON transaction_categories", ftsQueueSQL(ftsCategoryCondition("OLD"))},
+}
+
+// ftsCategoryCondition selects the transactions using the category in row (NEW or OLD)
+func ftsCategoryCondition(row string) string {
+	return "t.user_id = " + row + ".user_id AND (t.category_key = " + row + ".key OR t.id IN " +
+		"(SELECT transaction_id FROM transaction_splits WHERE category_key = " + row + ".key))"
+}
+
+// createSearchIndex creates the full-text index over transactions, its queue and the triggers
+// filling the queue. The triggers are recreated on every start so changes to them take effect,
+// and the index is rebuilt when it is out of step with the transactions table.
+func createSearchIndex() error {
+	_, err := db.Exec(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS transactions_fts USING fts5(
+			description, categories, tags, notes,
+			tokenize = 'unicode61 remove_diacritics 2'
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS transactions_fts_queue (transaction_id INTEGER NOT NULL PRIMARY KEY)"); err != nil {
+		return err
+	}
+
+	for _, trigger := range ftsTriggers {
+		if _, err := db.Exec("DROP TRIGGER IF EXISTS " + trigger.Name); err != nil {
+			return err
+		}
+		if _, err := db.Exec("CREATE TRIGGER " + trigger.Name + " " + trigger.When + " BEGIN " + trigger.Body + " END"); err != nil {
+			return fmt.Errorf("creating trigger %s: %w", trigger.Name, err)
+		}
+	}
+
+	var indexed, total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM transactions_fts").Scan(&indexed); err != nil {
+		return err
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM transactions").Scan(&total); err != nil {
+		return err
+	}
+	if indexed != total {
+		log.Printf("Rebuilding transaction search index (%d of %d indexed)", indexed, total)
+		if _, err := db.Exec("DELETE FROM transactions_fts"); err != nil {
+			return err
+		}
+		if _, err := db.Exec(ftsQueueSQL("1 = 1")); err != nil {
+			return err
+		}
+	}
+	return updateSearchIndex()
+}
+
+// searchIndexMu keeps searches from indexing the queue at the same time
+var searchIndexMu sync.Mutex
+
+// updateSearchIndex indexes the transactions in the queue, segmenting their text with segmentText.
+// It runs before every search, so the index is up to date whichever way the data was written.
+func updateSearchIndex() error {
+	searchIndexMu.Lock()
+	defer searchIndexMu.Unlock()
+
+	var queued bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM transactions_fts_queue)").Scan(&queued); err != nil {
+		return err
+	}
+	if !queued {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Writing first takes the database's write lock, so nothing is queued while the queue is read
+	if _, err := tx.Exec("DELETE FROM transactions_fts WHERE rowid IN (SELECT transaction_id FROM transactions_fts_queue)"); err != nil {
+		return err
+	}
+	rows, err := tx.Query(`
+		SELECT t.id, t.description, ` + ftsCategoriesSQL + `,
+			COALESCE((SELECT group_concat(tg.name, ' ') FROM transaction_tags tt
+				JOIN tags tg ON tg.id = tt.tag_id WHERE tt.transaction_id = t.id), ''),
+			COALESCE((SELECT group_concat(s.note, ' ') FROM transaction_splits s
+				WHERE s.transaction_id = t.id), '')
+		FROM transactions_fts_queue q
+		JOIN transactions t ON t.id = q.transaction_id
+	`)
+	if err != nil {
+		return err
+	}
+	type document struct {
+		id                                   int64
+		description, categories, tags, notes string
+	}
+	var documents []document
+	for rows.Next() {
+		var d document
+		if err := rows.Scan(&d.id, &d.description, &d.categories, &d.tags, &d.notes); err != nil {
+			rows.Close()
+			return err
+		}
+		documents = append(documents, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, d := range documents {
+		_, err := tx.Exec("INSERT INTO transactions_fts (rowid, description, categories, tags, notes) VALUES (?, ?, ?, ?, ?)",
+			d.id, segmentText(d.description), segmentText(d.categories), segmentText(d.tags), segmentText(d.notes))
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM transactions_fts_queue"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
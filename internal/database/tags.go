@@ -0,0 +1,230 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"mini-money/internal/models"
+)
+
+// ErrTagNotFound is returned when a tag doesn't exist or belongs to another user
+var ErrTagNotFound = errors.New("tag not found")
+
+// ErrTagExists is returned when the user already has a tag with the same name
+var ErrTagExists = errors.New("tag already exists")
+
+// loadTransactionDetails attaches the split lines and tags of each transaction in place
+func loadTransactionDetails(q queryer, transactions []models.Transaction) error {
+	if err := loadSplits(q, transactions); err != nil {
+		return err
+	}
+	return loadTags(q, transactions)
+}
+
+// loadTags attaches the tags of each transaction in place
+func loadTags(q queryer, transactions []models.Transaction) error {
+	index := make(map[int64]int, len(transactions))
+	for i := range transactions {
+		index[transactions[i].ID] = i
+		transactions[i].Tags = nil
+	}
+
+	for start := 0; start < len(transactions); start += splitBatchSize {
+		end := start + splitBatchSize
+		if end > len(transactions) {
+			end = len(transactions)
+		}
+
+		args := make([]interface{}, 0, end-start)
+		for _, t := range transactions[start:end] {
+			args = append(args, t.ID)
+		}
+
+		rows, err := q.Query(`
+			SELECT tt.transaction_id, t.id, t.user_id, t.name, t.color, t.created_at, t.updated_at
+			FROM transaction_tags tt
+			JOIN tags t ON t.id = tt.tag_id
+			WHERE tt.transaction_id IN (`+placeholders(len(args))+`)
+			ORDER BY t.name, t.id
+		`, args...)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var transactionID int64
+			var tag models.Tag
+			if err := rows.Scan(&transactionID, &tag.ID, &tag.UserID, &tag.Name, &tag.Color, &tag.CreatedAt, &tag.UpdatedAt); err != nil {
+				rows.Close()
+				return err
+			}
+			i := index[transactionID]
+			transactions[i].Tags = append(transactions[i].Tags, tag)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceTags links a transaction to exactly the given tags. Only the tag IDs are used;
+// the remaining fields are filled in from the database.
+func replaceTags(q queryer, transactionID, userID int64, tags []models.Tag) error {
+	if _, err := q.Exec("DELETE FROM transaction_tags WHERE transaction_id = ?", transactionID); err != nil {
+		return err
+	}
+
+	for i := range tags {
+		tag := &tags[i]
+		err := q.QueryRow("SELECT user_id, name, color, created_at, updated_at FROM tags WHERE id = ? AND user_id = ?", tag.ID, userID).
+			Scan(&tag.UserID, &tag.Name, &tag.Color, &tag.CreatedAt, &tag.UpdatedAt)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: %d", ErrTagNotFound, tag.ID)
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := q.Exec("INSERT OR IGNORE INTO transaction_tags (transaction_id, tag_id) VALUES (?, ?)", transactionID, tag.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tagsEqual reports whether two sets of tags contain the same tag IDs, ignoring order
+func tagsEqual(a, b []models.Tag) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	ids := make(map[int64]int, len(a))
+	for _, t := range a {
+		ids[t.ID]++
+	}
+	for _, t := range b {
+		if ids[t.ID] == 0 {
+			return false
+		}
+		ids[t.ID]--
+	}
+	return true
+}
+
+// GetTags retrieves all tags of a user ordered by name
+func GetTags(userID int64) ([]models.Tag, error) {
+	rows, err := db.Query("SELECT id, user_id, name, color, created_at, updated_at FROM tags WHERE user_id = ? ORDER BY name", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		var tag models.Tag
+		if err := rows.Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.Color, &tag.CreatedAt, &tag.UpdatedAt); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// tagNameTaken reports whether the user has another tag with the given name
+func tagNameTaken(userID int64, name string, exceptID int64) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM tags WHERE user_id = ? AND name = ? AND id != ?", userID, name, exceptID).Scan(&count)
+	return count > 0, err
+}
+
+// CreateTag creates a new tag
+func CreateTag(tag *models.Tag) error {
+	taken, err := tagNameTaken(tag.UserID, tag.Name, 0)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrTagExists
+	}
+
+	now := time.Now()
+	res, err := db.Exec("INSERT INTO tags (user_id, name, color, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+		tag.UserID, tag.Name, tag.Color, now, now)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	tag.ID = id
+	tag.CreatedAt = now
+	tag.UpdatedAt = now
+	return nil
+}
+
+// UpdateTag renames or recolors a tag
+func UpdateTag(tag *models.Tag) error {
+	taken, err := tagNameTaken(tag.UserID, tag.Name, tag.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrTagExists
+	}
+
+	now := time.Now()
+	err = db.QueryRow(`
+		UPDATE tags SET name = ?, color = ?, updated_at = ?
+		WHERE id = ? AND user_id = ?
+		RETURNING created_at, updated_at
+	`, tag.Name, tag.Color, now, tag.ID, tag.UserID).Scan(&tag.CreatedAt, &tag.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrTagNotFound
+	}
+	return err
+}
+
+// DeleteTag deletes a tag and removes it from all transactions
+func DeleteTag(id, userID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM tags WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrTagNotFound
+	}
+
+	if _, err := tx.Exec("DELETE FROM transaction_tags WHERE tag_id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetTagBreakdownForPeriod gets the per-tag totals of a transaction type for a period.
+// A transaction counts in full towards each of its tags.
+func GetTagBreakdownForPeriod(userID int64, transType string, start, end time.Time, total models.Money) ([]models.TagStat, error) {
+	totals, err := getNamedBreakdown(userID, transType, start, end, total,
+		"JOIN transaction_tags tt ON tt.transaction_id = t.id JOIN tags tg ON tg.id = tt.tag_id", "tg.id", "tg.name")
+	if err != nil {
+		return nil, err
+	}
+
+	breakdown := make([]models.TagStat, len(totals))
+	for i, t := range totals {
+		breakdown[i] = models.TagStat{TagID: t.id, Name: t.name, Amount: t.amount, Percentage: t.percentage}
+	}
+	return breakdown, nil
+}
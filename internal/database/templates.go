@@ -0,0 +1,117 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"mini-money/internal/models"
+)
+
+// ErrTemplateNotFound is returned when a transaction template doesn't exist for the user
+var ErrTemplateNotFound = errors.New("template not found")
+
+// templateColumns lists the template columns in the order expected by scanTemplate
+const templateColumns = "id, user_id, description, amount, currency, type, category_key, asset_id, usage_count, last_used_at, created_at, updated_at"
+
+// scanTemplate scans a row selected with templateColumns into a template
+func scanTemplate(row rowScanner) (models.TransactionTemplate, error) {
+	var t models.TransactionTemplate
+	err := row.Scan(&t.ID, &t.UserID, &t.Description, &t.Amount, &t.Currency, &t.Type, &t.CategoryKey, &t.AssetID,
+		&t.UsageCount, &t.LastUsedAt, &t.CreatedAt, &t.UpdatedAt)
+	return t, err
+}
+
+// GetTemplates retrieves the transaction templates of a user, most used first
+func GetTemplates(userID int64) ([]models.TransactionTemplate, error) {
+	rows, err := db.Query(`
+		SELECT `+templateColumns+`
+		FROM transaction_templates
+		WHERE user_id = ?
+		ORDER BY usage_count DESC, last_used_at DESC, id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []models.TransactionTemplate{}
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// GetTemplateByID retrieves a transaction template of a user
+func GetTemplateByID(id, userID int64) (*models.TransactionTemplate, error) {
+	t, err := scanTemplate(db.QueryRow("SELECT "+templateColumns+" FROM transaction_templates WHERE id = ? AND user_id = ?", id, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CreateTemplate creates a new transaction template
+func CreateTemplate(t *models.TransactionTemplate) error {
+	now := time.Now()
+	res, err := db.Exec(`
+		INSERT INTO transaction_templates (user_id, description, amount, currency, type, category_key, asset_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, t.UserID, t.Description, t.Amount, t.Currency, t.Type, t.CategoryKey, t.AssetID, now, now)
+	if err != nil {
+		return err
+	}
+	if t.ID, err = res.LastInsertId(); err != nil {
+		return err
+	}
+	t.UsageCount = 0
+	t.LastUsedAt = nil
+	t.CreatedAt = now
+	t.UpdatedAt = now
+	return nil
+}
+
+// UpdateTemplate saves the new values of a transaction template; its usage is kept
+func UpdateTemplate(t *models.TransactionTemplate) error {
+	err := db.QueryRow(`
+		UPDATE transaction_templates
+		SET description = ?, amount = ?, currency = ?, type = ?, category_key = ?, asset_id = ?, updated_at = ?
+		WHERE id = ? AND user_id = ?
+		RETURNING usage_count, last_used_at, created_at, updated_at
+	`, t.Description, t.Amount, t.Currency, t.Type, t.CategoryKey, t.AssetID, time.Now(), t.ID, t.UserID).
+		Scan(&t.UsageCount, &t.LastUsedAt, &t.CreatedAt, &t.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrTemplateNotFound
+	}
+	return err
+}
+
+// DeleteTemplate deletes a transaction template; transactions created from it are kept
+func DeleteTemplate(id, userID int64) error {
+	res, err := db.Exec("DELETE FROM transaction_templates WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
+
+// RecordTemplateUse counts a use of a transaction template
+func RecordTemplateUse(id, userID int64) error {
+	_, err := db.Exec(`
+		UPDATE transaction_templates SET usage_count = usage_count + 1, last_used_at = ?
+		WHERE id = ? AND user_id = ?
+	`, time.Now(), id, userID)
+	return err
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"database/sql"
+
+	"mini-money/internal/models"
+)
+
+// TransactionBatch applies several changes to a user's transactions inside one SQL transaction.
+// Nothing is visible to other requests until Commit.
+type TransactionBatch struct {
+	tx     *sql.Tx
+	userID int64
+}
+
+// BeginTransactionBatch starts a batch of changes to the transactions of a user
+func BeginTransactionBatch(userID int64) (*TransactionBatch, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &TransactionBatch{tx: tx, userID: userID}, nil
+}
+
+// Get retrieves one of the user's transactions as seen by the batch
+func (b *TransactionBatch) Get(id int64) (*models.Transaction, error) {
+	return getTransactionByID(b.tx, id, b.userID)
+}
+
+// RefundedAmount sums the refunds of an expense as seen by the batch, leaving out excludeID
+func (b *TransactionBatch) RefundedAmount(originalID, excludeID int64) (models.Money, error) {
+	return getRefundedAmount(b.tx, originalID, b.userID, excludeID)
+}
+
+// Insert adds a transaction and sets its ID; the currency must already be set
+func (b *TransactionBatch) Insert(t *models.Transaction) error {
+	t.UserID = b.userID
+	id, err := insertTransaction(b.tx, t)
+	if err != nil {
+		return err
+	}
+	t.ID = id
+	return nil
+}
+
+// Update saves the changes to a transaction and records a revision
+func (b *TransactionBatch) Update(t *models.Transaction) error {
+	t.UserID = b.userID
+	return updateTransaction(b.tx, t, b.userID)
+}
+
+// Delete moves a transaction to the trash and reports whether it existed
+func (b *TransactionBatch) Delete(id int64) (bool, error) {
+	n, err := deleteTransaction(b.tx, id, b.userID)
+	return n > 0, err
+}
+
+// SaveAssetRecord saves the balance of an asset on a day, replacing the record of that day.
+// The asset must belong to the user of the batch.
+func (b *TransactionBatch) SaveAssetRecord(record *models.AssetRecord) error {
+	return createAssetRecord(b.tx, record)
+}
+
+// Savepoint marks a point the batch can roll back to without abandoning earlier changes
+func (b *TransactionBatch) Savepoint() error {
+	_, err := b.tx.Exec("SAVEPOINT batch_item")
+	return err
+}
+
+// ReleaseSavepoint keeps the changes made since the last Savepoint
+func (b *TransactionBatch) ReleaseSavepoint() error {
+	_, err := b.tx.Exec("RELEASE batch_item")
+	return err
+}
+
+// RollbackToSavepoint discards the changes made since the last Savepoint
+func (b *TransactionBatch) RollbackToSavepoint() error {
+	if _, err := b.tx.Exec("ROLLBACK TO batch_item"); err != nil {
+		return err
+	}
+	return b.ReleaseSavepoint()
+}
+
+// Commit makes all changes of the batch permanent
+func (b *TransactionBatch) Commit() error {
+	return b.tx.Commit()
+}
+
+// Rollback discards all changes of the batch; it is a no-op after Commit
+func (b *TransactionBatch) Rollback() error {
+	err := b.tx.Rollback()
+	if err == sql.ErrTxDone {
+		return nil
+	}
+	return err
+}
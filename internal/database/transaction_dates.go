@@ -0,0 +1,122 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"mini-money/internal/models"
+)
+
+// transactionDateLayout is how transaction dates are stored: UTC with a fixed width,
+// so comparing and sorting the text is the same as comparing the times
+const transactionDateLayout = "2006-01-02T15:04:05.000Z"
+
+// localDateLayout is how local_date is stored: the calendar day of a transaction in its owner's timezone
+const localDateLayout = "2006-01-02"
+
+// transactionDate formats a time for the transactions date column and for comparisons against it
+func transactionDate(t time.Time) string {
+	return t.UTC().Format(transactionDateLayout)
+}
+
+// userLocation loads the timezone of a user using the given queryer
+func userLocation(q queryer, userID int64) (*time.Location, error) {
+	var timezone string
+	if err := q.QueryRow("SELECT timezone FROM users WHERE id = ?", userID).Scan(&timezone); err != nil {
+		return nil, err
+	}
+	return models.LoadTimezone(timezone)
+}
+
+// transactionLocalDate returns the calendar day of a transaction date in the timezone of the user
+func transactionLocalDate(q queryer, userID int64, date time.Time) (string, error) {
+	loc, err := userLocation(q, userID)
+	if err != nil {
+		return "", err
+	}
+	return date.In(loc).Format(localDateLayout), nil
+}
+
+// refreshLocalDates recomputes local_date of all transactions of a user, e.g. after the
+// user changed timezone
+func refreshLocalDates(q queryer, userID int64, loc *time.Location) error {
+	_, err := rewriteTransactionDates(q, "user_id = ? AND date IS NOT NULL", []interface{}{userID}, func(int64) (*time.Location, error) {
+		return loc, nil
+	})
+	return err
+}
+
+// migrateTransactionDates rewrites dates stored in an older format, such as Go's default
+// time.Time text, into transactionDateLayout and fills in their local_date
+func migrateTransactionDates() error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	locations := make(map[int64]*time.Location)
+	n, err := rewriteTransactionDates(tx, "local_date = '' AND date IS NOT NULL", nil, func(userID int64) (*time.Location, error) {
+		if loc, ok := locations[userID]; ok {
+			return loc, nil
+		}
+		loc, err := userLocation(tx, userID)
+		if err == sql.ErrNoRows {
+			loc, err = time.UTC, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		locations[userID] = loc
+		return loc, nil
+	})
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("Migrated the dates of %d transactions", n)
+	}
+	return tx.Commit()
+}
+
+// rewriteTransactionDates stores the date of the transactions matching condition in
+// transactionDateLayout together with its calendar day in the location of the owner.
+// It returns the number of rewritten transactions.
+func rewriteTransactionDates(q queryer, condition string, args []interface{}, location func(userID int64) (*time.Location, error)) (int, error) {
+	type row struct {
+		id, userID int64
+		date       time.Time
+	}
+
+	// The driver parses every format it has written dates in
+	rows, err := q.Query("SELECT id, user_id, date FROM transactions WHERE "+condition, args...)
+	if err != nil {
+		return 0, err
+	}
+	var found []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.userID, &r.date); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		found = append(found, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, r := range found {
+		loc, err := location(r.userID)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := q.Exec("UPDATE transactions SET date = ?, local_date = ? WHERE id = ?",
+			transactionDate(r.date), r.date.In(loc).Format(localDateLayout), r.id); err != nil {
+			return 0, err
+		}
+	}
+	return len(found), nil
+}
@@ -0,0 +1,223 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"mini-money/internal/models"
+)
+
+// ErrInvalidCursor is returned for cursors that are malformed or were issued for another sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// transactionCursor marks the position after the last transaction of a page.
+// It is handed to clients as an opaque base64 string.
+type transactionCursor struct {
+	SortBy    string `json:"s"`
+	Ascending bool   `json:"a,omitempty"`
+	Value     string `json:"v"` // sort column value of the last transaction as stored
+	ID        int64  `json:"id"`
+}
+
+// encode returns the opaque form of the cursor
+func (c transactionCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeTransactionCursor parses an opaque cursor
+func decodeTransactionCursor(s string) (transactionCursor, error) {
+	var c transactionCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// cursorValue formats a sort value scanned from the database for a cursor without losing precision
+func cursorValue(v interface{}) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	default:
+		return ""
+	}
+}
+
+// GetTransactionPage retrieves one page of the transactions matching the filter using keyset
+// pagination, so pages stay stable while transactions are added. The filter's Limit is ignored.
+// An empty cursor starts at the first page.
+func GetTransactionPage(userID int64, f TransactionFilter, pageSize int, cursor string) (*models.TransactionPage, error) {
+	if _, _, err := f.orderBy(); err != nil {
+		return nil, err
+	}
+	if err := f.updateSearchIndex(); err != nil {
+		return nil, err
+	}
+
+	conditions, conditionArgs := f.conditions()
+	page := &models.TransactionPage{}
+
+	// Totals cover every matching transaction, not just this page
+	countArgs := append([]interface{}{userID}, conditionArgs...)
+	if err := db.QueryRow("SELECT COUNT(*) FROM transactions WHERE user_id = ?"+conditions, countArgs...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+	var err error
+	page.Aggregates, err = getSummary(userID, conditions, conditionArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	page.Items, page.NextCursor, err = transactionPage(userID, f, pageSize, cursor)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// EachTransaction calls fn for every transaction matching the filter, in the filter's order and up
+// to its Limit. Transactions are read a page at a time, so exporting many years neither holds them
+// all in memory nor keeps the database locked while they are written out. It stops at the first
+// error returned by fn.
+func EachTransaction(userID int64, f TransactionFilter, fn func(models.Transaction) error) error {
+	if err := f.updateSearchIndex(); err != nil {
+		return err
+	}
+	count := 0
+	cursor := ""
+	for {
+		items, next, err := transactionPage(userID, f, splitBatchSize, cursor)
+		if err != nil {
+			return err
+		}
+		for _, t := range items {
+			if f.Limit > 0 && count == f.Limit {
+				return nil
+			}
+			if err := fn(t); err != nil {
+				return err
+			}
+			count++
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// transactionPage retrieves the transactions of a page and the cursor of the next page, which is
+// empty on the last page
+func transactionPage(userID int64, f TransactionFilter, pageSize int, cursor string) ([]models.Transaction, string, error) {
+	expr, exprArgs, err := f.sortExpr()
+	if err != nil {
+		return nil, "", err
+	}
+	sortBy := f.SortBy
+	if sortBy == "" {
+		sortBy = "date"
+	}
+	orderBy, orderArgs, err := f.orderBy()
+	if err != nil {
+		return nil, "", err
+	}
+	conditions, conditionArgs := f.conditions()
+
+	// Select the sort value as stored so the cursor can resume exactly after it.
+	// Text columns are cast so the driver doesn't parse dates.
+	selectExpr := expr
+	if sortBy == "date" || sortBy == "category" {
+		selectExpr = "CAST(" + expr + " AS TEXT)"
+	}
+	query := "SELECT " + transactionColumns + ", " + selectExpr + " FROM transactions WHERE user_id = ?" + conditions
+	args := append(append([]interface{}{}, exprArgs...), userID)
+	args = append(args, conditionArgs...)
+
+	if cursor != "" {
+		c, err := decodeTransactionCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		if c.SortBy != sortBy || c.Ascending != f.Ascending {
+			return nil, "", ErrInvalidCursor
+		}
+
+		// Amounts are compared as integers, relevance as a real and everything else as stored text
+		var value interface{} = c.Value
+		switch sortBy {
+		case "amount":
+			value, err = strconv.ParseInt(c.Value, 10, 64)
+		case "relevance":
+			value, err = strconv.ParseFloat(c.Value, 64)
+		}
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+
+		op := "<"
+		if f.Ascending {
+			op = ">"
+		}
+		query += " AND (" + expr + " " + op + " ? OR (" + expr + " = ? AND id " + op + " ?))"
+		args = append(args, exprArgs...)
+		args = append(args, value)
+		args = append(args, exprArgs...)
+		args = append(args, value, c.ID)
+	}
+
+	// Fetch one extra row to find out whether there is a next page
+	query += orderBy + " LIMIT ?"
+	args = append(args, orderArgs...)
+	args = append(args, pageSize+1)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	items := []models.Transaction{}
+	var values []string
+	for rows.Next() {
+		var value interface{}
+		t, err := scanTransaction(rows, &value)
+		if err != nil {
+			return nil, "", err
+		}
+		items = append(items, t)
+		values = append(values, cursorValue(value))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	rows.Close()
+
+	next := ""
+	if len(items) > pageSize {
+		items = items[:pageSize]
+		next = transactionCursor{
+			SortBy:    sortBy,
+			Ascending: f.Ascending,
+			Value:     values[pageSize-1],
+			ID:        items[pageSize-1].ID,
+		}.encode()
+	}
+
+	if err := loadTransactionDetails(db, items); err != nil {
+		return nil, "", err
+	}
+	return items, next, nil
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+
+	"mini-money/internal/models"
+)
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// categoryLinesSQL selects one row per amount attributed to a category: the transaction
+// itself when it has no splits, otherwise each of its split lines. Trashed transactions are
+// left out and refunds count as negative expenses of the category of the expense they pay back,
+// spread over its split lines in proportion to their amounts when it is split.
+const categoryLinesSQL = `
+	SELECT t.id AS transaction_id, t.user_id, ` + netTypeSQL + ` AS type, COALESCE(o.category_key, t.category_key) AS category_key,
+		t.currency, t.date, t.local_date, ` + netAmountSQL + ` AS amount
+	FROM transactions t
+	LEFT JOIN transactions o ON o.id = t.original_transaction_id
+	WHERE t.deleted_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM transaction_splits s WHERE s.transaction_id = t.id)
+		AND NOT EXISTS (SELECT 1 FROM transaction_splits s WHERE s.transaction_id = o.id)
+	UNION ALL
+	SELECT t.id, t.user_id, t.type, s.category_key, t.currency, t.date, t.local_date, s.amount
+	FROM transaction_splits s
+	JOIN transactions t ON t.id = s.transaction_id
+	WHERE t.deleted_at IS NULL
+	UNION ALL
+	SELECT id, user_id, 'expense', category_key, currency, date, local_date,
+		-- Shares are rounded down at each running total so that they add up to the refund
+		-(amount * running / original_amount - amount * (running - line_amount) / original_amount)
+	FROM (
+		SELECT t.id, t.user_id, s.category_key, t.currency, t.date, t.local_date, t.amount,
+			s.amount AS line_amount, o.amount AS original_amount,
+			SUM(s.amount) OVER (PARTITION BY t.id ORDER BY s.id) AS running
+		FROM transactions t
+		JOIN transactions o ON o.id = t.original_transaction_id
+		JOIN transaction_splits s ON s.transaction_id = o.id
+		WHERE t.deleted_at IS NULL
+	)
+`
+
+// splitBatchSize limits the number of IDs bound in a single IN (...) clause
+const splitBatchSize = 500
+
+// loadSplits attaches the split lines of each transaction in place
+func loadSplits(q queryer, transactions []models.Transaction) error {
+	index := make(map[int64]int, len(transactions))
+	for i := range transactions {
+		index[transactions[i].ID] = i
+		transactions[i].Splits = nil
+	}
+
+	for start := 0; start < len(transactions); start += splitBatchSize {
+		end := start + splitBatchSize
+		if end > len(transactions) {
+			end = len(transactions)
+		}
+
+		args := make([]interface{}, 0, end-start)
+		for _, t := range transactions[start:end] {
+			args = append(args, t.ID)
+		}
+
+		rows, err := q.Query(`
+			SELECT id, transaction_id, category_key, amount, note
+			FROM transaction_splits
+			WHERE transaction_id IN (`+placeholders(len(args))+`)
+			ORDER BY id
+		`, args...)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var s models.TransactionSplit
+			if err := rows.Scan(&s.ID, &s.TransactionID, &s.CategoryKey, &s.Amount, &s.Note); err != nil {
+				rows.Close()
+				return err
+			}
+			i := index[s.TransactionID]
+			transactions[i].Splits = append(transactions[i].Splits, s)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceSplits removes the split lines of a transaction and inserts the given ones,
+// filling in their IDs
+func replaceSplits(q queryer, transactionID int64, splits []models.TransactionSplit) error {
+	if _, err := q.Exec("DELETE FROM transaction_splits WHERE transaction_id = ?", transactionID); err != nil {
+		return err
+	}
+
+	for i := range splits {
+		res, err := q.Exec(`
+			INSERT INTO transaction_splits (transaction_id, category_key, amount, note)
+			VALUES (?, ?, ?, ?)
+		`, transactionID, splits[i].CategoryKey, splits[i].Amount, splits[i].Note)
+		if err != nil {
+			return err
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		splits[i].ID = id
+		splits[i].TransactionID = transactionID
+	}
+	return nil
+}
+
+// splitsEqual reports whether two sets of split lines have the same content, ignoring IDs
+func splitsEqual(a, b []models.TransactionSplit) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].CategoryKey != b[i].CategoryKey || a[i].Amount != b[i].Amount || a[i].Note != b[i].Note {
+			return false
+		}
+	}
+	return true
+}
+
+// placeholders returns n comma-separated SQL parameter placeholders
+func placeholders(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strings.Repeat("?, ", n-1) + "?"
+}
@@ -0,0 +1,277 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"mini-money/internal/models"
+)
+
+// ErrInvalidTrashKind is returned for a trash kind other than the ones in trashKinds
+var ErrInvalidTrashKind = errors.New("kind must be one of transactions, assets, asset-records or auto-transactions")
+
+// ErrRestoreConflict is returned when an item can't be restored because of what changed while it was
+// in the trash, such as an asset record of a day the asset has a new record for
+var ErrRestoreConflict = errors.New("cannot restore")
+
+// trashTimeLayout is how deleted_at is stored, so it sorts and compares as text
+const trashTimeLayout = "2006-01-02 15:04:05"
+
+// trashTable describes where the rows of a trash kind live and how they belong to a user
+type trashTable struct {
+	table string
+	owner string // condition with one user ID placeholder
+}
+
+// trashKinds lists the kinds of rows that can be trashed, in the order they are purged
+var trashKinds = []string{"transactions", "asset-records", "assets", "auto-transactions"}
+
+var trashTables = map[string]trashTable{
+	"transactions":      {table: "transactions", owner: "user_id = ?"},
+	"assets":            {table: "assets", owner: "user_id = ?"},
+	"asset-records":     {table: "asset_records", owner: "asset_id IN (SELECT id FROM assets WHERE user_id = ?)"},
+	"auto-transactions": {table: "auto_transactions", owner: "user_id = ?"},
+}
+
+// trashTime formats a deletion time for the deleted_at columns
+func trashTime(t time.Time) string {
+	return t.UTC().Format(trashTimeLayout)
+}
+
+// GetTrash retrieves everything the user has deleted but not yet purged, most recently deleted first
+func GetTrash(userID int64) ([]models.TrashItem, error) {
+	rows, err := db.Query(`
+		SELECT 'transactions', id, description, amount, currency, local_date, CAST(deleted_at AS TEXT)
+		FROM transactions
+		WHERE user_id = ? AND deleted_at IS NOT NULL
+		UNION ALL
+		SELECT 'assets', id, name, NULL, currency, '', CAST(deleted_at AS TEXT)
+		FROM assets
+		WHERE user_id = ? AND deleted_at IS NOT NULL
+		UNION ALL
+		SELECT 'asset-records', r.id, a.name, r.amount, a.currency, r.date, CAST(r.deleted_at AS TEXT)
+		FROM asset_records r
+		JOIN assets a ON a.id = r.asset_id
+		WHERE a.user_id = ? AND r.deleted_at IS NOT NULL
+		UNION ALL
+		SELECT 'auto-transactions', id, description, amount, currency, '', CAST(deleted_at AS TEXT)
+		FROM auto_transactions
+		WHERE user_id = ? AND deleted_at IS NOT NULL
+		ORDER BY 7 DESC, 1, 2 DESC
+	`, userID, userID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.TrashItem{}
+	for rows.Next() {
+		var item models.TrashItem
+		var deletedAt string
+		if err := rows.Scan(&item.Kind, &item.ID, &item.Description, &item.Amount, &item.Currency, &item.Date, &deletedAt); err != nil {
+			return nil, err
+		}
+		item.DeletedAt, err = time.Parse(trashTimeLayout, deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// RestoreTrashItem takes a row of the given kind back out of the trash.
+// It returns false when the user has no such row in the trash.
+func RestoreTrashItem(kind string, id, userID int64) (bool, error) {
+	t, ok := trashTables[kind]
+	if !ok {
+		return false, ErrInvalidTrashKind
+	}
+
+	if kind == "asset-records" {
+		var taken bool
+		err := db.QueryRow(`
+			SELECT EXISTS (
+				SELECT 1 FROM asset_records trashed
+				JOIN asset_records r ON r.asset_id = trashed.asset_id AND r.date = trashed.date AND r.deleted_at IS NULL
+				WHERE trashed.id = ? AND trashed.deleted_at IS NOT NULL AND trashed.`+t.owner+`
+			)
+		`, id, userID).Scan(&taken)
+		if err != nil {
+			return false, err
+		}
+		if taken {
+			return false, fmt.Errorf("%w: the asset already has a record for that day", ErrRestoreConflict)
+		}
+	}
+
+	if kind == "transactions" {
+		if err := checkRestoredRefund(id, userID); err != nil {
+			return false, err
+		}
+	}
+
+	result, err := db.Exec("UPDATE "+t.table+" SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL AND "+t.owner, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// checkRestoredRefund checks that a trashed refund can be restored. The expense it pays back may
+// have been trashed, changed or refunded by other transactions while the refund was in the trash.
+func checkRestoredRefund(id, userID int64) error {
+	var originalID sql.NullInt64
+	var amount models.Money
+	var currency string
+	err := db.QueryRow(`
+		SELECT original_transaction_id, amount, currency FROM transactions
+		WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL
+	`, id, userID).Scan(&originalID, &amount, &currency)
+	if err == sql.ErrNoRows || !originalID.Valid {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	original, err := GetTransactionByID(originalID.Int64, userID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: the expense it refunds is in the trash; restore that first", ErrRestoreConflict)
+	}
+	if err != nil {
+		return err
+	}
+	if original.Type != "expense" || original.Currency != currency {
+		return fmt.Errorf("%w: the transaction it refunds is no longer an expense in %s", ErrRestoreConflict, currency)
+	}
+	refunded, err := GetRefundedAmount(original.ID, userID, id)
+	if err != nil {
+		return err
+	}
+	if refunded+amount > original.Amount {
+		return fmt.Errorf("%w: refunds would exceed the original amount; %s is left to refund", ErrRestoreConflict, original.Amount-refunded)
+	}
+	return nil
+}
+
+// PurgeTrashItem permanently deletes a row of the given kind from the user's trash.
+// It returns the attachments whose content the caller should remove.
+func PurgeTrashItem(kind string, id, userID int64) (bool, []models.Attachment, error) {
+	t, ok := trashTables[kind]
+	if !ok {
+		return false, nil, ErrInvalidTrashKind
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, nil, err
+	}
+	defer tx.Rollback()
+
+	n, attachments, err := purgeTrashRows(tx, kind, "id = ? AND "+t.owner, id, userID)
+	if err != nil || n == 0 {
+		return false, nil, err
+	}
+	return true, attachments, tx.Commit()
+}
+
+// PurgeTrash permanently deletes the trashed rows of a user, or of all users when userID is 0,
+// that were deleted before the given time; a zero time purges everything in the trash.
+// It returns the number of purged rows and the attachments whose content the caller should remove.
+func PurgeTrash(userID int64, before time.Time) (int64, []models.Attachment, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	var total int64
+	var attachments []models.Attachment
+	for _, kind := range trashKinds {
+		condition := "1"
+		args := []interface{}{}
+		if userID != 0 {
+			condition += " AND " + trashTables[kind].owner
+			args = append(args, userID)
+		}
+		if !before.IsZero() {
+			condition += " AND deleted_at < ?"
+			args = append(args, trashTime(before))
+		}
+
+		n, found, err := purgeTrashRows(tx, kind, condition, args...)
+		if err != nil {
+			return 0, nil, err
+		}
+		total += n
+		attachments = append(attachments, found...)
+	}
+	return total, attachments, tx.Commit()
+}
+
+// purgeTrashRows deletes the trashed rows of a kind matching condition together with the
+// rows that depend on them, such as a transaction's edit history. Purging an expense unlinks its
+// refunds and purging an asset unlinks its transactions, auto transactions and templates.
+func purgeTrashRows(q queryer, kind, condition string, args ...interface{}) (int64, []models.Attachment, error) {
+	t := trashTables[kind]
+	condition = "deleted_at IS NOT NULL AND " + condition
+	trashed := "SELECT id FROM " + t.table + " WHERE " + condition
+
+	var attachments []models.Attachment
+	switch kind {
+	case "transactions":
+		rows, err := q.Query(`
+			SELECT id, transaction_id, user_id, file_name, content_type, size, storage_key, created_at
+			FROM transaction_attachments
+			WHERE transaction_id IN (`+trashed+`)
+		`, args...)
+		if err != nil {
+			return 0, nil, err
+		}
+		for rows.Next() {
+			var a models.Attachment
+			if err := rows.Scan(&a.ID, &a.TransactionID, &a.UserID, &a.FileName, &a.ContentType, &a.Size, &a.StorageKey, &a.CreatedAt); err != nil {
+				rows.Close()
+				return 0, nil, err
+			}
+			attachments = append(attachments, a)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, nil, err
+		}
+
+		for _, table := range []string{"transaction_splits", "transaction_tags", "transaction_attachments", "transaction_revisions"} {
+			if _, err := q.Exec("DELETE FROM "+table+" WHERE transaction_id IN ("+trashed+")", args...); err != nil {
+				return 0, nil, err
+			}
+		}
+		// Refunds of a purged expense become plain income
+		if _, err := q.Exec("UPDATE transactions SET original_transaction_id = NULL WHERE original_transaction_id IN ("+trashed+")", args...); err != nil {
+			return 0, nil, err
+		}
+	case "assets":
+		for _, statement := range []string{
+			"UPDATE transactions SET asset_id = NULL WHERE asset_id IN (" + trashed + ")",
+			"UPDATE transactions SET to_asset_id = NULL WHERE to_asset_id IN (" + trashed + ")",
+			"UPDATE auto_transactions SET asset_id = NULL WHERE asset_id IN (" + trashed + ")",
+			"UPDATE transaction_templates SET asset_id = NULL WHERE asset_id IN (" + trashed + ")",
+			"DELETE FROM asset_records WHERE asset_id IN (" + trashed + ")",
+		} {
+			if _, err := q.Exec(statement, args...); err != nil {
+				return 0, nil, err
+			}
+		}
+	}
+
+	result, err := q.Exec("DELETE FROM "+t.table+" WHERE "+condition, args...)
+	if err != nil {
+		return 0, nil, err
+	}
+	n, err := result.RowsAffected()
+	return n, attachments, err
+}
@@ -0,0 +1,184 @@
+package exchange
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"mini-money/internal/database"
+	"mini-money/internal/models"
+)
+
+// defaultColumns is the column order used when a CSV file has no header row
+var defaultColumns = map[string]int{"date": 0, "from": 1, "to": 2, "rate": 3}
+
+// columnAliases maps accepted header names to column roles
+var columnAliases = map[string]string{
+	"date":          "date",
+	"日期":            "date",
+	"from":          "from",
+	"from_currency": "from",
+	"fromcurrency":  "from",
+	"base":          "from",
+	"to":            "to",
+	"to_currency":   "to",
+	"tocurrency":    "to",
+	"quote":         "to",
+	"rate":          "rate",
+	"汇率":            "rate",
+}
+
+// ParseCSV parses exchange rates from a CSV file with the columns date,from,to,rate.
+// A header row is optional; when present it may list the columns in any order.
+// The returned rates have no owner or source set.
+func ParseCSV(r io.Reader) ([]models.ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	columns := defaultColumns
+	rates := []models.ExchangeRate{}
+	line := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line++
+
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if line == 1 {
+			if header, ok := parseHeader(record); ok {
+				columns = header
+				continue
+			}
+		}
+
+		rate, err := parseRecord(record, columns)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+// parseHeader maps the columns of a header row, reporting false if the row isn't a header
+func parseHeader(record []string) (map[string]int, bool) {
+	columns := make(map[string]int)
+	for i, name := range record {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\uFEFF")))
+		if role, ok := columnAliases[name]; ok {
+			columns[role] = i
+		}
+	}
+	if len(columns) != len(defaultColumns) {
+		return nil, false
+	}
+	return columns, true
+}
+
+// parseRecord converts a CSV record into an exchange rate
+func parseRecord(record []string, columns map[string]int) (models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	field := func(role string) string {
+		i := columns[role]
+		if i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(strings.TrimPrefix(record[i], "\uFEFF"))
+	}
+
+	date, err := time.Parse("2006-01-02", field("date"))
+	if err != nil {
+		return rate, errors.New("date must be in YYYY-MM-DD format")
+	}
+	rate.Date = date.Format("2006-01-02")
+
+	if rate.FromCurrency, err = models.NormalizeCurrency(field("from")); err != nil {
+		return rate, err
+	}
+	if rate.ToCurrency, err = models.NormalizeCurrency(field("to")); err != nil {
+		return rate, err
+	}
+	if rate.FromCurrency == rate.ToCurrency {
+		return rate, errors.New("from and to currencies must differ")
+	}
+
+	rate.Rate, err = strconv.ParseFloat(field("rate"), 64)
+	if err != nil || rate.Rate <= 0 {
+		return rate, errors.New("rate must be a positive number")
+	}
+	return rate, nil
+}
+
+// ImportFeed loads the shared exchange rate feed at path into the database.
+// A missing feed file is not an error; it returns the number of imported rates.
+func ImportFeed(path string) (int, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	rates, err := ParseCSV(file)
+	if err != nil {
+		return 0, err
+	}
+	for i := range rates {
+		rates[i].UserID = 0
+		rates[i].Source = "csv"
+	}
+
+	if err := database.UpsertExchangeRates(rates); err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
+
+// WatchFeed imports the feed at path and re-imports it whenever the file changes,
+// checking every interval until stop is closed.
+func WatchFeed(path string, interval time.Duration, stop <-chan struct{}) {
+	var lastModified time.Time
+	check := func() {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().After(lastModified) {
+			return
+		}
+		lastModified = info.ModTime()
+
+		count, err := ImportFeed(path)
+		if err != nil {
+			log.Printf("Error importing exchange rate feed %s: %v", path, err)
+			return
+		}
+		log.Printf("Imported %d exchange rates from %s", count, path)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	check()
+	for {
+		select {
+		case <-ticker.C:
+			check()
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Package export writes tables of data as files for spreadsheets. Rows are written as they come,
+// so a table never has to be held in memory as a whole.
+package export
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+)
+
+// ErrUnknownFormat is returned for an export format that isn't supported
+var ErrUnknownFormat = errors.New("format must be csv or xlsx")
+
+// Cell is one value of a row
+type Cell struct {
+	Value   string
+	Numeric bool // Value is a number such as "-12.50", stored as a number in spreadsheets
+}
+
+// Text returns a cell holding text
+func Text(s string) Cell {
+	return Cell{Value: s}
+}
+
+// Number returns a cell holding a number written in decimal notation
+func Number(s string) Cell {
+	return Cell{Value: s, Numeric: true}
+}
+
+// Writer writes the rows of a table; Close must be called after the last row
+type Writer interface {
+	WriteRow(cells []Cell) error
+	Close() error
+}
+
+// Format describes a file format tables can be exported in
+type Format struct {
+	Extension   string
+	ContentType string
+	NewWriter   func(w io.Writer) (Writer, error)
+}
+
+// Formats are the supported formats by name
+var Formats = map[string]Format{
+	"csv": {
+		Extension:   "csv",
+		ContentType: "text/csv; charset=utf-8",
+		NewWriter:   NewCSVWriter,
+	},
+	"xlsx": {
+		Extension:   "xlsx",
+		ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		NewWriter:   NewXLSXWriter,
+	},
+}
+
+// csvWriter writes a table as CSV
+type csvWriter struct {
+	w *csv.Writer
+}
+
+// NewCSVWriter starts a CSV file. It begins with a byte order mark so that Excel reads it as UTF-8.
+func NewCSVWriter(w io.Writer) (Writer, error) {
+	if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+		return nil, err
+	}
+	return &csvWriter{w: csv.NewWriter(w)}, nil
+}
+
+// WriteRow writes a row of the table
+func (c *csvWriter) WriteRow(cells []Cell) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		record[i] = cell.Value
+	}
+	return c.w.Write(record)
+}
+
+// Close flushes the rows written
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,119 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strconv"
+)
+
+// xlsxFiles are the parts of a workbook besides its only worksheet
+var xlsxFiles = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+		`</Relationships>`},
+	// Style 1 shows numbers with two decimals, style 2 makes the header row bold
+	{"xl/styles.xml", xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+		`<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+		`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+		`</styleSheet>`},
+}
+
+// xlsxWriter writes a table as a workbook with a single worksheet. Text is stored inline in the
+// cells instead of in a shared string table, so nothing has to be kept until the end.
+type xlsxWriter struct {
+	zip  *zip.Writer
+	w    *bufio.Writer
+	rows int
+}
+
+// NewXLSXWriter starts an XLSX workbook. The first row written is shown as a bold header.
+func NewXLSXWriter(w io.Writer) (Writer, error) {
+	z := zip.NewWriter(w)
+	for _, f := range xlsxFiles {
+		part, err := z.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(part, f.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := z.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	x := &xlsxWriter{zip: z, w: bufio.NewWriter(sheet)}
+	x.w.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>` +
+		`<sheetData>`)
+	return x, nil
+}
+
+// WriteRow writes a row of the table
+func (x *xlsxWriter) WriteRow(cells []Cell) error {
+	x.rows++
+	row := strconv.Itoa(x.rows)
+	x.w.WriteString(`<row r="` + row + `">`)
+	for i, cell := range cells {
+		ref := columnName(i) + row
+		switch {
+		case cell.Value == "":
+			continue
+		case x.rows == 1:
+			x.w.WriteString(`<c r="` + ref + `" s="2" t="inlineStr"><is><t>`)
+		case cell.Numeric:
+			x.w.WriteString(`<c r="` + ref + `" s="1"><v>`)
+			xml.EscapeText(x.w, []byte(cell.Value))
+			x.w.WriteString(`</v></c>`)
+			continue
+		default:
+			x.w.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">`)
+		}
+		xml.EscapeText(x.w, []byte(cell.Value))
+		x.w.WriteString(`</t></is></c>`)
+	}
+	_, err := x.w.WriteString(`</row>`)
+	return err
+}
+
+// Close finishes the worksheet and the workbook
+func (x *xlsxWriter) Close() error {
+	x.w.WriteString(`</sheetData></worksheet>`)
+	if err := x.w.Flush(); err != nil {
+		return err
+	}
+	return x.zip.Close()
+}
+
+// columnName returns the letters of a 0-based column, such as A, Z or AA
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"mini-money/internal/config"
+	"mini-money/internal/database"
+	"mini-money/internal/middleware"
+	"mini-money/internal/models"
+	"mini-money/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// attachmentStore holds the contents of transaction attachments
+var attachmentStore storage.BlobStore
+
+// attachmentConfig holds the upload limits for attachments
+var attachmentConfig config.StorageConfig
+
+// SetAttachmentStore configures where attachments are stored and which uploads are accepted
+func SetAttachmentStore(store storage.BlobStore, cfg config.StorageConfig) {
+	attachmentStore = store
+	attachmentConfig = cfg
+}
+
+// attachmentTypeAllowed reports whether a detected MIME type may be uploaded
+func attachmentTypeAllowed(contentType string) bool {
+	for _, allowed := range attachmentConfig.AllowedTypes {
+		if strings.EqualFold(allowed, contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// newStorageKey generates a unique blob key for an attachment of a user
+func newStorageKey(userID int64, fileName string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d/%s%s", userID, hex.EncodeToString(buf), strings.ToLower(filepath.Ext(fileName))), nil
+}
+
+// deleteAttachmentBlobs removes the stored contents of attachments whose records are gone
+func deleteAttachmentBlobs(attachments []models.Attachment) {
+	for _, a := range attachments {
+		if err := attachmentStore.Delete(a.StorageKey); err != nil {
+			log.Printf("Error deleting attachment blob %s: %v", a.StorageKey, err)
+		}
+	}
+}
+
+// attachmentTransaction parses the transaction ID of an attachment request and verifies ownership.
+// It writes the error response and returns false when the request can't continue.
+func attachmentTransaction(c *gin.Context, userID int64) (int64, bool) {
+	transactionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+		return 0, false
+	}
+
+	if _, err := database.GetTransactionByID(transactionID, userID); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return 0, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return 0, false
+	}
+	return transactionID, true
+}
+
+// GetAttachments handles GET /api/transactions/:id/attachments
+func GetAttachments(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	transactionID, ok := attachmentTransaction(c, userID)
+	if !ok {
+		return
+	}
+
+	attachments, err := database.GetAttachments(transactionID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get attachments: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, attachments)
+}
+
+// UploadAttachment handles POST /api/transactions/:id/attachments
+// The file is sent as multipart form field "file"; its type is detected from the content.
+func UploadAttachment(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	transactionID, ok := attachmentTransaction(c, userID)
+	if !ok {
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+		return
+	}
+	maxSize := attachmentConfig.MaxAttachmentSize
+	if fileHeader.Size > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File must not be larger than %d bytes", maxSize)})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	// Sniff the type from the first bytes instead of trusting the client
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	head = head[:n]
+	if n == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is empty"})
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if !attachmentTypeAllowed(contentType) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "File type " + contentType + " is not allowed"})
+		return
+	}
+
+	fileName := filepath.Base(fileHeader.Filename)
+	key, err := newStorageKey(userID, fileName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Read one byte more than allowed so oversized content is detected even if the declared size was wrong
+	content := io.LimitReader(io.MultiReader(bytes.NewReader(head), file), maxSize+1)
+	size, err := attachmentStore.Put(key, content)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store attachment: " + err.Error()})
+		return
+	}
+
+	attachment := models.Attachment{
+		TransactionID: transactionID,
+		UserID:        userID,
+		FileName:      fileName,
+		ContentType:   contentType,
+		Size:          size,
+		StorageKey:    key,
+	}
+	if size > maxSize {
+		deleteAttachmentBlobs([]models.Attachment{attachment})
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File must not be larger than %d bytes", maxSize)})
+		return
+	}
+
+	if err := database.CreateAttachment(&attachment); err != nil {
+		deleteAttachmentBlobs([]models.Attachment{attachment})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attachment: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachment)
+}
+
+// findAttachment looks up the attachment addressed by a request.
+// It writes the error response and returns nil when the attachment can't be found.
+func findAttachment(c *gin.Context, userID int64) *models.Attachment {
+	transactionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+		return nil
+	}
+	attachmentID, err := strconv.ParseInt(c.Param("attachmentId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return nil
+	}
+
+	attachment, err := database.GetAttachment(attachmentID, transactionID, userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil
+	}
+	return attachment
+}
+
+// DownloadAttachment handles GET /api/transactions/:id/attachments/:attachmentId
+func DownloadAttachment(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	attachment := findAttachment(c, userID)
+	if attachment == nil {
+		return
+	}
+
+	content, err := attachmentStore.Open(attachment.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment content not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open attachment: " + err.Error()})
+		return
+	}
+	defer content.Close()
+
+	// Images and PDFs are shown inline; ?download=1 forces a download
+	disposition := "inline"
+	if c.Query("download") != "" {
+		disposition = "attachment"
+	}
+	extraHeaders := map[string]string{
+		"Content-Disposition":    mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}),
+		"X-Content-Type-Options": "nosniff",
+	}
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, content, extraHeaders)
+}
+
+// DeleteAttachment handles DELETE /api/transactions/:id/attachments/:attachmentId
+func DeleteAttachment(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	attachment := findAttachment(c, userID)
+	if attachment == nil {
+		return
+	}
+
+	if err := database.DeleteAttachment(attachment.ID, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attachment: " + err.Error()})
+		return
+	}
+	deleteAttachmentBlobs([]models.Attachment{*attachment})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted successfully"})
+}
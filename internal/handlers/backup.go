@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"mini-money/internal/auth"
+	"mini-money/internal/backup"
+	"mini-money/internal/database"
+	"mini-money/internal/middleware"
+	"mini-money/internal/models"
+	"mini-money/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// maxBackupSize is the largest backup archive accepted for restore into an account
+const maxBackupSize = 200 << 20
+
+// maxNewAccountBackupSize is the largest backup archive accepted for creating an account, which
+// needs no login; larger backups can be restored into an account once it exists
+const maxNewAccountBackupSize = 20 << 20
+
+// ExportBackup handles GET /api/backup
+// It returns a ZIP archive with all of the user's data and attachments, which can be restored on
+// this or another server.
+func ExportBackup(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	b, err := database.ExportBackup(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export data: " + err.Error()})
+		return
+	}
+
+	loc, err := models.LoadTimezone(b.Profile.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	filename := "mini-money-backup-" + b.CreatedAt.In(loc).Format("20060102") + ".zip"
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Status(http.StatusOK)
+	if err := backup.Write(c.Writer, b, attachmentStore.Open); err != nil {
+		// Part of the archive has been sent already, so the download can only be cut short
+		log.Printf("Failed to write backup of user %d: %v", userID, err)
+		c.Abort()
+	}
+}
+
+// readBackup reads the uploaded backup archive of a restore request if it isn't larger than maxSize.
+// On failure it responds to the request and returns false.
+func readBackup(c *gin.Context, maxSize int64) (*backup.Archive, bool) {
+	data, ok := readUploadedFile(c, maxSize)
+	if !ok {
+		return nil, false
+	}
+	archive, err := backup.Read(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return archive, true
+}
+
+// restoreBackup restores an archive and responds with the error if that fails. The attachments'
+// contents are checked like uploads and stored first, and removed again when the restore fails.
+func restoreBackup(c *gin.Context, userID int64, archive *backup.Archive, options database.RestoreOptions) (*models.RestoreResult, bool) {
+	var stored []models.Attachment
+	options.StoreAttachment = func(userID int64, a *models.BackupAttachment) error {
+		content, err := archive.Open(*a)
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		defer content.Close()
+
+		// The content is checked like an upload, as the archive may not have been written by this app
+		maxSize := attachmentConfig.MaxAttachmentSize
+		head := make([]byte, 512)
+		n, err := io.ReadFull(content, head)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return fmt.Errorf("%w: attachment %q: %v", database.ErrInvalidBackup, a.FileName, err)
+		}
+		head = head[:n]
+		if n == 0 {
+			return fmt.Errorf("%w: attachment %q is empty", database.ErrInvalidBackup, a.FileName)
+		}
+		contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+		if !attachmentTypeAllowed(contentType) {
+			return fmt.Errorf("%w: attachment %q: file type %s is not allowed", database.ErrInvalidBackup, a.FileName, contentType)
+		}
+
+		fileName := filepath.Base(a.FileName)
+		key, err := newStorageKey(userID, fileName)
+		if err != nil {
+			return err
+		}
+		// Read one byte more than allowed so oversized content is detected whatever the archive says
+		size, err := attachmentStore.Put(key, io.LimitReader(io.MultiReader(bytes.NewReader(head), content), maxSize+1))
+		if err != nil {
+			return err
+		}
+		stored = append(stored, models.Attachment{StorageKey: key})
+		if size > maxSize {
+			return fmt.Errorf("%w: attachment %q is larger than %d bytes", database.ErrInvalidBackup, a.FileName, maxSize)
+		}
+		a.FileName, a.ContentType = fileName, contentType
+		a.StorageKey, a.Size = key, size
+		return nil
+	}
+
+	result, err := database.RestoreBackup(userID, archive.Backup, options)
+	if err != nil {
+		deleteAttachmentBlobs(stored)
+		if errors.Is(err, database.ErrUnsupportedBackup) || errors.Is(err, database.ErrInvalidBackup) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore backup: " + err.Error()})
+		return nil, false
+	}
+	return result, true
+}
+
+// RestoreBackup handles POST /api/backup/restore
+// The request is a multipart form with the archive as "file" and an optional "categoryConflict":
+// merge (the default), overwrite or rename. The backup is added to the user's data; the profile
+// of the account is kept.
+func RestoreBackup(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	if !parseUpload(c, maxBackupSize) {
+		return
+	}
+	conflict := c.DefaultPostForm("categoryConflict", database.CategoryConflictMerge)
+	switch conflict {
+	case database.CategoryConflictMerge, database.CategoryConflictOverwrite, database.CategoryConflictRename:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "categoryConflict must be 'merge', 'overwrite' or 'rename'"})
+		return
+	}
+	archive, ok := readBackup(c, maxBackupSize)
+	if !ok {
+		return
+	}
+
+	result, ok := restoreBackup(c, userID, archive, database.RestoreOptions{CategoryConflict: conflict})
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// RestoreAccount handles POST /api/auth/restore
+// It registers a new account from a backup. The request is a multipart form with the archive as
+// "file" and the "username", "email" and "password" of the account; the avatar, base currency and
+// timezone are taken from the backup.
+func RestoreAccount(c *gin.Context) {
+	if !parseUpload(c, maxNewAccountBackupSize) {
+		return
+	}
+	req := models.RegisterRequest{
+		Username: c.PostForm("username"),
+		Email:    c.PostForm("email"),
+		Password: c.PostForm("password"),
+	}
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if accountTaken(c, req) {
+		return
+	}
+	archive, ok := readBackup(c, maxNewAccountBackupSize)
+	if !ok {
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+	profile := archive.Backup.Profile
+	user := &models.User{
+		Username: req.Username,
+		Email:    req.Email,
+		Avatar:   profile.Avatar,
+		Password: hashedPassword,
+	}
+	if currency, err := models.NormalizeCurrency(profile.BaseCurrency); err == nil {
+		user.BaseCurrency = currency
+	}
+	if _, err := models.LoadTimezone(profile.Timezone); err == nil {
+		user.Timezone = profile.Timezone
+	}
+
+	result, ok := restoreBackup(c, 0, archive, database.RestoreOptions{NewUser: user})
+	if !ok {
+		return
+	}
+
+	// A backup without categories gets the defaults, like a newly registered account
+	if len(archive.Backup.AssetCategories) == 0 {
+		if err := database.InitializeDefaultAssetCategories(user.ID); err != nil {
+			log.Printf("Warning: Failed to initialize default asset categories for user %d: %v", user.ID, err)
+		}
+	}
+	if len(archive.Backup.TransactionCategories) == 0 {
+		if err := database.InitializeDefaultTransactionCategories(user.ID); err != nil {
+			log.Printf("Warning: Failed to initialize default transaction categories for user %d: %v", user.ID, err)
+		}
+	}
+
+	token, err := auth.GenerateToken(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"token":  token,
+		"user":   userResponse(user),
+		"result": result,
+	})
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mini-money/internal/database"
+	"mini-money/internal/middleware"
+	"mini-money/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDuplicateTransactions handles GET /api/transactions/duplicates
+// It lists groups of transactions that look like the same entry recorded more than once.
+func GetDuplicateTransactions(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	groups, err := database.GetDuplicateGroups(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find duplicates: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
+
+// MergeTransactions handles POST /api/transactions/:id/merge
+// The transactions in duplicateIds are merged into the one in the path and moved to the trash.
+func MergeTransactions(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+		return
+	}
+
+	var req models.MergeTransactionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	merged, err := database.MergeTransactions(id, req.DuplicateIDs, userID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	case errors.Is(err, database.ErrDuplicateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, database.ErrMergeMismatch), errors.Is(err, database.ErrMergeIntoItself), errors.Is(err, database.ErrMergeOverRefunded):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge transactions: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, merged)
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"mini-money/internal/database"
+	"mini-money/internal/exchange"
+	"mini-money/internal/middleware"
+	"mini-money/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetExchangeRates handles GET /api/exchange-rates
+func GetExchangeRates(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	rates, err := database.GetExchangeRates(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get exchange rates: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rates)
+}
+
+// CreateExchangeRate handles POST /api/exchange-rates
+func CreateExchangeRate(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req models.CreateExchangeRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fromCurrency, err := models.NormalizeCurrency(req.FromCurrency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	toCurrency, err := models.NormalizeCurrency(req.ToCurrency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if fromCurrency == toCurrency {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fromCurrency and toCurrency must differ"})
+		return
+	}
+	if _, err := time.Parse("2006-01-02", req.Date); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Date must be in YYYY-MM-DD format"})
+		return
+	}
+
+	rates := []models.ExchangeRate{{
+		UserID:       userID,
+		FromCurrency: fromCurrency,
+		ToCurrency:   toCurrency,
+		Rate:         req.Rate,
+		Date:         req.Date,
+		Source:       "manual",
+	}}
+
+	if err := database.UpsertExchangeRates(rates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save exchange rate: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rates[0])
+}
+
+// ImportExchangeRates handles POST /api/exchange-rates/import
+// The request is a multipart form with a CSV "file" in the same format as the shared feed.
+func ImportExchangeRates(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "CSV file is required"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	rates, err := exchange.ParseCSV(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exchange rate CSV: " + err.Error()})
+		return
+	}
+	for i := range rates {
+		rates[i].UserID = userID
+		rates[i].Source = "csv"
+	}
+
+	if err := database.UpsertExchangeRates(rates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import exchange rates: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exchange rates imported successfully", "imported": len(rates)})
+}
+
+// DeleteExchangeRate handles DELETE /api/exchange-rates/:id
+func DeleteExchangeRate(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exchange rate ID"})
+		return
+	}
+
+	rowsAffected, err := database.DeleteExchangeRate(id, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete exchange rate: " + err.Error()})
+		return
+	}
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exchange rate not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exchange rate deleted successfully"})
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"mini-money/internal/database"
+	"mini-money/internal/export"
+	"mini-money/internal/middleware"
+	"mini-money/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportHeaders are the columns of an exported transaction list; the names are ones the CSV
+// import recognizes, so exported income and expenses can be imported again. Transfers can't, as
+// the import has no type for them.
+var exportHeaders = []string{"日期", "类型", "分类", "图标", "描述", "金额", "币种", "资产", "转入资产", "交易对方", "标签"}
+
+// exportTypeNames are the names of the transaction types in an export
+var exportTypeNames = map[string]string{"income": "收入", "expense": "支出", "transfer": "转账"}
+
+// transactionExporter turns transactions into rows, naming the categories, assets and payees they refer to
+type transactionExporter struct {
+	loc        *time.Location
+	categories map[string]map[string]models.Category // by type, then key
+	assets     map[int64]string
+	payees     map[int64]string
+}
+
+// newTransactionExporter loads the names a user has given to categories, assets and payees
+func newTransactionExporter(userID int64) (*transactionExporter, error) {
+	loc, err := database.GetUserLocation(userID)
+	if err != nil {
+		return nil, err
+	}
+	e := &transactionExporter{
+		loc:        loc,
+		categories: make(map[string]map[string]models.Category),
+		assets:     make(map[int64]string),
+		payees:     make(map[int64]string),
+	}
+
+	categories, err := database.GetTransactionCategories(userID)
+	if err != nil {
+		return nil, err
+	}
+	for kind, list := range categories {
+		e.categories[kind] = make(map[string]models.Category)
+		for _, category := range list {
+			e.categories[kind][category.Key] = category
+		}
+	}
+
+	assets, err := database.GetAssetsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, asset := range assets {
+		e.assets[asset.ID] = asset.Name
+	}
+
+	payees, err := database.GetPayees(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, payee := range payees {
+		e.payees[payee.ID] = payee.Name
+	}
+	return e, nil
+}
+
+// row converts a transaction to the cells of its row. Expenses have a negative amount so that a
+// column sum is the net change.
+func (e *transactionExporter) row(t models.Transaction) []export.Cell {
+	category := e.categories[t.Type][t.CategoryKey]
+	if category.Name == "" && t.OriginalTransactionID != nil {
+		// A refund is income filed under the category of the expense it refunds
+		category = e.categories["expense"][t.CategoryKey]
+	}
+	if category.Name == "" {
+		category.Name = t.CategoryKey
+	}
+	amount := t.Amount
+	if t.Type == "expense" {
+		amount = -amount
+	}
+	tags := make([]string, len(t.Tags))
+	for i, tag := range t.Tags {
+		tags[i] = tag.Name
+	}
+	return []export.Cell{
+		export.Text(t.Date.In(e.loc).Format("2006-01-02 15:04:05")),
+		export.Text(exportTypeNames[t.Type]),
+		exportText(category.Name),
+		exportText(category.Icon),
+		exportText(t.Description),
+		export.Number(amount.String()),
+		export.Text(t.Currency),
+		exportText(e.assetName(t.AssetID)),
+		exportText(e.assetName(t.ToAssetID)),
+		exportText(e.payeeName(t.PayeeID)),
+		exportText(strings.Join(tags, ", ")),
+	}
+}
+
+// exportText returns a text cell. Text a spreadsheet would run as a formula, starting with =, +,
+// - or @, or that it would trim to such text, starting with a tab or carriage return, is prefixed
+// with a quote so that it is shown as it is.
+func exportText(s string) export.Cell {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		s = "'" + s
+	}
+	return export.Text(s)
+}
+
+// assetName names an asset; one that no longer exists is shown by its ID
+func (e *transactionExporter) assetName(id *int64) string {
+	if id == nil {
+		return ""
+	}
+	if name, ok := e.assets[*id]; ok {
+		return name
+	}
+	return "#" + strconv.FormatInt(*id, 10)
+}
+
+// payeeName names a payee
+func (e *transactionExporter) payeeName(id *int64) string {
+	if id == nil {
+		return ""
+	}
+	return e.payees[*id]
+}
+
+// ExportTransactions handles GET /api/export/transactions
+// It takes the filters of GET /api/transactions and a format of csv (the default) or xlsx, and
+// streams the file as it reads the transactions.
+func ExportTransactions(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	format, ok := export.Formats[c.DefaultQuery("format", "csv")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": export.ErrUnknownFormat.Error()})
+		return
+	}
+	filter, ok := transactionFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	exporter, err := newTransactionExporter(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load categories, assets or payees: " + err.Error()})
+		return
+	}
+
+	// The response starts with the first row, so errors found before it are still reported as JSON
+	var writer export.Writer
+	start := func() error {
+		filename := "transactions-" + time.Now().In(exporter.loc).Format("20060102") + "." + format.Extension
+		c.Header("Content-Type", format.ContentType)
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		c.Status(http.StatusOK)
+		var err error
+		if writer, err = format.NewWriter(c.Writer); err != nil {
+			return err
+		}
+		cells := make([]export.Cell, len(exportHeaders))
+		for i, header := range exportHeaders {
+			cells[i] = export.Text(header)
+		}
+		return writer.WriteRow(cells)
+	}
+
+	err = database.EachTransaction(userID, filter, func(t models.Transaction) error {
+		if writer == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		return writer.WriteRow(exporter.row(t))
+	})
+	if err == nil && writer == nil {
+		err = start()
+	}
+	if writer == nil {
+		switch {
+		case errors.Is(err, database.ErrInvalidSort) || errors.Is(err, database.ErrRelevanceWithoutSearch):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		// Part of the file has been sent already, so the download can only be cut short
+		log.Printf("Failed to export transactions of user %d: %v", userID, err)
+		c.Abort()
+	}
+}
@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"mini-money/internal/auth"
@@ -14,58 +18,118 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// addTransactionRequest is the JSON body of a new transaction; the date is a string from the frontend
+type addTransactionRequest struct {
+	Description string       `json:"description"`
+	Amount      models.Money `json:"amount"`
+	Currency    string       `json:"currency"` // Defaults to the user's base currency
+	Type        string       `json:"type"`
+	CategoryKey string       `json:"categoryKey"`
+	Date        string       `json:"date"`      // Accept date as string from frontend
+	AssetID     *int64       `json:"assetId"`   // Optional asset account the money moves through
+	ToAssetID   *int64       `json:"toAssetId"` // Destination asset of a transfer
+	TagIDs      []int64      `json:"tagIds"`
+	// Expense this income refunds or reimburses; the category defaults to the expense's
+	OriginalTransactionID *int64 `json:"originalTransactionId"`
+	Reimbursable          bool   `json:"reimbursable"` // Expense is waiting to be reimbursed
+	// Payee of the transaction; when omitted it is matched from the description, 0 leaves it empty
+	PayeeID *int64 `json:"payeeId"`
+	// Optional split lines; they must add up to the amount
+	Splits []models.TransactionSplit `json:"splits"`
+}
+
+// addTransactionResponse is the saved transaction, with a warning when it looks like a duplicate
+type addTransactionResponse struct {
+	models.Transaction
+	Warning    string               `json:"warning,omitempty"`
+	Duplicates []models.Transaction `json:"duplicates,omitempty"` // Existing transactions it may duplicate
+}
+
 // AddTransaction handles POST /api/transactions
 func AddTransaction(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
-	// Define a struct to handle the incoming JSON with string date
-	var requestData struct {
-		Description string  `json:"description"`
-		Amount      float64 `json:"amount"`
-		Type        string  `json:"type"`
-		CategoryKey string  `json:"categoryKey"`
-		Date        string  `json:"date"` // Accept date as string from frontend
+	var requestData addTransactionRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	if err := c.ShouldBindJSON(&requestData); err != nil {
+	newTransaction, err := buildNewTransaction(userID, requestData)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Possible duplicates don't stop the transaction from being saved, they are only reported
+	duplicates, err := database.FindDuplicates(&newTransaction)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check for duplicates: " + err.Error()})
+		return
+	}
+
+	if err := database.InsertTransaction(&newTransaction); err != nil {
+		if errors.Is(err, database.ErrTagNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := addTransactionResponse{Transaction: newTransaction}
+	if len(duplicates) > 0 {
+		response.Warning = "This transaction looks like one that was already recorded"
+		response.Duplicates = duplicates
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// buildNewTransaction validates a new transaction request and fills in the defaults
+func buildNewTransaction(userID int64, requestData addTransactionRequest) (models.Transaction, error) {
+	if !models.ValidTransactionType(requestData.Type) {
+		return models.Transaction{}, errors.New("Type must be 'income', 'expense' or 'transfer'")
+	}
+
+	asset, currency, err := resolveAssetCurrency(userID, requestData.AssetID, requestData.Currency)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	if err := models.ValidateSplits(requestData.Amount, requestData.Splits); err != nil {
+		return models.Transaction{}, err
+	}
+	// A split transaction is filed under its first split when no category is given
+	if requestData.CategoryKey == "" && len(requestData.Splits) > 0 {
+		requestData.CategoryKey = requestData.Splits[0].CategoryKey
+	}
+	// A refund is filed under the category of the expense it pays back unless told otherwise;
+	// statistics net it against that expense's categories either way
+	if requestData.CategoryKey == "" && requestData.OriginalTransactionID != nil {
+		original, err := database.GetTransactionByID(*requestData.OriginalTransactionID, userID)
+		if err == nil {
+			requestData.CategoryKey = original.CategoryKey
+		}
+	}
+
 	// Parse the date from the frontend (supports YYYY-MM-DD and ISO 8601 formats)
 	var transactionDate time.Time
 	if requestData.Date != "" {
-		// Try parsing different date formats
-		var parsedDate time.Time
-		var err error
-
-		// First try ISO 8601 format: 2025-09-24T00:00:00.000Z or similar
-		if parsedDate, err = time.Parse("2006-01-02T15:04:05.000Z", requestData.Date); err != nil {
-			// Try without Z suffix
-			if parsedDate, err = time.Parse("2006-01-02T15:04:05.000", requestData.Date); err != nil {
-				// Try RFC3339 format
-				if parsedDate, err = time.Parse(time.RFC3339, requestData.Date); err != nil {
-					// Finally try simple date format: YYYY-MM-DD
-					if parsedDate, err = time.Parse("2006-01-02", requestData.Date); err != nil {
-						c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Supported formats: YYYY-MM-DD, YYYY-MM-DDTHH:mm:ss.sssZ"})
-						return
-					}
-				}
-			}
+		loc, err := database.GetUserLocation(userID)
+		if err != nil {
+			return models.Transaction{}, err
+		}
+		parsedDate, dateOnly, err := parseTransactionDate(requestData.Date, loc)
+		if err != nil {
+			return models.Transaction{}, err
 		}
 
-		// If only date was provided (YYYY-MM-DD format), set time to current time
+		// If only date was provided (YYYY-MM-DD format), set time to the current time in the user's timezone
 		// For full datetime formats, use the provided time
-		if len(requestData.Date) <= 10 { // YYYY-MM-DD format
-			now := time.Now().UTC()
-			transactionDate = time.Date(
-				parsedDate.Year(), parsedDate.Month(), parsedDate.Day(),
-				now.Hour(), now.Minute(), now.Second(), now.Nanosecond(),
-				time.UTC,
-			)
+		if dateOnly {
+			transactionDate = withTimeOfDay(parsedDate, time.Now().In(loc))
 		} else {
-			// Use the full datetime provided, but ensure it's in UTC
-			transactionDate = parsedDate.UTC()
+			transactionDate = parsedDate
 		}
 	} else {
 		// If no date provided, use current time
@@ -77,38 +141,268 @@ func AddTransaction(c *gin.Context) {
 		UserID:      userID,
 		Description: requestData.Description,
 		Amount:      requestData.Amount,
+		Currency:    currency,
 		Type:        requestData.Type,
 		CategoryKey: requestData.CategoryKey,
 		Date:        transactionDate,
+		AssetID:     assetIDOf(asset),
+		ToAssetID:   requestData.ToAssetID,
+		Splits:      requestData.Splits,
+		Tags:        tagsFromIDs(requestData.TagIDs),
+
+		OriginalTransactionID: requestData.OriginalTransactionID,
+		Reimbursable:          requestData.Reimbursable,
+	}
+	if err := validateTransfer(userID, &newTransaction); err != nil {
+		return models.Transaction{}, err
 	}
+	if err := validateRefund(userID, &newTransaction); err != nil {
+		return models.Transaction{}, err
+	}
+	if newTransaction.PayeeID, err = resolvePayee(userID, requestData.PayeeID, requestData.Description); err != nil {
+		return models.Transaction{}, err
+	}
+	return newTransaction, nil
+}
 
-	if err := database.InsertTransaction(&newTransaction); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+// parseTransactionDate parses a transaction date sent by the frontend.
+// It supports YYYY-MM-DD and ISO 8601 formats and reports whether only a date was given.
+// A date and time without an offset is taken to be in loc, the user's timezone.
+func parseTransactionDate(value string, loc *time.Location) (time.Time, bool, error) {
+	// First try ISO 8601 format: 2025-09-24T00:00:00.000Z or similar
+	if parsedDate, err := time.Parse("2006-01-02T15:04:05.000Z", value); err == nil {
+		return parsedDate.UTC(), false, nil
+	}
+	// Try without Z suffix
+	if parsedDate, err := time.ParseInLocation("2006-01-02T15:04:05.000", value, loc); err == nil {
+		return parsedDate.UTC(), false, nil
+	}
+	// Try RFC3339 format
+	if parsedDate, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsedDate.UTC(), false, nil
+	}
+	// Finally try simple date format: YYYY-MM-DD
+	if parsedDate, err := time.Parse("2006-01-02", value); err == nil {
+		return parsedDate, true, nil
+	}
+	return time.Time{}, false, errors.New("Invalid date format. Supported formats: YYYY-MM-DD, YYYY-MM-DDTHH:mm:ss.sssZ")
+}
+
+// withTimeOfDay combines the calendar date of day with the clock time of clock in the
+// timezone of clock, and returns the result in UTC
+func withTimeOfDay(day, clock time.Time) time.Time {
+	return time.Date(
+		day.Year(), day.Month(), day.Day(),
+		clock.Hour(), clock.Minute(), clock.Second(), clock.Nanosecond(),
+		clock.Location(),
+	).UTC()
+}
+
+// resolveCurrency validates a currency code from a request, defaulting to the user's base currency
+func resolveCurrency(userID int64, code string) (string, error) {
+	if code == "" {
+		return database.GetUserBaseCurrency(userID)
+	}
+	return models.NormalizeCurrency(code)
+}
+
+// linkedAsset looks up the asset a transaction should be linked to.
+// It returns nil when no asset (or 0) is given and an error when the asset doesn't belong to the user.
+func linkedAsset(userID int64, assetID *int64) (*models.Asset, error) {
+	if assetID == nil || *assetID == 0 {
+		return nil, nil
+	}
+	asset, err := database.GetAssetByID(*assetID, userID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("asset %d not found", *assetID)
+	}
+	return asset, err
+}
+
+// checkAssetCurrency ensures a transaction uses the same currency as its linked asset
+func checkAssetCurrency(asset *models.Asset, currency string) error {
+	if asset != nil && asset.Currency != currency {
+		return fmt.Errorf("currency %s does not match asset currency %s", currency, asset.Currency)
+	}
+	return nil
+}
+
+// validateTransfer checks the accounts of a transfer. On top of the checks of
+// models.ValidateTransfer, the destination must be an asset of the user in the
+// transaction's currency.
+func validateTransfer(userID int64, t *models.Transaction) error {
+	if err := models.ValidateTransfer(t); err != nil || t.Type != "transfer" {
+		return err
+	}
+
+	destination, err := linkedAsset(userID, t.ToAssetID)
+	if err != nil {
+		return err
+	}
+	return checkAssetCurrency(destination, t.Currency)
+}
+
+// resolveAssetCurrency validates the asset and currency of a new transaction.
+// Without an explicit currency a transaction linked to an asset uses the asset's currency.
+func resolveAssetCurrency(userID int64, assetID *int64, code string) (*models.Asset, string, error) {
+	asset, err := linkedAsset(userID, assetID)
+	if err != nil {
+		return nil, "", err
+	}
+	if code == "" && asset != nil {
+		return asset, asset.Currency, nil
+	}
+	currency, err := resolveCurrency(userID, code)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := checkAssetCurrency(asset, currency); err != nil {
+		return nil, "", err
+	}
+	return asset, currency, nil
+}
+
+// assetIDOf returns the ID of an optional asset
+func assetIDOf(asset *models.Asset) *int64 {
+	if asset == nil {
+		return nil
+	}
+	return &asset.ID
+}
+
+// tagsFromIDs builds the tag list of a transaction from the tag IDs of a request, dropping duplicates
+func tagsFromIDs(ids []int64) []models.Tag {
+	var tags []models.Tag
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			tags = append(tags, models.Tag{ID: id})
+		}
+	}
+	return tags
+}
+
+// parseIDList parses a comma-separated list of IDs such as "1,2,3"
+func parseIDList(value string) ([]int64, error) {
+	var ids []int64
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// transactionFilterFromQuery reads the filters of GET /api/transactions from the query string.
+// It responds with 400 and returns false when one of them is invalid.
+func transactionFilterFromQuery(c *gin.Context) (database.TransactionFilter, bool) {
+	filter := database.TransactionFilter{
+		Type:      c.Query("type"),
+		Month:     c.Query("month"),
+		Search:    c.Query("search"),
+		Date:      c.Query("date"),
+		StartDate: c.Query("start_date"),
+		EndDate:   c.Query("end_date"),
+		SortBy:    c.DefaultQuery("sort", "date"),
+	}
+
+	// tags is a comma-separated list of tag IDs
+	tagIDs, err := parseIDList(c.Query("tags"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tags parameter"})
+		return filter, false
+	}
+	filter.TagIDs = tagIDs
+
+	// payees is a comma-separated list of payee IDs
+	payeeIDs, err := parseIDList(c.Query("payees"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payees parameter"})
+		return filter, false
+	}
+	filter.PayeeIDs = payeeIDs
+
+	// Newest and largest first; categories alphabetically and best matches first by default
+	order := c.Query("order")
+	switch order {
+	case "":
+		filter.Ascending = filter.SortBy == "category" || filter.SortBy == "relevance"
+	case "asc", "desc":
+		filter.Ascending = order == "asc"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be 'asc' or 'desc'"})
+		return filter, false
 	}
 
-	c.JSON(http.StatusOK, newTransaction)
+	if limit := c.Query("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return filter, false
+		}
+	}
+	return filter, true
 }
 
+// Page sizes accepted by GET /api/transactions
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
 // GetTransactions handles GET /api/transactions
+// Without page_size or cursor it returns a plain array as before; with either it
+// returns a models.TransactionPage envelope and the next page is requested with nextCursor.
 func GetTransactions(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
-	// Get query parameters
-	transactionType := c.Query("type")
-	month := c.Query("month")
-	search := c.Query("search")
-	limit := c.Query("limit")
-	date := c.Query("date")
-	startDate := c.Query("start_date")
-	endDate := c.Query("end_date")
+	filter, ok := transactionFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	pageSizeParam, cursor := c.Query("page_size"), c.Query("cursor")
+	if pageSizeParam == "" && cursor == "" {
+		transactions, err := database.GetFilteredTransactions(userID, filter)
+		if errors.Is(err, database.ErrInvalidSort) || errors.Is(err, database.ErrRelevanceWithoutSearch) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, transactions)
+		return
+	}
+
+	pageSize := defaultPageSize
+	if pageSizeParam != "" {
+		var err error
+		pageSize, err = strconv.Atoi(pageSizeParam)
+		if err != nil || pageSize < 1 || pageSize > maxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("page_size must be between 1 and %d", maxPageSize)})
+			return
+		}
+	}
 
-	transactions, err := database.GetFilteredTransactions(userID, transactionType, month, search, limit, date, startDate, endDate)
+	page, err := database.GetTransactionPage(userID, filter, pageSize, cursor)
+	if errors.Is(err, database.ErrInvalidSort) || errors.Is(err, database.ErrRelevanceWithoutSearch) || errors.Is(err, database.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, transactions)
+	c.JSON(http.StatusOK, page)
 }
 
 // DeleteTransaction handles DELETE /api/transactions/:id
@@ -127,7 +421,195 @@ func DeleteTransaction(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Transaction deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Transaction moved to trash"})
+}
+
+// UpdateTransaction handles PUT /api/transactions/:id and PATCH /api/transactions/:id
+func UpdateTransaction(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+		return
+	}
+
+	var req models.UpdateTransactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// PUT replaces the transaction, so all editable fields must be present
+	if c.Request.Method == http.MethodPut && (req.Amount == nil || req.Type == nil || req.CategoryKey == nil || req.Date == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount, type, categoryKey and date are required"})
+		return
+	}
+
+	existing, err := database.GetTransactionByID(id, userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated := *existing
+	if req.Description != nil {
+		updated.Description = *req.Description
+	}
+	if req.Amount != nil {
+		updated.Amount = *req.Amount
+	}
+	if req.Currency != nil {
+		currency, err := models.NormalizeCurrency(*req.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updated.Currency = currency
+	}
+	if req.Type != nil {
+		if !models.ValidTransactionType(*req.Type) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Type must be 'income', 'expense' or 'transfer'"})
+			return
+		}
+		updated.Type = *req.Type
+		// A transaction that stops being a transfer loses its destination
+		if updated.Type != "transfer" && req.ToAssetID == nil {
+			updated.ToAssetID = nil
+		}
+		// Likewise only expenses are reimbursable and only income refunds an expense
+		if updated.Type != "expense" && req.Reimbursable == nil {
+			updated.Reimbursable = false
+		}
+		if updated.Type != "income" && req.OriginalTransactionID == nil {
+			updated.OriginalTransactionID = nil
+		}
+	}
+	if req.CategoryKey != nil {
+		updated.CategoryKey = *req.CategoryKey
+	}
+	if req.AssetID != nil {
+		// assetId 0 unlinks the transaction from its asset
+		asset, err := linkedAsset(userID, req.AssetID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updated.AssetID = assetIDOf(asset)
+	}
+	if req.ToAssetID != nil {
+		updated.ToAssetID = req.ToAssetID
+		if *req.ToAssetID == 0 {
+			updated.ToAssetID = nil
+		}
+	}
+	if updated.AssetID != nil {
+		asset, err := database.GetAssetByID(*updated.AssetID, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkAssetCurrency(asset, updated.Currency); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Splits != nil {
+		updated.Splits = *req.Splits
+	}
+	if req.TagIDs != nil {
+		updated.Tags = tagsFromIDs(*req.TagIDs)
+	}
+	if req.OriginalTransactionID != nil {
+		updated.OriginalTransactionID = req.OriginalTransactionID
+		if *req.OriginalTransactionID == 0 {
+			updated.OriginalTransactionID = nil
+		}
+	}
+	if req.Reimbursable != nil {
+		updated.Reimbursable = *req.Reimbursable
+	}
+	if req.PayeeID != nil {
+		// payeeId 0 removes the payee
+		updated.PayeeID = nil
+		if *req.PayeeID != 0 {
+			if _, err := database.GetPayeeByID(*req.PayeeID, userID); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updated.PayeeID = req.PayeeID
+		}
+	}
+	if err := models.ValidateSplits(updated.Amount, updated.Splits); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateTransfer(userID, &updated); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateRefund(userID, &updated); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Date != nil {
+		loc, err := database.GetUserLocation(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		parsedDate, dateOnly, err := parseTransactionDate(*req.Date, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// Keep the original local time of day when only the date is corrected
+		if dateOnly {
+			updated.Date = withTimeOfDay(parsedDate, existing.Date.In(loc))
+		} else {
+			updated.Date = parsedDate
+		}
+	}
+
+	if err := database.UpdateTransaction(&updated, userID); err != nil {
+		if errors.Is(err, database.ErrTagNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transaction: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// GetTransactionHistory handles GET /api/transactions/:id/history
+func GetTransactionHistory(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+		return
+	}
+
+	// Verify transaction ownership
+	if _, err := database.GetTransactionByID(id, userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	revisions, err := database.GetTransactionRevisions(id, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get transaction history: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
 }
 
 // GetSummary handles GET /api/summary
@@ -158,32 +640,13 @@ func GetCategories(c *gin.Context) {
 // GetStatistics handles GET /api/statistics
 func GetStatistics(c *gin.Context) {
 	userID := middleware.GetUserID(c)
-	year, _ := strconv.Atoi(c.DefaultQuery("year", strconv.Itoa(time.Now().Year())))
-
-	// Check if month parameter is provided
-	monthStr := c.Query("month")
-	periodType := c.DefaultQuery("period", "month") // "month" or "year", default to "month" for backward compatibility
+	startTime, endTime, err := statisticsPeriod(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	var stats models.Statistics
-	var err error
-	var startTime, endTime time.Time
-
-	// Determine time bounds based on parameters
-	if monthStr != "" {
-		// If month is explicitly provided, use monthly statistics (backward compatibility)
-		month, _ := strconv.Atoi(monthStr)
-		if month == 0 {
-			month = int(time.Now().Month())
-		}
-		startTime, endTime = getMonthBounds(year, month)
-	} else if periodType == "year" {
-		// If period=year is specified, use yearly statistics
-		startTime, endTime = getYearBounds(year)
-	} else {
-		// Default to current month for backward compatibility
-		month := int(time.Now().Month())
-		startTime, endTime = getMonthBounds(year, month)
-	}
 
 	stats.Summary, err = database.GetSummaryForPeriod(userID, startTime, endTime)
 	if err != nil {
@@ -206,23 +669,32 @@ func GetStatistics(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
-// Register handles POST /api/auth/register
-func Register(c *gin.Context) {
-	var req models.RegisterRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
+// accountTaken checks that the email and username of a new account are still free.
+// If not it responds with 409 and returns true.
+func accountTaken(c *gin.Context, req models.RegisterRequest) bool {
 	// Check if user already exists
 	if _, err := database.GetUserByEmail(req.Email); err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
-		return
+		return true
 	}
 
 	// Check if username already exists
 	if _, err := database.GetUserByUsername(req.Username); err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "Username already taken"})
+		return true
+	}
+	return false
+}
+
+// Register handles POST /api/auth/register
+func Register(c *gin.Context) {
+	var req models.RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if accountTaken(c, req) {
 		return
 	}
 
@@ -266,12 +738,7 @@ func Register(c *gin.Context) {
 
 	response := models.AuthResponse{
 		Token: token,
-		User: models.UserResponse{
-			ID:       user.ID,
-			Username: user.Username,
-			Email:    user.Email,
-			Avatar:   user.Avatar,
-		},
+		User:  userResponse(user),
 	}
 
 	c.JSON(http.StatusCreated, response)
@@ -287,12 +754,7 @@ func GetUserProfile(c *gin.Context) {
 		return
 	}
 
-	response := models.UserResponse{
-		ID:       user.ID,
-		Username: user.Username,
-		Email:    user.Email,
-		Avatar:   user.Avatar,
-	}
+	response := userResponse(user)
 
 	c.JSON(http.StatusOK, response)
 }
@@ -320,16 +782,71 @@ func UpdateUserAvatar(c *gin.Context) {
 		return
 	}
 
-	response := models.UserResponse{
-		ID:       user.ID,
-		Username: user.Username,
-		Email:    user.Email,
-		Avatar:   user.Avatar,
-	}
+	response := userResponse(user)
 
 	c.JSON(http.StatusOK, response)
 }
 
+// UpdateUserBaseCurrency handles PUT /api/user/base-currency
+func UpdateUserBaseCurrency(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req models.UpdateBaseCurrencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currency, err := models.NormalizeCurrency(req.BaseCurrency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.UpdateUserBaseCurrency(userID, currency); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update base currency"})
+		return
+	}
+
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user info"})
+		return
+	}
+
+	c.JSON(http.StatusOK, userResponse(user))
+}
+
+// UpdateUserTimezone handles PUT /api/user/timezone
+func UpdateUserTimezone(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req models.UpdateTimezoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	loc, err := models.LoadTimezone(req.Timezone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.UpdateUserTimezone(userID, loc.String()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update timezone"})
+		return
+	}
+
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user info"})
+		return
+	}
+
+	c.JSON(http.StatusOK, userResponse(user))
+}
+
 // UpdateUserPassword handles PUT /api/user/password
 func UpdateUserPassword(c *gin.Context) {
 	userID := middleware.GetUserID(c)
@@ -412,16 +929,23 @@ func UpdateUserEmail(c *gin.Context) {
 		return
 	}
 
-	response := models.UserResponse{
-		ID:       updatedUser.ID,
-		Username: updatedUser.Username,
-		Email:    updatedUser.Email,
-		Avatar:   updatedUser.Avatar,
-	}
+	response := userResponse(updatedUser)
 
 	c.JSON(http.StatusOK, response)
 }
 
+// userResponse converts a user into the data returned in API responses
+func userResponse(user *models.User) models.UserResponse {
+	return models.UserResponse{
+		ID:           user.ID,
+		Username:     user.Username,
+		Email:        user.Email,
+		Avatar:       user.Avatar,
+		BaseCurrency: user.BaseCurrency,
+		Timezone:     user.Timezone,
+	}
+}
+
 // Login handles POST /api/auth/login
 func Login(c *gin.Context) {
 	var req models.LoginRequest
@@ -463,22 +987,51 @@ func Login(c *gin.Context) {
 
 	response := models.AuthResponse{
 		Token: token,
-		User: models.UserResponse{
-			ID:       user.ID,
-			Username: user.Username,
-			Email:    user.Email,
-			Avatar:   user.Avatar,
-		},
+		User:  userResponse(user),
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-// getMonthBounds returns the start and end times for a given month
-func getMonthBounds(year, month int) (time.Time, time.Time) {
-	start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+// statisticsPeriod determines the period of a statistics request from the year, month and period query parameters.
+// Periods follow the calendar of the user's timezone; the bounds are returned in UTC.
+func statisticsPeriod(c *gin.Context) (startTime, endTime time.Time, err error) {
+	loc, err := database.GetUserLocation(middleware.GetUserID(c))
+	if err != nil {
+		return startTime, endTime, err
+	}
+	now := time.Now().In(loc)
+	year, _ := strconv.Atoi(c.DefaultQuery("year", strconv.Itoa(now.Year())))
+
+	// Check if month parameter is provided
+	monthStr := c.Query("month")
+	periodType := c.DefaultQuery("period", "month") // "month" or "year", default to "month" for backward compatibility
+
+	// Determine time bounds based on parameters
+	if monthStr != "" {
+		// If month is explicitly provided, use monthly statistics (backward compatibility)
+		month, _ := strconv.Atoi(monthStr)
+		if month == 0 {
+			month = int(now.Month())
+		}
+		startTime, endTime = getMonthBounds(year, month, loc)
+	} else if periodType == "year" {
+		// If period=year is specified, use yearly statistics
+		startTime, endTime = getYearBounds(year, loc)
+	} else {
+		// Default to current month for backward compatibility
+		month := int(now.Month())
+		startTime, endTime = getMonthBounds(year, month, loc)
+	}
+
+	return startTime, endTime, nil
+}
+
+// getMonthBounds returns the start and end times in UTC of a given month in loc
+func getMonthBounds(year, month int, loc *time.Location) (time.Time, time.Time) {
+	start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, loc)
 	end := start.AddDate(0, 1, 0)
-	return start, end
+	return start.UTC(), end.UTC()
 }
 
 // Asset-related handlers
@@ -506,10 +1059,17 @@ func CreateAsset(c *gin.Context) {
 		return
 	}
 
+	currency, err := resolveCurrency(userID, req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	asset := &models.Asset{
 		UserID:     userID,
 		Name:       req.Name,
 		CategoryID: &req.CategoryID,
+		Currency:   currency,
 	}
 
 	if err := database.CreateAsset(asset); err != nil {
@@ -543,7 +1103,30 @@ func DeleteAsset(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Asset deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Asset moved to trash"})
+}
+
+// GetAssetLedger handles GET /api/assets/:id/ledger
+func GetAssetLedger(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	assetID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid asset ID"})
+		return
+	}
+
+	ledger, err := database.GetAssetLedger(assetID, userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get asset ledger: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ledger)
 }
 
 // CreateAssetRecord handles POST /api/assets/:id/records
@@ -614,7 +1197,7 @@ func DeleteAssetRecord(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Asset record deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Asset record moved to trash"})
 }
 
 // UpdateAssetRecord handles PUT /api/assets/:id/records/:recordId
@@ -784,11 +1367,11 @@ func DeleteAssetCategory(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Asset category deleted successfully"})
 }
 
-// getYearBounds returns the start and end time for a given year
-func getYearBounds(year int) (time.Time, time.Time) {
-	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
-	end := time.Date(year, time.December, 31, 23, 59, 59, 0, time.UTC)
-	return start, end
+// getYearBounds returns the start and end time in UTC of a given year in loc
+func getYearBounds(year int, loc *time.Location) (time.Time, time.Time) {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+	end := start.AddDate(1, 0, 0)
+	return start.UTC(), end.UTC()
 }
 
 // CreateTransactionCategory handles POST /api/categories
@@ -906,15 +1489,28 @@ func CreateAutoTransaction(c *gin.Context) {
 		return
 	}
 
+	asset, currency, err := resolveAssetCurrency(userID, req.AssetID, req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Calculate next execution date based on frequency
-	nextExecutionDate := calculateNextExecutionDate(req.Frequency, req.DayOfMonth, req.DayOfWeek)
+	loc, err := database.GetUserLocation(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	nextExecutionDate := calculateNextExecutionDate(req.Frequency, req.DayOfMonth, req.DayOfWeek, loc)
 
 	autoTransaction := models.AutoTransaction{
 		UserID:            userID,
 		Type:              req.Type,
 		Amount:            req.Amount,
+		Currency:          currency,
 		CategoryKey:       req.CategoryKey,
 		Description:       req.Description,
+		AssetID:           assetIDOf(asset),
 		Frequency:         req.Frequency,
 		DayOfMonth:        req.DayOfMonth,
 		DayOfWeek:         req.DayOfWeek,
@@ -948,16 +1544,29 @@ func UpdateAutoTransaction(c *gin.Context) {
 		return
 	}
 
+	asset, currency, err := resolveAssetCurrency(userID, req.AssetID, req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Calculate next execution date based on frequency
-	nextExecutionDate := calculateNextExecutionDate(req.Frequency, req.DayOfMonth, req.DayOfWeek)
+	loc, err := database.GetUserLocation(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	nextExecutionDate := calculateNextExecutionDate(req.Frequency, req.DayOfMonth, req.DayOfWeek, loc)
 
 	autoTransaction := models.AutoTransaction{
 		ID:                id,
 		UserID:            userID,
 		Type:              req.Type,
 		Amount:            req.Amount,
+		Currency:          currency,
 		CategoryKey:       req.CategoryKey,
 		Description:       req.Description,
+		AssetID:           assetIDOf(asset),
 		Frequency:         req.Frequency,
 		DayOfMonth:        req.DayOfMonth,
 		DayOfWeek:         req.DayOfWeek,
@@ -990,7 +1599,7 @@ func DeleteAutoTransaction(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Auto transaction deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Auto transaction moved to trash"})
 }
 
 // ToggleAutoTransaction handles PUT /api/auto-transactions/:id/toggle
@@ -1012,44 +1621,45 @@ func ToggleAutoTransaction(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Auto transaction status toggled successfully"})
 }
 
-// calculateNextExecutionDate calculates the next execution date based on frequency
-func calculateNextExecutionDate(frequency string, dayOfMonth, dayOfWeek int) time.Time {
-	now := time.Now()
+// calculateNextExecutionDate calculates the next execution date based on frequency.
+// Days are counted in loc, the user's timezone, and the result is returned in UTC.
+func calculateNextExecutionDate(frequency string, dayOfMonth, dayOfWeek int, loc *time.Location) time.Time {
+	now := time.Now().In(loc)
 
 	switch frequency {
 	case "daily":
-		return now.AddDate(0, 0, 1)
+		return now.AddDate(0, 0, 1).UTC()
 	case "weekly":
 		// Find next occurrence of the specified day of week
 		daysUntilNext := (dayOfWeek - int(now.Weekday()) + 7) % 7
 		if daysUntilNext == 0 {
 			daysUntilNext = 7 // If it's the same day, schedule for next week
 		}
-		return now.AddDate(0, 0, daysUntilNext)
+		return now.AddDate(0, 0, daysUntilNext).UTC()
 	case "monthly":
 		// Find next occurrence of the specified day of month
 		nextMonth := now.AddDate(0, 1, 0)
 		// Handle edge cases like day 31 in February
 		if dayOfMonth > 28 {
 			// Get last day of next month
-			lastDayOfMonth := time.Date(nextMonth.Year(), nextMonth.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+			lastDayOfMonth := time.Date(nextMonth.Year(), nextMonth.Month()+1, 0, 0, 0, 0, 0, loc).Day()
 			if dayOfMonth > lastDayOfMonth {
 				dayOfMonth = lastDayOfMonth
 			}
 		}
-		return time.Date(nextMonth.Year(), nextMonth.Month(), dayOfMonth, now.Hour(), now.Minute(), now.Second(), 0, now.Location())
+		return time.Date(nextMonth.Year(), nextMonth.Month(), dayOfMonth, now.Hour(), now.Minute(), now.Second(), 0, loc).UTC()
 	case "yearly":
 		// Schedule for next year, same month and day
 		nextYear := now.AddDate(1, 0, 0)
 		if dayOfMonth > 28 {
 			// Handle leap year edge case for February 29th
-			lastDayOfMonth := time.Date(nextYear.Year(), nextYear.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+			lastDayOfMonth := time.Date(nextYear.Year(), nextYear.Month()+1, 0, 0, 0, 0, 0, loc).Day()
 			if dayOfMonth > lastDayOfMonth {
 				dayOfMonth = lastDayOfMonth
 			}
 		}
-		return time.Date(nextYear.Year(), nextYear.Month(), dayOfMonth, now.Hour(), now.Minute(), now.Second(), 0, now.Location())
+		return time.Date(nextYear.Year(), nextYear.Month(), dayOfMonth, now.Hour(), now.Minute(), now.Second(), 0, loc).UTC()
 	default:
-		return now.AddDate(0, 0, 1) // Default to daily
+		return now.AddDate(0, 0, 1).UTC() // Default to daily
 	}
 }
@@ -0,0 +1,462 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mini-money/internal/database"
+	"mini-money/internal/importer"
+	"mini-money/internal/middleware"
+	"mini-money/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportSize is the largest file accepted for import
+const maxImportSize = 20 << 20
+
+// readImportFile reads the uploaded "file" of an import request.
+// On failure it responds to the request and returns false.
+func readImportFile(c *gin.Context) ([]byte, bool) {
+	return readUploadedFile(c, maxImportSize)
+}
+
+// multipartOverhead allows for the form fields and part headers sent along with an uploaded file
+const multipartOverhead = 1 << 20
+
+// parseUpload parses the multipart form of a request with a file of up to maxSize bytes, reading
+// no more of a larger request than that. It must be called before any form field is read.
+// On failure it responds to the request and returns false.
+func parseUpload(c *gin.Context, maxSize int64) bool {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+multipartOverhead)
+	// Like gin, up to 32 MB of the form is kept in memory and the rest in temporary files
+	err := c.Request.ParseMultipartForm(32 << 20)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File must not be larger than %d bytes", maxSize)})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid multipart form: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+// readUploadedFile reads the uploaded "file" of a request if it isn't larger than maxSize.
+// On failure it responds to the request and returns false.
+func readUploadedFile(c *gin.Context, maxSize int64) ([]byte, bool) {
+	if !parseUpload(c, maxSize) {
+		return nil, false
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+		return nil, false
+	}
+	if fileHeader.Size > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File must not be larger than %d bytes", maxSize)})
+		return nil, false
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return nil, false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return nil, false
+	}
+	return data, true
+}
+
+// importOptions are the form fields shared by all import requests
+type importOptions struct {
+	AssetID *int64 // asset account the imported money moves through
+	Commit  bool   // save the transactions instead of only previewing them
+	// SuggestCategories picks categories for records without one from their descriptions
+	SuggestCategories bool
+	// OpeningBalance and Balance are the balances a statement opens and closes with, saved as
+	// records of the asset
+	OpeningBalance *models.AssetRecord
+	Balance        *models.AssetRecord
+}
+
+// parseImportOptions reads the assetId and commit form fields.
+// On failure it responds to the request and returns false.
+func parseImportOptions(c *gin.Context) (importOptions, bool) {
+	var options importOptions
+	if value := c.PostForm("assetId"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid asset ID"})
+			return options, false
+		}
+		options.AssetID = &id
+	}
+	if value := c.PostForm("commit"); value != "" {
+		commit, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "commit must be true or false"})
+			return options, false
+		}
+		options.Commit = commit
+	}
+	return options, true
+}
+
+// parseCategoryMapping reads the optional "categories" form field, a JSON map of the categories
+// in a file to category keys. On failure it responds to the request and returns false.
+func parseCategoryMapping(c *gin.Context) (map[string]string, bool) {
+	var mapping map[string]string
+	if value := c.PostForm("categories"); value != "" {
+		if err := json.Unmarshal([]byte(value), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid categories: " + err.Error()})
+			return nil, false
+		}
+	}
+	return mapping, true
+}
+
+// ImportCSV handles POST /api/import/csv
+// The request is a multipart form with the CSV "file", an optional JSON "mapping" of columns to
+// fields (guessed from the header row when omitted), "header" (default true), "assetId" and
+// "commit". Without commit it returns a preview with the problems of each row. With commit all
+// rows are saved in one go, and nothing is saved if any row has a problem.
+func ImportCSV(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	data, ok := readImportFile(c)
+	if !ok {
+		return
+	}
+	options, ok := parseImportOptions(c)
+	if !ok {
+		return
+	}
+	header := true
+	if value := c.PostForm("header"); value != "" {
+		var err error
+		if header, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "header must be true or false"})
+			return
+		}
+	}
+
+	table, err := importer.ParseCSV(data, header)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CSV file: " + err.Error()})
+		return
+	}
+
+	var mapping models.ImportMapping
+	if value := c.PostForm("mapping"); value != "" {
+		if err := json.Unmarshal([]byte(value), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mapping: " + err.Error()})
+			return
+		}
+	} else {
+		mapping = importer.GuessMapping(table.Headers)
+	}
+
+	result := models.ImportResult{
+		Format:    "csv",
+		Encoding:  table.Encoding,
+		Delimiter: table.Delimiter,
+		Headers:   table.Headers,
+		Mapping:   &mapping,
+	}
+
+	loc, err := database.GetUserLocation(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	records, err := table.Records(mapping, loc)
+	if err != nil {
+		// The mapping doesn't fit the file; return the headers so the client can fix it
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mapping: " + err.Error(), "headers": table.Headers, "mapping": mapping})
+		return
+	}
+
+	finishImport(c, userID, records, mapping.Categories, options, &result)
+}
+
+// ImportAlipay handles POST /api/import/alipay
+// The request is a multipart form with the bill CSV exported from Alipay as "file", and optional
+// "assetId", "commit" and "categories", a JSON map of Alipay categories to category keys.
+func ImportAlipay(c *gin.Context) {
+	importBill(c, "alipay", importer.ParseAlipay)
+}
+
+// ImportWechat handles POST /api/import/wechat
+// The request is a multipart form with the bill CSV exported from WeChat Pay as "file", and
+// optional "assetId", "commit" and "categories" like for Alipay.
+func ImportWechat(c *gin.Context) {
+	importBill(c, "wechat", importer.ParseWechat)
+}
+
+// importBill imports the bill of a payment app read by parse. Unfinished entries and moves between
+// the user's own accounts are skipped, as are entries imported before.
+func importBill(c *gin.Context, format string, parse func([]byte, *time.Location) ([]importer.Record, error)) {
+	userID := middleware.GetUserID(c)
+
+	data, ok := readImportFile(c)
+	if !ok {
+		return
+	}
+	options, ok := parseImportOptions(c)
+	if !ok {
+		return
+	}
+	options.SuggestCategories = true
+	categoryMapping, ok := parseCategoryMapping(c)
+	if !ok {
+		return
+	}
+
+	loc, err := database.GetUserLocation(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	records, err := parse(data, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill: " + err.Error()})
+		return
+	}
+
+	finishImport(c, userID, records, categoryMapping, options, &models.ImportResult{Format: format})
+}
+
+// ImportOFX handles POST /api/import/ofx
+// The request is a multipart form with the OFX statement as "file", and optional "assetId",
+// "commit" and "categories". When bound to an asset, the ledger balance of the statement is saved
+// as the asset's record for its day.
+func ImportOFX(c *gin.Context) {
+	importStatement(c, "ofx", importer.ParseOFX)
+}
+
+// ImportQIF handles POST /api/import/qif
+// The request is like for OFX, with an optional "dateFormat" (a Go time layout) for files whose
+// dates aren't in the American month/day/year order.
+func ImportQIF(c *gin.Context) {
+	layout := c.PostForm("dateFormat")
+	importStatement(c, "qif", func(data []byte, loc *time.Location) (*importer.Statement, error) {
+		return importer.ParseQIF(data, layout, loc)
+	})
+}
+
+// ImportMT940 handles POST /api/import/mt940
+// The request is like for OFX. The opening and closing balances of the statement are both saved
+// as records of the asset.
+func ImportMT940(c *gin.Context) {
+	importStatement(c, "mt940", importer.ParseMT940)
+}
+
+// ImportCamt053 handles POST /api/import/camt053
+// The request is like for MT940, with an ISO 20022 camt.053 XML statement as the file.
+func ImportCamt053(c *gin.Context) {
+	importStatement(c, "camt053", importer.ParseCamt053)
+}
+
+// importStatement imports a bank or credit card statement read by parse. Entries imported before
+// are skipped, so a statement can be imported again after it was extended.
+func importStatement(c *gin.Context, format string, parse func([]byte, *time.Location) (*importer.Statement, error)) {
+	userID := middleware.GetUserID(c)
+
+	data, ok := readImportFile(c)
+	if !ok {
+		return
+	}
+	options, ok := parseImportOptions(c)
+	if !ok {
+		return
+	}
+	options.SuggestCategories = true
+	categoryMapping, ok := parseCategoryMapping(c)
+	if !ok {
+		return
+	}
+
+	var asset *models.Asset
+	if options.AssetID != nil {
+		assets, err := database.GetAssetsByUserID(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get assets: " + err.Error()})
+			return
+		}
+		for i := range assets {
+			if assets[i].ID == *options.AssetID {
+				asset = &assets[i]
+			}
+		}
+		if asset == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+			return
+		}
+	}
+
+	loc, err := database.GetUserLocation(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	statement, err := parse(data, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid statement: " + err.Error()})
+		return
+	}
+
+	if asset != nil {
+		// Statements show money owed as negative, liabilities record it as a positive amount
+		liability, err := database.IsLiabilityAsset(asset.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		options.OpeningBalance = balanceRecord(asset.ID, statement.Opening, liability, loc)
+		options.Balance = balanceRecord(asset.ID, statement.Balance, liability, loc)
+	}
+
+	finishImport(c, userID, statement.Records, categoryMapping, options, &models.ImportResult{Format: format})
+}
+
+// balanceRecord converts a statement balance to the record of an asset for the day of the balance
+func balanceRecord(assetID int64, balance *importer.Balance, liability bool, loc *time.Location) *models.AssetRecord {
+	if balance == nil {
+		return nil
+	}
+	amount := balance.Amount
+	if liability {
+		amount = -amount
+	}
+	return &models.AssetRecord{
+		AssetID: assetID,
+		Date:    balance.Date.In(loc).Format("2006-01-02"),
+		Amount:  amount,
+	}
+}
+
+// finishImport turns parsed records into transactions and responds with the result.
+// Records are validated like new transactions; with options.Commit they are all saved in a single
+// database transaction, or none are when any record has a problem. Records with an external ID
+// are saved under result.Format as their source and skipped when they were imported before.
+func finishImport(c *gin.Context, userID int64, records []importer.Record, categoryMapping map[string]string, options importOptions, result *models.ImportResult) {
+	categories, err := database.GetTransactionCategories(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get categories: " + err.Error()})
+		return
+	}
+	if options.SuggestCategories {
+		importer.SuggestCategories(records, categories)
+	}
+	importer.ResolveCategories(records, categories, categoryMapping)
+
+	imported, err := database.GetImportedIDs(userID, result.Format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	seen := make(map[string]int) // external ID → line it was first seen on
+
+	result.OpeningBalance = options.OpeningBalance
+	result.Balance = options.Balance
+	result.Rows = make([]models.ImportRow, 0, len(records))
+	for _, record := range records {
+		row := models.ImportRow{Line: record.Line, Errors: record.Errors, Skipped: record.Skip}
+		if id := record.ExternalID; id != "" && row.Skipped == "" {
+			if imported[id] {
+				row.Skipped = "imported before"
+			} else if line, ok := seen[id]; ok {
+				row.Skipped = fmt.Sprintf("same entry as line %d", line)
+			} else {
+				seen[id] = record.Line
+			}
+		}
+		if row.Skipped != "" {
+			row.Errors = nil
+			result.Skipped++
+			result.Rows = append(result.Rows, row)
+			continue
+		}
+
+		if len(row.Errors) == 0 {
+			transaction, err := buildNewTransaction(userID, addTransactionRequest{
+				Description: record.Description,
+				Amount:      record.Amount,
+				Currency:    record.Currency,
+				Type:        record.Type,
+				CategoryKey: record.CategoryKey,
+				Date:        record.Date.Format(time.RFC3339Nano),
+				AssetID:     options.AssetID,
+			})
+			if err != nil {
+				row.Errors = append(row.Errors, err.Error())
+			} else {
+				if record.ExternalID != "" {
+					transaction.ExternalSource = result.Format
+					transaction.ExternalID = record.ExternalID
+				}
+				row.Transaction = &transaction
+			}
+		}
+		if len(row.Errors) > 0 {
+			result.Invalid++
+		} else {
+			result.Valid++
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	if !options.Commit {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	if result.Invalid > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Some rows have problems, nothing was imported", "result": result})
+		return
+	}
+
+	batch, err := database.BeginTransactionBatch(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer batch.Rollback()
+	for _, row := range result.Rows {
+		if row.Transaction == nil {
+			continue
+		}
+		if err := batch.Insert(row.Transaction); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to import line %d: %v", row.Line, err)})
+			return
+		}
+	}
+	// The closing balance is saved last so it wins when both fall on the same day
+	for _, record := range []*models.AssetRecord{options.OpeningBalance, options.Balance} {
+		if record == nil {
+			continue
+		}
+		if err := batch.SaveAssetRecord(record); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save the statement balance: " + err.Error()})
+			return
+		}
+	}
+	if err := batch.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import transactions: " + err.Error()})
+		return
+	}
+
+	result.Committed = true
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mini-money/internal/database"
+	"mini-money/internal/middleware"
+	"mini-money/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// resolvePayee picks the payee of a new transaction: the given one after checking that the
+// user owns it, none for payee ID 0, otherwise the payee matching the description
+func resolvePayee(userID int64, payeeID *int64, description string) (*int64, error) {
+	if payeeID == nil {
+		return database.MatchPayee(userID, description)
+	}
+	if *payeeID == 0 {
+		return nil, nil
+	}
+	if _, err := database.GetPayeeByID(*payeeID, userID); err != nil {
+		return nil, err
+	}
+	return payeeID, nil
+}
+
+// payeeFromRequest builds a payee from a create or update request
+func payeeFromRequest(c *gin.Context, userID int64) (*models.Payee, bool) {
+	var req models.PayeeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	payee := &models.Payee{
+		UserID:  userID,
+		Name:    strings.TrimSpace(req.Name),
+		Aliases: []models.PayeeAlias{},
+	}
+	if payee.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return nil, false
+	}
+	for _, alias := range req.Aliases {
+		alias.ID = 0
+		alias.Pattern = strings.TrimSpace(alias.Pattern)
+		if alias.Pattern == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Alias pattern is required"})
+			return nil, false
+		}
+		payee.Aliases = append(payee.Aliases, alias)
+	}
+	return payee, true
+}
+
+// GetPayees handles GET /api/payees
+func GetPayees(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	payees, err := database.GetPayees(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get payees: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, payees)
+}
+
+// CreatePayee handles POST /api/payees
+func CreatePayee(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	payee, ok := payeeFromRequest(c, userID)
+	if !ok {
+		return
+	}
+
+	err := database.CreatePayee(payee)
+	if errors.Is(err, database.ErrPayeeExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payee: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, payee)
+}
+
+// UpdatePayee handles PUT /api/payees/:id
+func UpdatePayee(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payee ID"})
+		return
+	}
+
+	payee, ok := payeeFromRequest(c, userID)
+	if !ok {
+		return
+	}
+	payee.ID = id
+
+	err = database.UpdatePayee(payee)
+	switch {
+	case errors.Is(err, database.ErrPayeeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payee not found"})
+		return
+	case errors.Is(err, database.ErrPayeeExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payee: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, payee)
+}
+
+// DeletePayee handles DELETE /api/payees/:id
+func DeletePayee(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payee ID"})
+		return
+	}
+
+	err = database.DeletePayee(id, userID)
+	if errors.Is(err, database.ErrPayeeNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payee not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete payee: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Payee deleted successfully"})
+}
+
+// ApplyPayeeRules handles POST /api/payees/apply
+// It assigns payees to existing transactions by their alias rules; with overwrite=true
+// transactions that already have a payee are matched again too.
+func ApplyPayeeRules(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	overwrite, err := strconv.ParseBool(c.DefaultQuery("overwrite", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "overwrite must be true or false"})
+		return
+	}
+
+	updated, err := database.ApplyPayeeRules(userID, overwrite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply payee rules: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
+}
+
+// GetPayeeStatistics handles GET /api/statistics/payees
+// It accepts the same period parameters as GET /api/statistics.
+func GetPayeeStatistics(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	startTime, endTime, err := statisticsPeriod(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var stats models.PayeeStatistics
+
+	stats.Summary, err = database.GetSummaryForPeriod(userID, startTime, endTime)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get summary: " + err.Error()})
+		return
+	}
+
+	stats.ExpenseBreakdown, err = database.GetPayeeBreakdownForPeriod(userID, "expense", startTime, endTime, stats.Summary.TotalExpense)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get expense breakdown: " + err.Error()})
+		return
+	}
+
+	stats.IncomeBreakdown, err = database.GetPayeeBreakdownForPeriod(userID, "income", startTime, endTime, stats.Summary.TotalIncome)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get income breakdown: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
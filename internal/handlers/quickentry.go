@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"mini-money/internal/database"
+	"mini-money/internal/middleware"
+	"mini-money/internal/quickentry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseTransactionRequest is the JSON body of POST /api/transactions/parse
+type parseTransactionRequest struct {
+	Text   string `json:"text" binding:"required,max=500"`
+	Commit bool   `json:"commit"` // Save the parsed transaction instead of only returning the draft
+}
+
+// ParseTransaction handles POST /api/transactions/parse
+// It turns free text such as "午饭 35 餐饮 昨天" or "salary 12000 income" into a draft
+// transaction, validated like a new one. With commit the transaction is saved right away.
+func ParseTransaction(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req parseTransactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	loc, err := database.GetUserLocation(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	grouped, err := database.GetTransactionCategories(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get categories: " + err.Error()})
+		return
+	}
+	var categories []quickentry.Category
+	for _, categoryType := range []string{"expense", "income"} {
+		for _, category := range grouped[categoryType] {
+			categories = append(categories, quickentry.Category{Key: category.Key, Name: category.Name, Type: categoryType})
+		}
+	}
+
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	parsed, err := quickentry.Parse(req.Text, today, categories)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transaction, err := buildNewTransaction(userID, addTransactionRequest{
+		Description: parsed.Description,
+		Amount:      parsed.Amount,
+		Currency:    parsed.Currency,
+		Type:        parsed.Type,
+		CategoryKey: parsed.CategoryKey,
+		Date:        parsed.Date,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !req.Commit {
+		c.JSON(http.StatusOK, transaction)
+		return
+	}
+	// A draft may leave the category for the user to pick, a saved transaction may not
+	if transaction.CategoryKey == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No category found in text"})
+		return
+	}
+
+	if err := database.InsertTransaction(&transaction); err != nil {
+		if errors.Is(err, database.ErrTagNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, transaction)
+}
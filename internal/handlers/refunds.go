@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"mini-money/internal/database"
+	"mini-money/internal/middleware"
+	"mini-money/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// refundSource looks up the transactions a refund link is checked against. It is implemented
+// by committedTransactions and by *database.TransactionBatch, which sees its own changes.
+type refundSource interface {
+	Get(id int64) (*models.Transaction, error)
+	RefundedAmount(originalID, excludeID int64) (models.Money, error)
+}
+
+// committedTransactions is the refundSource of a user's saved transactions
+type committedTransactions struct {
+	userID int64
+}
+
+// Get retrieves a saved transaction
+func (s committedTransactions) Get(id int64) (*models.Transaction, error) {
+	return database.GetTransactionByID(id, s.userID)
+}
+
+// RefundedAmount sums the saved refunds of an expense, leaving out excludeID
+func (s committedTransactions) RefundedAmount(originalID, excludeID int64) (models.Money, error) {
+	return database.GetRefundedAmount(originalID, s.userID, excludeID)
+}
+
+// validateRefund checks the refund link of a transaction against the user's saved transactions
+func validateRefund(userID int64, t *models.Transaction) error {
+	return checkRefund(committedTransactions{userID}, t)
+}
+
+// checkRefund checks the refund link of a transaction. A refund or reimbursement is
+// an unsplit income in the currency of the expense it pays back, and all refunds of an
+// expense together can't exceed it. An expense that has refunds must stay one that covers them.
+func checkRefund(source refundSource, t *models.Transaction) error {
+	if t.Reimbursable && t.Type != "expense" {
+		return errors.New("only expenses can be reimbursable")
+	}
+
+	if t.OriginalTransactionID == nil {
+		if t.ID == 0 {
+			return nil
+		}
+		refunded, err := source.RefundedAmount(t.ID, 0)
+		if err != nil {
+			return err
+		}
+		if refunded > 0 && (t.Type != "expense" || t.Amount < refunded) {
+			return fmt.Errorf("transaction has %s refunded and must remain an expense of at least that amount", refunded)
+		}
+		return nil
+	}
+
+	if t.Type != "income" {
+		return errors.New("refunds and reimbursements must be income")
+	}
+	if len(t.Splits) > 0 {
+		return errors.New("refunds cannot be split")
+	}
+	if t.Amount <= 0 {
+		return errors.New("refund amount must be positive")
+	}
+	if *t.OriginalTransactionID == t.ID {
+		return errors.New("a transaction cannot refund itself")
+	}
+
+	original, err := source.Get(*t.OriginalTransactionID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("original transaction %d not found", *t.OriginalTransactionID)
+	}
+	if err != nil {
+		return err
+	}
+	if original.Type != "expense" {
+		return errors.New("only expenses can be refunded")
+	}
+	if original.Currency != t.Currency {
+		return fmt.Errorf("refund currency must match the original transaction (%s)", original.Currency)
+	}
+
+	refunded, err := source.RefundedAmount(original.ID, t.ID)
+	if err != nil {
+		return err
+	}
+	if refunded+t.Amount > original.Amount {
+		return fmt.Errorf("refunds cannot exceed the original amount; %s is left to refund", original.Amount-refunded)
+	}
+	return nil
+}
+
+// GetOutstandingReimbursements handles GET /api/reimbursements/outstanding
+func GetOutstandingReimbursements(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	outstanding, err := database.GetOutstandingReimbursements(userID)
+	if errors.Is(err, database.ErrMissingExchangeRate) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, outstanding)
+}
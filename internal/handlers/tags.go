@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mini-money/internal/database"
+	"mini-money/internal/middleware"
+	"mini-money/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTags handles GET /api/tags
+func GetTags(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	tags, err := database.GetTags(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tags: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// CreateTag handles POST /api/tags
+func CreateTag(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req models.TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag := &models.Tag{
+		UserID: userID,
+		Name:   strings.TrimSpace(req.Name),
+		Color:  req.Color,
+	}
+	if tag.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return
+	}
+
+	err := database.CreateTag(tag)
+	if errors.Is(err, database.ErrTagExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tag: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, tag)
+}
+
+// UpdateTag handles PUT /api/tags/:id
+func UpdateTag(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	var req models.TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag := &models.Tag{
+		ID:     id,
+		UserID: userID,
+		Name:   strings.TrimSpace(req.Name),
+		Color:  req.Color,
+	}
+	if tag.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return
+	}
+
+	err = database.UpdateTag(tag)
+	switch {
+	case errors.Is(err, database.ErrTagNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	case errors.Is(err, database.ErrTagExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tag: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag handles DELETE /api/tags/:id
+func DeleteTag(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	err = database.DeleteTag(id, userID)
+	if errors.Is(err, database.ErrTagNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tag: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted successfully"})
+}
+
+// GetTagStatistics handles GET /api/statistics/tags
+// It accepts the same period parameters as GET /api/statistics.
+func GetTagStatistics(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	startTime, endTime, err := statisticsPeriod(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var stats models.TagStatistics
+
+	stats.Summary, err = database.GetSummaryForPeriod(userID, startTime, endTime)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get summary: " + err.Error()})
+		return
+	}
+
+	stats.ExpenseBreakdown, err = database.GetTagBreakdownForPeriod(userID, "expense", startTime, endTime, stats.Summary.TotalExpense)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get expense breakdown: " + err.Error()})
+		return
+	}
+
+	stats.IncomeBreakdown, err = database.GetTagBreakdownForPeriod(userID, "income", startTime, endTime, stats.Summary.TotalIncome)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get income breakdown: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
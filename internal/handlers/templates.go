@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mini-money/internal/database"
+	"mini-money/internal/middleware"
+	"mini-money/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// templateFromRequest builds a transaction template from a create or update request.
+// The currency defaults to the one of the asset, otherwise the base currency.
+func templateFromRequest(c *gin.Context, userID int64) (*models.TransactionTemplate, bool) {
+	var req models.TransactionTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	description := strings.TrimSpace(req.Description)
+	if description == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Description is required"})
+		return nil, false
+	}
+
+	asset, currency, err := resolveAssetCurrency(userID, req.AssetID, req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	return &models.TransactionTemplate{
+		UserID:      userID,
+		Description: description,
+		Amount:      req.Amount,
+		Currency:    currency,
+		Type:        req.Type,
+		CategoryKey: req.CategoryKey,
+		AssetID:     assetIDOf(asset),
+	}, true
+}
+
+// templateID parses the template ID of the request path
+func templateID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// GetTemplates handles GET /api/templates
+func GetTemplates(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	templates, err := database.GetTemplates(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get templates: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// CreateTemplate handles POST /api/templates
+func CreateTemplate(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	template, ok := templateFromRequest(c, userID)
+	if !ok {
+		return
+	}
+
+	if err := database.CreateTemplate(template); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create template: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// UpdateTemplate handles PUT /api/templates/:id
+func UpdateTemplate(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, ok := templateID(c)
+	if !ok {
+		return
+	}
+
+	template, ok := templateFromRequest(c, userID)
+	if !ok {
+		return
+	}
+	template.ID = id
+
+	err := database.UpdateTemplate(template)
+	if errors.Is(err, database.ErrTemplateNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update template: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// DeleteTemplate handles DELETE /api/templates/:id
+func DeleteTemplate(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, ok := templateID(c)
+	if !ok {
+		return
+	}
+
+	err := database.DeleteTemplate(id, userID)
+	if errors.Is(err, database.ErrTemplateNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete template: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted successfully"})
+}
+
+// ApplyTemplate handles POST /api/templates/:id/apply
+// It records a transaction dated now from the template and counts the use.
+func ApplyTemplate(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, ok := templateID(c)
+	if !ok {
+		return
+	}
+
+	template, err := database.GetTemplateByID(id, userID)
+	if errors.Is(err, database.ErrTemplateNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get template: " + err.Error()})
+		return
+	}
+
+	// Validated like a new transaction, since the asset may have been deleted since
+	transaction, err := buildNewTransaction(userID, addTransactionRequest{
+		Description: template.Description,
+		Amount:      template.Amount,
+		Currency:    template.Currency,
+		Type:        template.Type,
+		CategoryKey: template.CategoryKey,
+		AssetID:     template.AssetID,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.InsertTransaction(&transaction); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.RecordTemplateUse(id, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record template use: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, transaction)
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"mini-money/internal/database"
+	"mini-money/internal/middleware"
+	"mini-money/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBatchOperations limits the size of a single batch request
+const maxBatchOperations = 500
+
+// batchRequest is the body of POST /api/transactions/batch
+type batchRequest struct {
+	// Atomic applies all operations or none of them; it defaults to true.
+	// Otherwise failed operations are skipped and the rest are saved.
+	Atomic     *bool            `json:"atomic"`
+	Operations []batchOperation `json:"operations" binding:"required"`
+}
+
+// batchOperation is one change in a batch. Op is "create", "delete", "recategorize" or "retag".
+type batchOperation struct {
+	Op          string                 `json:"op"`
+	ID          int64                  `json:"id"`          // Target of delete, recategorize and retag
+	Transaction *addTransactionRequest `json:"transaction"` // New transaction for create
+	CategoryKey string                 `json:"categoryKey"` // New category for recategorize
+	// TagIDs replaces the tags for retag; AddTagIDs and RemoveTagIDs change them incrementally
+	TagIDs       *[]int64 `json:"tagIds"`
+	AddTagIDs    []int64  `json:"addTagIds"`
+	RemoveTagIDs []int64  `json:"removeTagIds"`
+}
+
+// batchResult reports the outcome of one operation of a batch
+type batchResult struct {
+	Index       int                 `json:"index"`
+	Op          string              `json:"op"`
+	Status      string              `json:"status"` // "ok", "failed" or "rolled_back" when an atomic batch was abandoned
+	ID          int64               `json:"id,omitempty"`
+	Transaction *models.Transaction `json:"transaction,omitempty"`
+	Error       string              `json:"error,omitempty"`
+}
+
+// BatchTransactions handles POST /api/transactions/batch
+// All operations run inside one SQL transaction, each behind a savepoint so every
+// operation gets its own result.
+func BatchTransactions(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req batchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Operations) == 0 || len(req.Operations) > maxBatchOperations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("operations must contain between 1 and %d items", maxBatchOperations)})
+		return
+	}
+	atomic := req.Atomic == nil || *req.Atomic
+
+	// Validate new transactions before the write transaction starts
+	results := make([]batchResult, len(req.Operations))
+	created := make([]models.Transaction, len(req.Operations))
+	for i, op := range req.Operations {
+		results[i] = batchResult{Index: i, Op: op.Op, ID: op.ID, Status: "ok"}
+		switch op.Op {
+		case "create":
+			if op.Transaction == nil {
+				results[i].fail(errors.New("transaction is required"))
+				continue
+			}
+			t, err := buildNewTransaction(userID, *op.Transaction)
+			if err != nil {
+				results[i].fail(err)
+				continue
+			}
+			created[i] = t
+		case "delete", "recategorize", "retag":
+		default:
+			results[i].fail(errors.New("op must be one of create, delete, recategorize or retag"))
+		}
+	}
+
+	batch, err := database.BeginTransactionBatch(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer batch.Rollback()
+
+	failed := 0
+	for i, op := range req.Operations {
+		if results[i].Status == "failed" {
+			failed++
+			continue
+		}
+
+		if err := batch.Savepoint(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		err := applyBatchOperation(batch, op, &created[i], &results[i])
+		if err != nil {
+			results[i].fail(err)
+			failed++
+			err = batch.RollbackToSavepoint()
+		} else {
+			err = batch.ReleaseSavepoint()
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	committed := !atomic || failed == 0
+	succeeded := len(results) - failed
+	if committed {
+		if err := batch.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save batch: " + err.Error()})
+			return
+		}
+	} else {
+		for i := range results {
+			if results[i].Status == "ok" {
+				results[i].Status = "rolled_back"
+				results[i].Transaction = nil
+				if results[i].Op == "create" {
+					results[i].ID = 0
+				}
+			}
+		}
+		succeeded = 0
+	}
+
+	status := http.StatusOK
+	if !committed {
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, gin.H{
+		"committed": committed,
+		"succeeded": succeeded,
+		"failed":    failed,
+		"results":   results,
+	})
+}
+
+// fail marks the operation as failed
+func (r *batchResult) fail(err error) {
+	r.Status = "failed"
+	r.Error = err.Error()
+}
+
+// applyBatchOperation runs one validated operation inside the batch
+func applyBatchOperation(batch *database.TransactionBatch, op batchOperation, newTransaction *models.Transaction, result *batchResult) error {
+	if op.Op == "create" {
+		// Earlier operations may have refunded or deleted the original expense
+		if err := checkRefund(batch, newTransaction); err != nil {
+			return err
+		}
+		if err := batch.Insert(newTransaction); err != nil {
+			return err
+		}
+		result.ID = newTransaction.ID
+		result.Transaction = newTransaction
+		return nil
+	}
+
+	if op.Op == "delete" {
+		found, err := batch.Delete(op.ID)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return errors.New("transaction not found")
+		}
+		return nil
+	}
+
+	t, err := batch.Get(op.ID)
+	if err == sql.ErrNoRows {
+		return errors.New("transaction not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	switch op.Op {
+	case "recategorize":
+		if op.CategoryKey == "" {
+			return errors.New("categoryKey is required")
+		}
+		if t.Type == "transfer" {
+			return errors.New("transfers have no category")
+		}
+		if len(t.Splits) > 0 {
+			return errors.New("split transactions are categorized by their splits; edit the splits instead")
+		}
+		t.CategoryKey = op.CategoryKey
+	case "retag":
+		if op.TagIDs == nil && len(op.AddTagIDs) == 0 && len(op.RemoveTagIDs) == 0 {
+			return errors.New("tagIds, addTagIds or removeTagIds is required")
+		}
+		t.Tags = retag(t.Tags, op)
+	}
+
+	if err := batch.Update(t); err != nil {
+		return err
+	}
+	result.Transaction = t
+	return nil
+}
+
+// retag computes the tags of a transaction after a retag operation
+func retag(current []models.Tag, op batchOperation) []models.Tag {
+	var ids []int64
+	if op.TagIDs != nil {
+		ids = append(ids, *op.TagIDs...)
+	} else {
+		for _, tag := range current {
+			ids = append(ids, tag.ID)
+		}
+	}
+	ids = append(ids, op.AddTagIDs...)
+
+	remove := make(map[int64]bool, len(op.RemoveTagIDs))
+	for _, id := range op.RemoveTagIDs {
+		remove[id] = true
+	}
+	kept := ids[:0]
+	for _, id := range ids {
+		if !remove[id] {
+			kept = append(kept, id)
+		}
+	}
+	return tagsFromIDs(kept)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mini-money/internal/config"
+	"mini-money/internal/database"
+	"mini-money/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// trashConfig holds how long deleted items are kept
+var trashConfig config.TrashConfig
+
+// SetTrashConfig configures the retention period reported for trashed items
+func SetTrashConfig(cfg config.TrashConfig) {
+	trashConfig = cfg
+}
+
+// GetTrash handles GET /api/trash
+func GetTrash(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	items, err := database.GetTrash(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if trashConfig.RetentionDays > 0 {
+		for i := range items {
+			purgeAt := items[i].DeletedAt.AddDate(0, 0, trashConfig.RetentionDays)
+			items[i].PurgeAt = &purgeAt
+		}
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// trashItemID parses the kind and ID of a trash item request.
+// It writes the error response and returns false when the request can't continue.
+func trashItemID(c *gin.Context) (string, int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return "", 0, false
+	}
+	return c.Param("kind"), id, true
+}
+
+// RestoreTrashItem handles POST /api/trash/:kind/:id/restore
+func RestoreTrashItem(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	kind, id, ok := trashItemID(c)
+	if !ok {
+		return
+	}
+
+	restored, err := database.RestoreTrashItem(kind, id, userID)
+	if errors.Is(err, database.ErrInvalidTrashKind) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, database.ErrRestoreConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !restored {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found in trash"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Item restored successfully"})
+}
+
+// PurgeTrashItem handles DELETE /api/trash/:kind/:id
+func PurgeTrashItem(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	kind, id, ok := trashItemID(c)
+	if !ok {
+		return
+	}
+
+	purged, attachments, err := database.PurgeTrashItem(kind, id, userID)
+	if errors.Is(err, database.ErrInvalidTrashKind) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !purged {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found in trash"})
+		return
+	}
+	deleteAttachmentBlobs(attachments)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Item deleted permanently"})
+}
+
+// EmptyTrash handles DELETE /api/trash
+func EmptyTrash(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	purged, attachments, err := database.PurgeTrash(userID, time.Time{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	deleteAttachmentBlobs(attachments)
+
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
@@ -0,0 +1,328 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"mini-money/internal/models"
+	"mini-money/internal/quickentry"
+)
+
+// ErrUnknownFormat is returned for a file that isn't a bill of the expected kind
+var ErrUnknownFormat = errors.New("file is not a bill in the expected format")
+
+// billTable holds the rows below the header row of a bill exported by a payment app
+type billTable struct {
+	columns map[string]int // field → column
+	rows    [][]string
+	lines   []int
+}
+
+// readBill finds the header row of a bill among the lines before it and reads the rows below it,
+// up to a closing line of dashes. columns lists the header names each field may have, in order of
+// preference; the fields in required must all be present.
+func readBill(data []byte, columns map[string][]string, required []string) (*billTable, error) {
+	text, _, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var table *billTable
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+
+		if table == nil {
+			table = billHeader(record, columns, required)
+			continue
+		}
+		if strings.HasPrefix(record[0], "---") {
+			break
+		}
+		if blankRow(record) {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		table.rows = append(table.rows, record)
+		table.lines = append(table.lines, line)
+	}
+	if table == nil {
+		return nil, ErrUnknownFormat
+	}
+	return table, nil
+}
+
+// billHeader returns an empty table if record is the header row of a bill, or nil
+func billHeader(record []string, columns map[string][]string, required []string) *billTable {
+	positions := make(map[string]int, len(record))
+	for i, name := range record {
+		positions[name] = i
+	}
+	table := &billTable{columns: make(map[string]int)}
+	for field, names := range columns {
+		for _, name := range names {
+			if i, ok := positions[name]; ok {
+				table.columns[field] = i
+				break
+			}
+		}
+	}
+	for _, field := range required {
+		if _, ok := table.columns[field]; !ok {
+			return nil
+		}
+	}
+	return table
+}
+
+// value returns a field of a row, or "" when the bill doesn't have it
+func (t *billTable) value(row []string, field string) string {
+	if i, ok := t.columns[field]; ok && i < len(row) {
+		return row[i]
+	}
+	return ""
+}
+
+// billDescription describes a bill entry by its counterparty and the goods paid for
+func billDescription(counterparty, item string) string {
+	if item == "/" || item == counterparty {
+		item = ""
+	}
+	if counterparty == "/" {
+		counterparty = ""
+	}
+	if counterparty != "" && item != "" {
+		return counterparty + " - " + item
+	}
+	return counterparty + item
+}
+
+// billType converts the 收/支 column of a bill; entries that are neither, such as moving money
+// between the user's own accounts, return false
+func billType(direction string) (string, bool) {
+	switch direction {
+	case "收入":
+		return "income", true
+	case "支出":
+		return "expense", true
+	}
+	return "", false
+}
+
+// alipayColumns are the header names of the fields of Alipay bills, covering both the export
+// from the app and the older export from the website
+var alipayColumns = map[string][]string{
+	"time":         {"交易时间", "付款时间"},
+	"created":      {"交易创建时间"},
+	"category":     {"交易分类"},
+	"counterparty": {"交易对方"},
+	"item":         {"商品说明", "商品名称"},
+	"direction":    {"收/支"},
+	"amount":       {"金额", "金额（元）", "金额(元)"},
+	"status":       {"交易状态"},
+	"id":           {"交易订单号", "交易号"},
+	"refunded":     {"成功退款（元）", "成功退款(元)"},
+}
+
+// alipayFinal are the statuses of Alipay entries whose money has actually moved
+var alipayFinal = map[string]bool{"交易成功": true, "支付成功": true, "还款成功": true}
+
+// ParseAlipay reads a bill exported from Alipay (支付宝). Entries that aren't finished, were
+// refunded in full or aren't counted as income or expense are returned with a reason to skip them.
+func ParseAlipay(data []byte, loc *time.Location) ([]Record, error) {
+	table, err := readBill(data, alipayColumns, []string{"counterparty", "direction", "amount", "status"})
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]Record, 0, len(table.rows))
+	for i, row := range table.rows {
+		value := func(field string) string { return table.value(row, field) }
+		r := Record{
+			Line:        table.lines[i],
+			Description: billDescription(value("counterparty"), value("item")),
+			Category:    value("category"),
+			ExternalID:  value("id"),
+		}
+
+		kind, counted := billType(value("direction"))
+		status := value("status")
+		switch {
+		case !counted:
+			r.Skip = "not counted as income or expense"
+		case !alipayFinal[status]:
+			r.Skip = "status " + status
+		}
+		r.Type = kind
+
+		billAmount(&r, value("amount"), value("refunded"))
+		date := value("time")
+		if date == "" {
+			date = value("created")
+		}
+		billDate(&r, date, loc)
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// wechatColumns are the header names of the fields of WeChat Pay bills
+var wechatColumns = map[string][]string{
+	"time":         {"交易时间"},
+	"kind":         {"交易类型"},
+	"counterparty": {"交易对方"},
+	"item":         {"商品"},
+	"direction":    {"收/支"},
+	"amount":       {"金额(元)", "金额（元）", "金额"},
+	"status":       {"当前状态"},
+	"id":           {"交易单号"},
+}
+
+// wechatRefund matches the status of a partly refunded WeChat Pay entry, such as 已退款(￥10.00)
+var wechatRefund = regexp.MustCompile(`已退款\s*[(（]?\s*[¥￥]?\s*([\d,.]+)`)
+
+// wechatUnfinished matches the statuses of WeChat Pay entries whose money hasn't moved or was sent back
+var wechatUnfinished = regexp.MustCompile(`失败|关闭|退还|待|已全额退款`)
+
+// ParseWechat reads a bill exported from WeChat Pay (微信支付). Entries that aren't finished, were
+// refunded in full or aren't counted as income or expense are returned with a reason to skip them.
+func ParseWechat(data []byte, loc *time.Location) ([]Record, error) {
+	table, err := readBill(data, wechatColumns, []string{"time", "counterparty", "direction", "amount", "status"})
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]Record, 0, len(table.rows))
+	for i, row := range table.rows {
+		value := func(field string) string { return table.value(row, field) }
+		r := Record{
+			Line:        table.lines[i],
+			Description: billDescription(value("counterparty"), value("item")),
+			Hint:        value("kind"),
+			ExternalID:  value("id"),
+		}
+
+		kind, counted := billType(value("direction"))
+		status := value("status")
+		switch {
+		case !counted:
+			r.Skip = "not counted as income or expense"
+		case wechatUnfinished.MatchString(status):
+			r.Skip = "status " + status
+		}
+		r.Type = kind
+
+		refunded := ""
+		if m := wechatRefund.FindStringSubmatch(status); m != nil {
+			refunded = m[1]
+		}
+		billAmount(&r, value("amount"), refunded)
+		billDate(&r, value("time"), loc)
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// billAmount sets the amount of a bill entry less the part refunded
+func billAmount(r *Record, amount, refunded string) {
+	value, err := ParseAmount(amount)
+	if err != nil {
+		if r.Skip == "" {
+			r.addError("invalid amount %q", amount)
+		}
+		return
+	}
+	if value < 0 {
+		value = -value
+	}
+	if refund, err := ParseAmount(refunded); err == nil && refund > 0 {
+		value -= refund
+		if value <= 0 && r.Skip == "" {
+			r.Skip = "refunded in full"
+		}
+	}
+	r.Amount = value
+}
+
+// billDate sets the date of a bill entry
+func billDate(r *Record, date string, loc *time.Location) {
+	parsed, err := ParseDate(date, "", loc)
+	if err != nil {
+		if r.Skip == "" {
+			r.addError("invalid date %q", date)
+		}
+		return
+	}
+	r.Date = parsed
+}
+
+// billCategories maps the categories Alipay files entries under to the keys of the default categories
+var billCategories = map[string]string{
+	"餐饮美食": "food",
+	"交通出行": "transport",
+	"日用百货": "daily",
+	"生活服务": "daily",
+	"服饰装扮": "clothing",
+	"数码电器": "digital",
+	"美容美发": "beauty",
+	"住房物业": "housing",
+	"文化休闲": "entertainment",
+	"休闲娱乐": "entertainment",
+	"医疗健康": "medical",
+	"通讯物流": "communication",
+	"充值缴费": "communication",
+	"教育培训": "education",
+	"运动户外": "sports",
+	"母婴亲子": "childcare",
+	"宠物":   "pets",
+	"酒店旅游": "travel",
+	"爱车养车": "car",
+	"转账红包": "social",
+	"商业服务": "office",
+}
+
+// SuggestCategories fills in the category key of records that don't have one yet, from the
+// category the source filed them under or from words in their description
+func SuggestCategories(records []Record, categories map[string][]models.Category) {
+	var candidates []quickentry.Category
+	for _, kind := range []string{"expense", "income"} {
+		for _, c := range categories[kind] {
+			candidates = append(candidates, quickentry.Category{Key: c.Key, Name: c.Name, Type: kind})
+		}
+	}
+	hasCategory := func(kind, key string) bool {
+		for _, c := range candidates {
+			if c.Type == kind && c.Key == key {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := range records {
+		r := &records[i]
+		if r.CategoryKey != "" || r.Skip != "" || len(r.Errors) > 0 {
+			continue
+		}
+		if key, ok := billCategories[r.Category]; ok && hasCategory(r.Type, key) {
+			r.CategoryKey = key
+			continue
+		}
+		r.CategoryKey = quickentry.SuggestCategory(strings.Join([]string{r.Category, r.Hint, r.Description}, " "), r.Type, candidates)
+	}
+}
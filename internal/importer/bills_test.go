@@ -0,0 +1,89 @@
+package importer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"mini-money/internal/models"
+)
+
+func TestParseAlipay(t *testing.T) {
+	records, err := ParseAlipay(readTestdata(t, "alipay.csv"), testLoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(d, h, m, s int) time.Time { return time.Date(2024, 1, d, h, m, s, 0, testLoc) }
+	checkRecords(t, records, []wantRecord{
+		{line: 18, date: at(31, 12, 8, 41), description: "肯德基 - 肯德基(人民广场店)外卖订单", kind: "expense", amount: 4250, category: "餐饮美食", externalID: "2024013122001412345678901234"},
+		{line: 19, date: at(28, 19, 30, 2), description: "滴滴出行 - 快车 人民广场-徐家汇", kind: "expense", amount: 2890, category: "交通出行", externalID: "2024012822001412345678901235"},
+		{line: 20, date: at(20, 10, 15, 33), description: "盒马 - 盒马鲜生订单", kind: "expense", amount: 15000, category: "日用百货", externalID: "2024012022001412345678901236"},
+		{line: 21, date: at(18, 14, 2, 10), description: "李四 - 转账", kind: "income", amount: 8800, category: "转账红包", externalID: "2024011820001412345678901237"},
+		{line: 22, date: at(15, 8, 45, 0), description: "余额宝 - 余额宝-自动转入", amount: 100000, category: "投资理财", externalID: "2024011500001412345678901238", skip: "not counted as income or expense"},
+		{line: 23, date: at(10, 21, 17, 56), description: "优衣库官方旗舰店 - 男装 摇粒绒外套", kind: "expense", amount: 19900, category: "服饰装扮", externalID: "2024011022001412345678901239", skip: "status 交易关闭"},
+	})
+}
+
+func TestParseAlipayWebsite(t *testing.T) {
+	records, err := ParseAlipay(readTestdata(t, "alipay_web.csv"), testLoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, records, []wantRecord{
+		// The refunded part is taken off
+		{line: 6, date: time.Date(2019, 1, 10, 9, 0, 5, 0, testLoc), description: "某某书店 - 图书三本", kind: "expense", amount: 8000, externalID: "2019011022001400001001"},
+		// Without a payment time the entry is dated by when it was created
+		{line: 7, date: time.Date(2019, 1, 15, 20, 11, 0, 0, testLoc), description: "某某数码专营店 - 蓝牙耳机", kind: "expense", externalID: "2019011522001400001002", skip: "refunded in full"},
+	})
+}
+
+func TestParseWechat(t *testing.T) {
+	records, err := ParseWechat(readTestdata(t, "wechat.csv"), testLoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(d, h, m, s int) time.Time { return time.Date(2024, 1, d, h, m, s, 0, testLoc) }
+	checkRecords(t, records, []wantRecord{
+		{line: 18, date: at(31, 8, 15, 22), description: "瑞幸咖啡 - 生椰拿铁", kind: "expense", amount: 990, externalID: "4200002110202401311234567890"},
+		{line: 19, date: at(29, 12, 40, 5), description: "美团 - 美团订单-午餐", kind: "expense", amount: 3000, externalID: "4200002110202401291234567891"},
+		{line: 20, date: at(25, 19, 2, 41), description: "张三", kind: "income", amount: 6666, externalID: "1000039901240125000123456789"},
+		{line: 21, date: at(20, 10, 0, 0), description: "招商银行(1234)", amount: 50000, externalID: "1000050001240120000123456789", skip: "not counted as income or expense"},
+		{line: 22, date: at(18, 18, 30, 12), description: "便利店", kind: "expense", amount: 5290, externalID: "1000039801240118000123456789", skip: "status 已全额退款"},
+		{line: 23, date: at(5, 7, 55, 31), description: "上海地铁 - 乘车码", kind: "expense", amount: 3000, externalID: "4200002110202401051234567892"},
+	})
+	if records[0].Hint != "商户消费" {
+		t.Errorf("Hint = %q, want 商户消费", records[0].Hint)
+	}
+}
+
+func TestParseBillWrongFormat(t *testing.T) {
+	if _, err := ParseAlipay(readTestdata(t, "wechat.csv"), testLoc); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("ParseAlipay of a WeChat bill error = %v, want %v", err, ErrUnknownFormat)
+	}
+	if _, err := ParseWechat(readTestdata(t, "alipay.csv"), testLoc); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("ParseWechat of an Alipay bill error = %v, want %v", err, ErrUnknownFormat)
+	}
+}
+
+func TestSuggestCategories(t *testing.T) {
+	categories := map[string][]models.Category{
+		"expense": {{Key: "food", Name: "餐饮"}, {Key: "transport", Name: "交通"}, {Key: "other_expense", Name: "其他"}},
+		"income":  {{Key: "other_income", Name: "其他"}},
+	}
+	records := []Record{
+		{Type: "expense", Category: "餐饮美食", Description: "肯德基"},
+		{Type: "expense", Category: "日用百货", Description: "盒马"},
+		{Type: "expense", Description: "滴滴出行 - 快车", Hint: "打车"},
+		{Type: "expense", Category: "餐饮美食", CategoryKey: "other_expense"},
+		{Type: "expense", Category: "餐饮美食", Skip: "status 交易关闭"},
+	}
+	SuggestCategories(records, categories)
+
+	// 日用百货 maps to daily, which this user doesn't have, so it is left for ResolveCategories
+	want := []string{"food", "", "transport", "other_expense", ""}
+	for i, key := range want {
+		if records[i].CategoryKey != key {
+			t.Errorf("record %d: CategoryKey = %q, want %q", i, records[i].CategoryKey, key)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"mini-money/internal/models"
+)
+
+// camtDocument is the part of an ISO 20022 camt.053 bank-to-customer statement that is imported
+type camtDocument struct {
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtStatement struct {
+	IBAN     string        `xml:"Acct>Id>IBAN"`
+	Other    string        `xml:"Acct>Id>Othr>Id"`
+	Currency string        `xml:"Acct>Ccy"`
+	Balances []camtBalance `xml:"Bal"`
+	Entries  []camtEntry   `xml:"Ntry"`
+}
+
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+type camtBalance struct {
+	Code        string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount      camtAmount `xml:"Amt"`
+	CreditDebit string     `xml:"CdtDbtInd"`
+	Date        camtDate   `xml:"Dt"`
+}
+
+type camtEntry struct {
+	Reference   string     `xml:"NtryRef"`
+	Amount      camtAmount `xml:"Amt"`
+	CreditDebit string     `xml:"CdtDbtInd"`
+	// Sts holds the code itself up to version 7 and a Cd element from version 8
+	Status struct {
+		Value string `xml:",chardata"`
+		Code  string `xml:"Cd"`
+	} `xml:"Sts"`
+	BookingDate    camtDate      `xml:"BookgDt"`
+	ValueDate      camtDate      `xml:"ValDt"`
+	ServicerRef    string        `xml:"AcctSvcrRef"`
+	Details        []camtDetails `xml:"NtryDtls>TxDtls"`
+	AdditionalInfo string        `xml:"AddtlNtryInf"`
+}
+
+type camtDetails struct {
+	ServicerRef string `xml:"Refs>AcctSvcrRef"`
+	EndToEndID  string `xml:"Refs>EndToEndId"`
+	// Parties are named directly up to version 7 and through a Pty element from version 8
+	Creditor      string   `xml:"RltdPties>Cdtr>Nm"`
+	CreditorParty string   `xml:"RltdPties>Cdtr>Pty>Nm"`
+	Debtor        string   `xml:"RltdPties>Dbtr>Nm"`
+	DebtorParty   string   `xml:"RltdPties>Dbtr>Pty>Nm"`
+	Remittance    []string `xml:"RmtInf>Ustrd"`
+}
+
+// camtEntryStart matches the start of an Ntry element, with or without a namespace prefix
+var camtEntryStart = regexp.MustCompile(`<(?:\w+:)?Ntry[\s>]`)
+
+// ParseCamt053 reads an ISO 20022 camt.053 statement. Only booked entries are imported; they are
+// identified by the bank's reference. With several statements in one file the opening balance is
+// that of the first and the closing balance that of the last.
+func ParseCamt053(data []byte, loc *time.Location) (*Statement, error) {
+	text, _, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(text, "BkToCstmrStmt") {
+		return nil, ErrUnknownFormat
+	}
+	decoder := xml.NewDecoder(bytes.NewReader([]byte(text)))
+	// The text is already UTF-8 whatever the declaration says
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+	var document camtDocument
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	// Entries are numbered by the line their element starts on, in the order they appear
+	var lines []int
+	for _, m := range camtEntryStart.FindAllStringIndex(text, -1) {
+		lines = append(lines, strings.Count(text[:m[0]], "\n")+1)
+	}
+
+	var statement Statement
+	occurrences := make(map[string]int)
+	for _, s := range document.Statements {
+		account := s.IBAN
+		if account == "" {
+			account = s.Other
+		}
+		if statement.Account == "" {
+			statement.Account = account
+		}
+		if statement.Currency == "" && s.Currency != "" {
+			if statement.Currency, err = models.NormalizeCurrency(s.Currency); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, b := range s.Balances {
+			balance, err := b.parse(loc)
+			if err != nil {
+				return nil, err
+			}
+			switch b.Code {
+			case "OPBD", "PRCD":
+				if statement.Opening == nil {
+					// An opening balance is the balance at the start of its day
+					if b.Code == "OPBD" {
+						balance.Date = balance.Date.AddDate(0, 0, -1)
+					}
+					statement.Opening = balance
+				}
+			case "CLBD":
+				statement.Balance = balance
+			}
+		}
+
+		for _, e := range s.Entries {
+			r := e.record(account, loc, occurrences)
+			if n := len(statement.Records); n < len(lines) {
+				r.Line = lines[n]
+			}
+			statement.Records = append(statement.Records, r)
+		}
+	}
+	if statement.Currency == "" && len(document.Statements) > 0 {
+		for _, b := range document.Statements[0].Balances {
+			if b.Amount.Currency != "" {
+				if statement.Currency, err = models.NormalizeCurrency(b.Amount.Currency); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+	}
+	return &statement, nil
+}
+
+// parse converts an amount with its credit/debit indicator; debits are negative
+func (a camtAmount) parse(creditDebit string) (models.Money, error) {
+	amount, err := models.ParseMoney(strings.TrimSpace(a.Value))
+	if err != nil {
+		return 0, ErrInvalidAmount
+	}
+	if creditDebit == "DBIT" {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// parse reads a date given either as a day or as a date and time
+func (d camtDate) parse(loc *time.Location) (time.Time, error) {
+	if d.Date != "" {
+		return ParseDate(d.Date, "2006-01-02", loc)
+	}
+	return ParseDate(d.DateTime, "", loc)
+}
+
+// parse converts a balance of a statement
+func (b camtBalance) parse(loc *time.Location) (*Balance, error) {
+	amount, err := b.Amount.parse(b.CreditDebit)
+	if err != nil {
+		return nil, err
+	}
+	date, err := b.Date.parse(loc)
+	if err != nil {
+		return nil, err
+	}
+	return &Balance{Amount: amount, Date: date}, nil
+}
+
+// record converts a statement entry. Credits are income and debits expenses, which also holds
+// for reversals as they carry the direction of the reversing entry; entries that aren't booked yet
+// are skipped.
+func (e camtEntry) record(account string, loc *time.Location, occurrences map[string]int) Record {
+	r := Record{}
+	status := strings.TrimSpace(e.Status.Code)
+	if status == "" {
+		status = strings.TrimSpace(e.Status.Value)
+	}
+	if status != "" && status != "BOOK" {
+		r.Skip = "status " + status
+	}
+
+	amount, err := e.Amount.parse(e.CreditDebit)
+	if err != nil {
+		r.addError("invalid amount %q", e.Amount.Value)
+	}
+	if amount < 0 {
+		r.Type, r.Amount = "expense", -amount
+	} else {
+		r.Type, r.Amount = "income", amount
+	}
+	if e.Amount.Currency != "" {
+		if r.Currency, err = models.NormalizeCurrency(e.Amount.Currency); err != nil {
+			r.addError("invalid currency %q", e.Amount.Currency)
+		}
+	}
+
+	date := e.BookingDate
+	if date.Date == "" && date.DateTime == "" {
+		date = e.ValueDate
+	}
+	if r.Date, err = date.parse(loc); err != nil && r.Skip == "" {
+		r.addError("invalid date %q", date.Date+date.DateTime)
+	}
+
+	var counterparty string
+	var purpose []string
+	reference := e.ServicerRef
+	for _, d := range e.Details {
+		if counterparty == "" {
+			// The counterparty of money paid out is the creditor, of money received the debtor
+			if e.CreditDebit == "DBIT" {
+				counterparty = firstNonEmpty(d.Creditor, d.CreditorParty)
+			} else {
+				counterparty = firstNonEmpty(d.Debtor, d.DebtorParty)
+			}
+		}
+		purpose = append(purpose, d.Remittance...)
+		if reference == "" {
+			reference = d.ServicerRef
+		}
+		if reference == "" && d.EndToEndID != "NOTPROVIDED" {
+			reference = d.EndToEndID
+		}
+	}
+	if len(purpose) == 0 && e.AdditionalInfo != "" {
+		purpose = append(purpose, e.AdditionalInfo)
+	}
+	r.Description = billDescription(strings.TrimSpace(counterparty), strings.TrimSpace(strings.Join(purpose, " ")))
+
+	if reference == "" {
+		reference = e.Reference
+	}
+	if reference == "" {
+		reference = contentID(occurrences, e.Amount.Value, e.CreditDebit, date.Date+date.DateTime, r.Description)
+	}
+	r.ExternalID = reference
+	if account != "" {
+		r.ExternalID = account + ":" + reference
+	}
+	return r
+}
+
+// firstNonEmpty returns the first of its arguments that isn't empty
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+package importer
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseCamt053(t *testing.T) {
+	statement, err := ParseCamt053(readTestdata(t, "camt053.xml"), testLoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const account = "DE89370400440532013000"
+	if statement.Account != account || statement.Currency != "EUR" {
+		t.Errorf("got account %q in %q, want %s in EUR", statement.Account, statement.Currency, account)
+	}
+
+	occurrences := make(map[string]int)
+	day := func(m time.Month, d int) time.Time { return time.Date(2024, m, d, 0, 0, 0, 0, testLoc) }
+	checkRecords(t, statement.Records, []wantRecord{
+		// Money paid out is described by the creditor
+		{line: 42, date: day(time.January, 2), description: "Hausverwaltung Schmidt - Miete Januar 2024", kind: "expense", amount: 85000, externalID: account + ":2024010200001"},
+		// Money received by the debtor; without the bank's reference the end-to-end ID identifies it
+		{line: 73, date: day(time.January, 30), description: "Muster GmbH - Gehalt Januar 2024", kind: "income", amount: 270000, externalID: account + ":SAL-2024-01"},
+		// A reversal carries the direction of the reversing entry and is booked at a time with an offset
+		{line: 104, date: time.Date(2024, 1, 15, 9, 24, 0, 0, time.UTC), description: "Rueckbuchung Gutschrift", kind: "expense", amount: 3874,
+			externalID: account + ":" + contentID(occurrences, "38.74", "DBIT", "2024-01-15T10:24:00+01:00", "Rueckbuchung Gutschrift")},
+		// A pending entry is dated by its value date and skipped
+		{line: 115, date: day(time.February, 1), description: "Kartenzahlung", kind: "expense", amount: 1250,
+			externalID: account + ":" + contentID(occurrences, "12.50", "DBIT", "2024-02-01", "Kartenzahlung"), skip: "status PDNG"},
+	})
+	for _, r := range statement.Records {
+		if r.Currency != "EUR" {
+			t.Errorf("line %d: Currency = %q, want EUR", r.Line, r.Currency)
+		}
+	}
+	// OPBD is the balance at the start of its day, so it is kept as that of the day before
+	checkBalance(t, "opening", statement.Opening, 150000, time.Date(2023, 12, 31, 0, 0, 0, 0, testLoc))
+	checkBalance(t, "closing", statement.Balance, 331126, day(time.January, 31))
+}
+
+func TestParseCamt053Version8(t *testing.T) {
+	// From version 8 the status has a Cd element and parties are named through Pty
+	data := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.08">
+<BkToCstmrStmt><Stmt>
+<Acct><Id><Othr><Id>987654321</Id></Othr></Id></Acct>
+<Bal><Tp><CdOrPrtry><Cd>PRCD</Cd></CdOrPrtry></Tp><Amt Ccy="CHF">10.00</Amt><CdtDbtInd>DBIT</CdtDbtInd><Dt><Dt>2024-03-31</Dt></Dt></Bal>
+<Ntry><Amt Ccy="CHF">25.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts><Cd>BOOK</Cd></Sts><BookgDt><Dt>2024-04-02</Dt></BookgDt><AcctSvcrRef>R-1</AcctSvcrRef>
+<NtryDtls><TxDtls><RltdPties><Dbtr><Pty><Nm>Hans Muster</Nm></Pty></Dbtr></RltdPties></TxDtls></NtryDtls></Ntry>
+</Stmt></BkToCstmrStmt>
+</Document>`)
+
+	statement, err := ParseCamt053(data, testLoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statement.Account != "987654321" || statement.Currency != "CHF" {
+		t.Errorf("got account %q in %q, want 987654321 in CHF", statement.Account, statement.Currency)
+	}
+	checkRecords(t, statement.Records, []wantRecord{
+		{line: 6, date: time.Date(2024, 4, 2, 0, 0, 0, 0, testLoc), description: "Hans Muster", kind: "income", amount: 2500, externalID: "987654321:R-1"},
+	})
+	// PRCD is the closing balance of the day before and is kept as it is
+	checkBalance(t, "opening", statement.Opening, -1000, time.Date(2024, 3, 31, 0, 0, 0, 0, testLoc))
+}
+
+func TestParseCamt053WrongFormat(t *testing.T) {
+	if _, err := ParseCamt053(readTestdata(t, "creditcard.ofx"), testLoc); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("ParseCamt053 of an OFX file error = %v, want %v", err, ErrUnknownFormat)
+	}
+}
@@ -0,0 +1,314 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"mini-money/internal/models"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// ErrEmptyFile is returned for a file without any rows
+var ErrEmptyFile = errors.New("file has no rows")
+
+// Table is the content of a CSV file
+type Table struct {
+	Encoding  string // "utf-8", "utf-16" or "gbk"
+	Delimiter string
+	Headers   []string // empty when the file has no header row
+	Rows      [][]string
+	Lines     []int // line of the file each row starts on
+}
+
+// delimiters are the field separators recognized in CSV files
+var delimiters = []rune{',', '\t', ';', '|'}
+
+// Decode converts file content to UTF-8 and names the encoding it was in.
+// UTF-8 and UTF-16 are recognized by their byte order mark or validity; anything else is read as GBK.
+func Decode(data []byte) (string, string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:]), "utf-8", nil
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}), bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		decoded, err := unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder().Bytes(data)
+		if err != nil {
+			return "", "", err
+		}
+		return string(decoded), "utf-16", nil
+	case utf8.Valid(data):
+		return string(data), "utf-8", nil
+	}
+	// GB18030 is a superset of GBK and GB2312
+	decoded, err := simplifiedchinese.GB18030.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", "", err
+	}
+	return string(decoded), "gbk", nil
+}
+
+// DetectDelimiter picks the delimiter that splits the first lines into the same number of
+// fields, preferring the one giving the most fields. It defaults to a comma.
+func DetectDelimiter(text string) rune {
+	sample := text
+	lines := 0
+	for i, r := range text {
+		if r == '\n' {
+			if lines++; lines == 20 {
+				sample = text[:i]
+				break
+			}
+		}
+	}
+
+	best, bestFields, bestConsistent := ',', 0, false
+	for _, delimiter := range delimiters {
+		reader := csv.NewReader(strings.NewReader(sample))
+		reader.Comma = delimiter
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+
+		fields, consistent := 0, true
+		for {
+			record, err := reader.Read()
+			if err != nil {
+				break
+			}
+			if fields == 0 {
+				fields = len(record)
+			} else if len(record) != fields {
+				consistent = false
+			}
+		}
+		if fields < 2 {
+			continue
+		}
+		if (consistent && !bestConsistent) || (consistent == bestConsistent && fields > bestFields) {
+			best, bestFields, bestConsistent = delimiter, fields, consistent
+		}
+	}
+	return best
+}
+
+// ParseCSV reads a CSV file in any of the recognized encodings and delimiters.
+// With header the first row names the columns. Empty rows are skipped.
+func ParseCSV(data []byte, header bool) (*Table, error) {
+	text, encoding, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	delimiter := DetectDelimiter(text)
+	table := &Table{Encoding: encoding, Delimiter: string(delimiter)}
+
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if blankRow(record) {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+		if header && table.Headers == nil {
+			table.Headers = record
+			continue
+		}
+		table.Rows = append(table.Rows, record)
+		table.Lines = append(table.Lines, line)
+	}
+	if len(table.Rows) == 0 {
+		return nil, ErrEmptyFile
+	}
+	return table, nil
+}
+
+// blankRow reports whether every field of a row is empty
+func blankRow(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// headerAliases maps common header names to the transaction field they hold
+var headerAliases = map[string]string{
+	"date": "date", "日期": "date", "时间": "date", "交易时间": "date", "交易日期": "date", "记账日期": "date",
+	"description": "description", "描述": "description", "备注": "description", "说明": "description", "摘要": "description",
+	"memo": "description", "note": "description", "商品": "description", "名称": "description",
+	"amount": "amount", "金额": "amount", "金额(元)": "amount", "交易金额": "amount",
+	"income": "income", "收入": "income", "收入金额": "income", "credit": "income", "存入": "income",
+	"expense": "expense", "支出": "expense", "支出金额": "expense", "debit": "expense", "支取": "expense",
+	"type": "type", "类型": "type", "收/支": "type", "收支": "type", "收支类型": "type",
+	"category": "category", "分类": "category", "类别": "category",
+	"currency": "currency", "币种": "currency", "货币": "currency",
+}
+
+// GuessMapping maps the columns of a table by their header names
+func GuessMapping(headers []string) models.ImportMapping {
+	var m models.ImportMapping
+	fields := map[string]*string{
+		"date": &m.Date, "description": &m.Description, "amount": &m.Amount, "income": &m.Income,
+		"expense": &m.Expense, "type": &m.Type, "category": &m.Category, "currency": &m.Currency,
+	}
+	for _, header := range headers {
+		if field, ok := headerAliases[strings.ToLower(header)]; ok && *fields[field] == "" {
+			*fields[field] = header
+		}
+	}
+	return m
+}
+
+// column finds a mapped column by header name or by 1-based number; -1 means not mapped
+func (t *Table) column(name string) (int, error) {
+	if name == "" {
+		return -1, nil
+	}
+	for i, header := range t.Headers {
+		if strings.EqualFold(header, name) {
+			return i, nil
+		}
+	}
+	if n, err := strconv.Atoi(name); err == nil && n >= 1 {
+		return n - 1, nil
+	}
+	return 0, fmt.Errorf("column %q not found", name)
+}
+
+// Records turns the rows of the table into records using the mapping.
+// Problems with single rows are reported on their records; an unusable mapping is an error.
+func (t *Table) Records(m models.ImportMapping, loc *time.Location) ([]Record, error) {
+	columns := make(map[string]int)
+	for field, name := range map[string]string{
+		"date": m.Date, "description": m.Description, "amount": m.Amount, "income": m.Income,
+		"expense": m.Expense, "type": m.Type, "category": m.Category, "currency": m.Currency,
+	} {
+		i, err := t.column(name)
+		if err != nil {
+			return nil, err
+		}
+		columns[field] = i
+	}
+	if columns["date"] < 0 {
+		return nil, errors.New("a date column is required")
+	}
+	if columns["amount"] < 0 && columns["income"] < 0 && columns["expense"] < 0 {
+		return nil, errors.New("an amount column, or income and expense columns, is required")
+	}
+	defaultType := m.DefaultType
+	if defaultType == "" {
+		defaultType = "expense"
+	}
+	if defaultType != "income" && defaultType != "expense" {
+		return nil, errors.New("defaultType must be income or expense")
+	}
+
+	cell := func(row []string, field string) string {
+		if c := columns[field]; c >= 0 && c < len(row) {
+			return row[c]
+		}
+		return ""
+	}
+	// When some amounts are negative the sign tells income from expense
+	signed := false
+	for _, row := range t.Rows {
+		if amount, err := ParseAmount(cell(row, "amount")); err == nil && amount < 0 {
+			signed = true
+			break
+		}
+	}
+
+	records := make([]Record, 0, len(t.Rows))
+	for i, row := range t.Rows {
+		value := func(field string) string { return cell(row, field) }
+		r := Record{Line: t.Lines[i], Description: value("description"), Category: value("category")}
+
+		date, err := ParseDate(value("date"), m.DateFormat, loc)
+		if err != nil {
+			r.addError("invalid date %q", value("date"))
+		}
+		r.Date = date
+
+		typeGiven := false
+		if columns["type"] >= 0 {
+			if r.Type, typeGiven = ParseType(value("type")); !typeGiven {
+				r.addError("unknown type %q", value("type"))
+			}
+		}
+
+		var amount models.Money
+		var amountErr error
+		if columns["amount"] >= 0 && value("amount") != "" {
+			amount, amountErr = ParseAmount(value("amount"))
+			if amountErr != nil {
+				r.addError("invalid amount %q", value("amount"))
+			}
+			if !typeGiven {
+				r.Type = defaultType
+				if signed {
+					r.Type = "income"
+					if amount < 0 {
+						r.Type = "expense"
+					}
+				}
+			}
+		} else {
+			// With separate columns the one not used is left empty or zero, such as "0.00"
+			for _, field := range []string{"income", "expense"} {
+				if value(field) == "" {
+					continue
+				}
+				parsed, err := ParseAmount(value(field))
+				if err != nil {
+					amountErr = err
+					r.addError("invalid amount %q", value(field))
+					continue
+				}
+				if amount == 0 && parsed != 0 {
+					amount = parsed
+					if !typeGiven {
+						r.Type = field
+					}
+				}
+			}
+		}
+		if amount < 0 {
+			amount = -amount
+		}
+		if amount == 0 && amountErr == nil {
+			r.addError("amount is missing or zero")
+		}
+		r.Amount = amount
+		if r.Type == "" {
+			r.Type = defaultType
+		}
+
+		if currency := value("currency"); currency != "" {
+			if r.Currency, err = models.NormalizeCurrency(currency); err != nil {
+				r.addError("invalid currency %q", currency)
+			}
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
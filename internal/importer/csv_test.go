@@ -0,0 +1,131 @@
+package importer
+
+import (
+	"testing"
+	"time"
+
+	"mini-money/internal/models"
+)
+
+func TestDetectDelimiter(t *testing.T) {
+	tests := []struct {
+		text string
+		want rune
+	}{
+		{"date,amount\n2024-01-05,35\n", ','},
+		{"date\tamount\tnote\n2024-01-05\t1,234.50\tlunch, dinner\n", '\t'},
+		{"Datum;Betrag;Text\n05.01.2024;-12,50;Kaffee\n", ';'},
+		{"date|amount\n2024-01-05|35\n", '|'},
+		{"single column\nno delimiter\n", ','},
+	}
+	for _, tt := range tests {
+		if got := DetectDelimiter(tt.text); got != tt.want {
+			t.Errorf("DetectDelimiter(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseCSVGBKSemicolon(t *testing.T) {
+	table, err := ParseCSV(readTestdata(t, "bank_gbk_semicolon.csv"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.Encoding != "gbk" || table.Delimiter != ";" {
+		t.Errorf("got encoding %q and delimiter %q, want gbk and ;", table.Encoding, table.Delimiter)
+	}
+	if want := []string{"交易日期", "摘要", "收入", "支出", "余额", "币种"}; !equalStrings(table.Headers, want) {
+		t.Errorf("Headers = %q, want %q", table.Headers, want)
+	}
+
+	records, err := table.Records(GuessMapping(table.Headers), testLoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, testLoc) }
+	checkRecords(t, records, []wantRecord{
+		{line: 2, date: day(2), description: "工资", kind: "income", amount: 1200000},
+		{line: 3, date: day(3), description: "美团外卖 午饭", kind: "expense", amount: 3250},
+		// The full-width semicolon inside quotes isn't a delimiter
+		{line: 4, date: day(5), description: "转账；房租", kind: "expense", amount: 350000},
+		// The blank line before it is skipped but still counted
+		{line: 6, date: day(6), description: "利息", kind: "income", amount: 35},
+	})
+	for _, r := range records {
+		if r.Currency != "CNY" {
+			t.Errorf("line %d: Currency = %q, want CNY", r.Line, r.Currency)
+		}
+	}
+}
+
+func TestParseCSVUTF16Tab(t *testing.T) {
+	table, err := ParseCSV(readTestdata(t, "excel_utf16_tab.txt"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.Encoding != "utf-16" || table.Delimiter != "\t" {
+		t.Errorf("got encoding %q and delimiter %q, want utf-16 and a tab", table.Encoding, table.Delimiter)
+	}
+
+	records, err := table.Records(GuessMapping(table.Headers), testLoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, testLoc) }
+	// Some amounts are negative, so the sign gives the type
+	checkRecords(t, records, []wantRecord{
+		{line: 2, date: day(1), description: "Coffee", kind: "expense", amount: 450, category: "Food"},
+		{line: 3, date: day(2), description: "Bookshop", kind: "expense", amount: 1200, category: "Shopping"},
+		{line: 4, date: day(15), description: "Paycheck", kind: "income", amount: 250000, category: "Salary"},
+	})
+}
+
+func TestRecordsSeparateAmountColumns(t *testing.T) {
+	data := []byte("日期,描述,收入,支出\n" +
+		"2024-01-05,工资,8000.00,0.00\n" +
+		"2024-01-06,午饭,0.00,35.00\n" +
+		"2024-01-07,奶茶,,-18\n" +
+		"2024-01-08,空行,0.00,0.00\n" +
+		"2024-01-09,坏数,abc,0\n")
+	table, err := ParseCSV(data, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := table.Records(GuessMapping(table.Headers), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		kind   string
+		amount models.Money
+		errors []string
+	}{
+		{"income", 800000, nil},
+		{"expense", 3500, nil},
+		{"expense", 1800, nil},
+		{"expense", 0, []string{"amount is missing or zero"}},
+		{"expense", 0, []string{`invalid amount "abc"`}},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d", len(records), len(want))
+	}
+	for i, w := range want {
+		r := records[i]
+		if r.Type != w.kind || r.Amount != w.amount || !equalStrings(r.Errors, w.errors) {
+			t.Errorf("line %d: got %s %s %q, want %s %s %q", r.Line, r.Type, r.Amount, r.Errors, w.kind, w.amount, w.errors)
+		}
+	}
+}
+
+// equalStrings reports whether two lists hold the same strings in the same order
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
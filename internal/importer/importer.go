@@ -0,0 +1,204 @@
+// Package importer reads transactions from files exported by spreadsheets, banks and
+// payment apps. Parsers return records; turning them into transactions is up to the caller.
+package importer
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"mini-money/internal/models"
+)
+
+// ErrInvalidAmount is returned for an amount that can't be parsed
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// ErrInvalidDate is returned for a date that can't be parsed
+var ErrInvalidDate = errors.New("invalid date")
+
+// Record is one transaction read from an import file
+type Record struct {
+	Line        int // line of the file the record starts on
+	Date        time.Time
+	Description string
+	Amount      models.Money // always positive, the direction is in Type
+	Type        string       // "income" or "expense"
+	Category    string       // category as written in the file
+	CategoryKey string       // set by ResolveCategories
+	Currency    string       // empty for the user's base currency
+	Hint        string       // other text that helps to suggest a category, such as the kind of payment
+	ExternalID  string       // ID of the entry in its source, used to skip entries imported before
+	Skip        string       // why the record isn't imported, such as an unfinished payment
+	Errors      []string
+}
+
+// Statement is a bank or credit card statement: its entries and the balance it closes with
+type Statement struct {
+	Account  string // account number given by the bank, if any
+	Currency string // currency of the account, if given
+	Records  []Record
+	Opening  *Balance // opening balance, as the balance at the end of the day before, if given
+	Balance  *Balance // ledger balance, if the statement has one
+}
+
+// Balance is the balance of an account at a point in time
+type Balance struct {
+	Amount models.Money // negative when money is owed, such as on a credit card
+	Date   time.Time
+}
+
+// contentID identifies an entry that has no ID of its own by a hash of its content, numbered by
+// how often the same content occurred before in the file
+func contentID(occurrences map[string]int, parts ...string) string {
+	hash := sha1.Sum([]byte(strings.Join(parts, "\x00")))
+	key := hex.EncodeToString(hash[:8])
+	id := fmt.Sprintf("%s-%d", key, occurrences[key])
+	occurrences[key]++
+	return id
+}
+
+// addError records a problem with the record
+func (r *Record) addError(format string, args ...interface{}) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+// ResolveCategories sets the category key of each record. A category is looked up in mapping
+// first, then among the user's categories of the record's type by key or name. Records without a
+// category keep a suggested key or fall back to mapping[""] or the catch-all category of their
+// type, such as other_income.
+func ResolveCategories(records []Record, categories map[string][]models.Category, mapping map[string]string) {
+	exists := func(kind, key string) bool {
+		for _, c := range categories[kind] {
+			if c.Key == key {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := range records {
+		r := &records[i]
+		if r.Skip != "" {
+			continue
+		}
+		raw := strings.TrimSpace(r.Category)
+
+		if key, ok := mapping[raw]; ok && (raw != "" || r.CategoryKey == "") {
+			if !exists(r.Type, key) {
+				r.addError("category %q is mapped to %q, which is not an %s category", raw, key, r.Type)
+				continue
+			}
+			r.CategoryKey = key
+			continue
+		}
+		if r.CategoryKey != "" {
+			continue
+		}
+
+		if raw == "" {
+			for _, c := range categories[r.Type] {
+				if strings.HasPrefix(c.Key, "other") {
+					r.CategoryKey = c.Key
+					break
+				}
+			}
+			if r.CategoryKey == "" {
+				r.addError("category is required")
+			}
+			continue
+		}
+
+		for _, c := range categories[r.Type] {
+			if strings.EqualFold(c.Key, raw) || strings.EqualFold(c.Name, raw) {
+				r.CategoryKey = c.Key
+				break
+			}
+		}
+		if r.CategoryKey == "" {
+			r.addError("unknown %s category %q", r.Type, raw)
+		}
+	}
+}
+
+// ParseAmount parses an amount as written in spreadsheets and statements, such as "1,234.50",
+// "¥35", "-12.00" or "(12.00)" for a negative amount
+func ParseAmount(s string) (models.Money, error) {
+	s = strings.TrimSpace(s)
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = s[1 : len(s)-1]
+	}
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case ',', ' ', '¥', '￥', '$', '€', '£':
+			return -1
+		}
+		return r
+	}, s)
+	s = strings.TrimSuffix(s, "元")
+	if s == "" {
+		return 0, ErrInvalidAmount
+	}
+
+	amount, err := models.ParseMoney(s)
+	if err != nil {
+		return 0, ErrInvalidAmount
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// dateLayouts are the date formats recognized when no format is given, most specific first
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-1-2 15:04:05",
+	"2006-1-2 15:04",
+	"2006-1-2",
+	"2006/1/2 15:04:05",
+	"2006/1/2 15:04",
+	"2006/1/2",
+	"2006.1.2 15:04:05",
+	"2006.1.2",
+	"2006年1月2日 15:04:05",
+	"2006年1月2日 15:04",
+	"2006年1月2日",
+	"20060102 150405",
+	"20060102",
+}
+
+// ParseDate parses a date in the given Go layout, or in one of the common formats when layout
+// is empty. Dates without an offset are in loc, the user's timezone.
+func ParseDate(s, layout string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if layout != "" {
+		t, err := time.ParseInLocation(layout, s, loc)
+		if err != nil {
+			return time.Time{}, ErrInvalidDate
+		}
+		return t, nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrInvalidDate
+}
+
+// typeWords maps the words used for the direction of money to transaction types
+var typeWords = map[string]string{
+	"income": "income", "收入": "income", "收": "income", "入账": "income", "存入": "income", "credit": "income", "cr": "income", "in": "income", "+": "income",
+	"expense": "expense", "支出": "expense", "支": "expense", "出账": "expense", "支取": "expense", "debit": "expense", "dr": "expense", "out": "expense", "-": "expense",
+}
+
+// ParseType recognizes a transaction type written as a word such as 收入, debit or expense
+func ParseType(s string) (string, bool) {
+	kind, ok := typeWords[strings.ToLower(strings.TrimSpace(s))]
+	return kind, ok
+}
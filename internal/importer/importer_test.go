@@ -0,0 +1,205 @@
+package importer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mini-money/internal/models"
+)
+
+// testLoc is the user's timezone in the tests, away from UTC so that zone mistakes show
+var testLoc = time.FixedZone("CST", 8*3600)
+
+// wantRecord is the expected content of a parsed record
+type wantRecord struct {
+	line        int
+	date        time.Time
+	description string
+	kind        string
+	amount      models.Money
+	category    string
+	externalID  string
+	skip        string
+}
+
+// readTestdata returns the content of a file in testdata
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// checkRecords compares parsed records with the expected ones; none may have errors
+func checkRecords(t *testing.T, got []Record, want []wantRecord) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d records, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		r := got[i]
+		if r.Line != w.line || !r.Date.Equal(w.date) || r.Description != w.description || r.Type != w.kind ||
+			r.Amount != w.amount || r.Category != w.category || r.ExternalID != w.externalID || r.Skip != w.skip {
+			t.Errorf("record %d:\n got line %d %s %q %s %s %q %q skip %q\nwant line %d %s %q %s %s %q %q skip %q", i,
+				r.Line, r.Date, r.Description, r.Type, r.Amount, r.Category, r.ExternalID, r.Skip,
+				w.line, w.date, w.description, w.kind, w.amount, w.category, w.externalID, w.skip)
+		}
+		if len(r.Errors) > 0 {
+			t.Errorf("record %d: unexpected errors %q", i, r.Errors)
+		}
+	}
+}
+
+// checkBalance compares a statement balance with the expected amount and date
+func checkBalance(t *testing.T, name string, got *Balance, amount models.Money, date time.Time) {
+	t.Helper()
+	if got == nil {
+		t.Errorf("%s balance is missing", name)
+		return
+	}
+	if got.Amount != amount || !got.Date.Equal(date) {
+		t.Errorf("%s balance = %s on %s, want %s on %s", name, got.Amount, got.Date, amount, date)
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want models.Money
+	}{
+		{"35", 3500},
+		{"1,234.50", 123450},
+		{" -12.00 ", -1200},
+		{"(12.00)", -1200},
+		{"¥35", 3500},
+		{"￥1,000.5", 100050},
+		{"$ 9.99", 999},
+		{"€0.01", 1},
+		{"28元", 2800},
+	}
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseAmount(%q) = %s, %v, want %s", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "  ", "abc", "1.2.3", "()"} {
+		if _, err := ParseAmount(in); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("ParseAmount(%q) error = %v, want %v", in, err, ErrInvalidAmount)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		in, layout string
+		want       time.Time
+	}{
+		{"2024-01-05", "", time.Date(2024, 1, 5, 0, 0, 0, 0, testLoc)},
+		{"2024-1-5 8:30", "", time.Date(2024, 1, 5, 8, 30, 0, 0, testLoc)},
+		{"2024/01/05 08:30:15", "", time.Date(2024, 1, 5, 8, 30, 15, 0, testLoc)},
+		{"2024.1.5", "", time.Date(2024, 1, 5, 0, 0, 0, 0, testLoc)},
+		{"2024年1月5日", "", time.Date(2024, 1, 5, 0, 0, 0, 0, testLoc)},
+		{"20240105", "", time.Date(2024, 1, 5, 0, 0, 0, 0, testLoc)},
+		// An offset in the value wins over the user's timezone
+		{"2024-01-05T08:30:00Z", "", time.Date(2024, 1, 5, 8, 30, 0, 0, time.UTC)},
+		{"05/01/2024", "02/01/2006", time.Date(2024, 1, 5, 0, 0, 0, 0, testLoc)},
+	}
+	for _, tt := range tests {
+		got, err := ParseDate(tt.in, tt.layout, testLoc)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("ParseDate(%q, %q) = %s, %v, want %s", tt.in, tt.layout, got, err, tt.want)
+		}
+	}
+
+	for _, tt := range []struct{ in, layout string }{{"", ""}, {"yesterday", ""}, {"2024-02-30", ""}, {"2024-01-05", "02/01/2006"}} {
+		if _, err := ParseDate(tt.in, tt.layout, testLoc); !errors.Is(err, ErrInvalidDate) {
+			t.Errorf("ParseDate(%q, %q) error = %v, want %v", tt.in, tt.layout, err, ErrInvalidDate)
+		}
+	}
+}
+
+func TestParseType(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"收入", "income", true},
+		{" Credit ", "income", true},
+		{"+", "income", true},
+		{"支出", "expense", true},
+		{"DR", "expense", true},
+		{"转账", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseType(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseType(%q) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestResolveCategories(t *testing.T) {
+	categories := map[string][]models.Category{
+		"expense": {{Key: "food", Name: "餐饮"}, {Key: "shopping", Name: "购物"}, {Key: "other_expense", Name: "其他"}},
+		"income":  {{Key: "salary", Name: "工资"}, {Key: "other_income", Name: "其他"}},
+	}
+	mapping := map[string]string{"Groceries": "food", "Gadgets": "digital"}
+
+	records := []Record{
+		{Type: "expense", Category: "餐饮"},
+		{Type: "expense", Category: "Shopping"},
+		{Type: "expense", Category: " Groceries "},
+		{Type: "income", Category: ""},
+		{Type: "expense", CategoryKey: "shopping"},
+		{Type: "income", Category: "餐饮"},
+		{Type: "expense", Category: "Gadgets"},
+		{Type: "expense", Category: "Nonsense", Skip: "zero amount"},
+	}
+	ResolveCategories(records, categories, mapping)
+
+	want := []struct {
+		key    string
+		errors []string
+	}{
+		{"food", nil},
+		{"shopping", nil},
+		{"food", nil},
+		{"other_income", nil},
+		{"shopping", nil},
+		{"", []string{`unknown income category "餐饮"`}},
+		{"", []string{`category "Gadgets" is mapped to "digital", which is not an expense category`}},
+		{"", nil},
+	}
+	for i, w := range want {
+		r := records[i]
+		if r.CategoryKey != w.key || !equalStrings(r.Errors, w.errors) {
+			t.Errorf("record %d: got %q %q, want %q %q", i, r.CategoryKey, r.Errors, w.key, w.errors)
+		}
+	}
+}
+
+func TestContentID(t *testing.T) {
+	occurrences := make(map[string]int)
+	first := contentID(occurrences, "2024-01-12", "-120.00", "Costco")
+	second := contentID(occurrences, "2024-01-12", "-120.00", "Costco")
+	other := contentID(occurrences, "2024-01-12", "-120.0", "0Costco")
+
+	if first[:len(first)-2] != second[:len(second)-2] || first[len(first)-2:] != "-0" || second[len(second)-2:] != "-1" {
+		t.Errorf("repeated content got IDs %q and %q, want the same hash numbered -0 and -1", first, second)
+	}
+	if other[:len(other)-2] == first[:len(first)-2] {
+		t.Errorf("different content got the same hash %q", other)
+	}
+	if again := contentID(make(map[string]int), "2024-01-12", "-120.00", "Costco"); again != first {
+		t.Errorf("content ID is not stable: %q, then %q", first, again)
+	}
+}
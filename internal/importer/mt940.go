@@ -0,0 +1,236 @@
+package importer
+
+import (
+	"bufio"
+	"regexp"
+	"strings"
+	"time"
+
+	"mini-money/internal/models"
+)
+
+// mt940Tag matches the start of a field of an MT940 message, such as :61:
+var mt940Tag = regexp.MustCompile(`^:(\d{2}[A-Z]?):`)
+
+// mt940Entry matches a :61: statement line: value date, optional entry date, debit/credit mark
+// (R for a reversal), optional funds code, amount, transaction type, customer reference, bank
+// reference after // and supplementary details on the next line
+var mt940Entry = regexp.MustCompile(`^(\d{6})(\d{4})?(RC|RD|C|D)[A-Z]?(\d+,\d*)[NFS][A-Z0-9]{3}([^/\n]*)(?://([^\n]*))?(?:\n(.*))?`)
+
+// mt940Balance matches a balance field such as :60F:C261001EUR1234,56
+var mt940Balance = regexp.MustCompile(`^([CD])(\d{6})([A-Z]{3})(\d+,\d*)`)
+
+// mt940Field is one field of an MT940 message and the line it starts on
+type mt940Field struct {
+	tag   string
+	value string
+	line  int
+}
+
+// ParseMT940 reads a SWIFT MT940 statement. Entries are identified by their bank reference, or by
+// their customer reference when the bank gives none. With several statements in one file the
+// opening balance is that of the first and the closing balance that of the last.
+func ParseMT940(data []byte, loc *time.Location) (*Statement, error) {
+	text, _, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := mt940Fields(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, ErrUnknownFormat
+	}
+
+	var (
+		statement   Statement
+		account     string
+		occurrences = make(map[string]int)
+	)
+	for i, f := range fields {
+		switch f.tag {
+		case "25":
+			account = f.value
+			if statement.Account == "" {
+				statement.Account = account
+			}
+		case "60F", "60M":
+			if statement.Opening != nil {
+				continue
+			}
+			balance, currency, err := mt940ParseBalance(f.value, loc)
+			if err != nil {
+				return nil, err
+			}
+			statement.Opening = balance
+			if statement.Currency == "" {
+				statement.Currency = currency
+			}
+		case "62F":
+			balance, _, err := mt940ParseBalance(f.value, loc)
+			if err != nil {
+				return nil, err
+			}
+			statement.Balance = balance
+		case "61":
+			details := ""
+			if i+1 < len(fields) && fields[i+1].tag == "86" {
+				details = fields[i+1].value
+			}
+			statement.Records = append(statement.Records, mt940Record(f, details, account, loc, occurrences))
+		}
+	}
+	for i := range statement.Records {
+		statement.Records[i].Currency = statement.Currency
+	}
+	return &statement, nil
+}
+
+// mt940Fields splits the text block of MT940 messages into fields, dropping the SWIFT headers
+func mt940Fields(text string) ([]mt940Field, error) {
+	var fields []mt940Field
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for line := 1; scanner.Scan(); line++ {
+		s := strings.TrimRight(scanner.Text(), "\r ")
+		// Headers such as {1:F01...}{2:...}{4: and the closing -} are not fields
+		if i := strings.Index(s, "{4:"); i >= 0 {
+			s = s[i+3:]
+		}
+		if s == "" || s == "-" || strings.HasPrefix(s, "-}") || strings.HasPrefix(s, "{") {
+			continue
+		}
+		if m := mt940Tag.FindStringSubmatch(s); m != nil {
+			fields = append(fields, mt940Field{tag: m[1], value: s[len(m[0]):], line: line})
+		} else if len(fields) > 0 {
+			fields[len(fields)-1].value += "\n" + s
+		}
+	}
+	return fields, scanner.Err()
+}
+
+// mt940Amount parses an amount with a decimal comma, such as 1234,56
+func mt940Amount(s string) (models.Money, error) {
+	amount, err := models.ParseMoney(strings.Replace(strings.TrimSuffix(s, ","), ",", ".", 1))
+	if err != nil {
+		return 0, ErrInvalidAmount
+	}
+	return amount, nil
+}
+
+// mt940Date parses a date in YYMMDD form
+func mt940Date(s string, loc *time.Location) (time.Time, error) {
+	t, err := time.ParseInLocation("060102", s, loc)
+	if err != nil {
+		return time.Time{}, ErrInvalidDate
+	}
+	return t, nil
+}
+
+// mt940ParseBalance parses an opening or closing balance and returns it with its currency
+func mt940ParseBalance(s string, loc *time.Location) (*Balance, string, error) {
+	m := mt940Balance.FindStringSubmatch(s)
+	if m == nil {
+		return nil, "", ErrInvalidAmount
+	}
+	amount, err := mt940Amount(m[4])
+	if err != nil {
+		return nil, "", err
+	}
+	if m[1] == "D" {
+		amount = -amount
+	}
+	date, err := mt940Date(m[2], loc)
+	if err != nil {
+		return nil, "", err
+	}
+	currency, err := models.NormalizeCurrency(m[3])
+	if err != nil {
+		return nil, "", err
+	}
+	return &Balance{Amount: amount, Date: date}, currency, nil
+}
+
+// mt940Record builds a record from a :61: statement line and the :86: information that follows it
+func mt940Record(f mt940Field, details, account string, loc *time.Location, occurrences map[string]int) Record {
+	r := Record{Line: f.line}
+	m := mt940Entry.FindStringSubmatch(f.value)
+	if m == nil {
+		r.addError("invalid statement line %q", strings.SplitN(f.value, "\n", 2)[0])
+		return r
+	}
+
+	var err error
+	if r.Date, err = mt940Date(m[1], loc); err != nil {
+		r.addError("invalid date %q", m[1])
+	}
+	if r.Amount, err = mt940Amount(m[4]); err != nil {
+		r.addError("invalid amount %q", m[4])
+	}
+	// A reversed credit takes money out, a reversed debit puts it back
+	switch m[3] {
+	case "C", "RD":
+		r.Type = "income"
+	default:
+		r.Type = "expense"
+	}
+	if r.Amount == 0 && len(r.Errors) == 0 {
+		r.Skip = "zero amount"
+	}
+
+	reference := strings.TrimSpace(m[6])
+	if reference == "" || reference == "NONREF" {
+		reference = strings.TrimSpace(m[5])
+	}
+	if reference == "" || reference == "NONREF" {
+		reference = contentID(occurrences, m[0], details)
+	}
+	r.ExternalID = reference
+	if account != "" {
+		r.ExternalID = account + ":" + reference
+	}
+
+	r.Description = mt940Description(details)
+	if r.Description == "" {
+		r.Description = strings.TrimSpace(m[7])
+	}
+	return r
+}
+
+// mt940Subfield matches a subfield of structured :86: information, such as ?20 or ?32
+var mt940Subfield = regexp.MustCompile(`\?(\d{2})([^?]*)`)
+
+// mt940SwiftField matches a code of :86: information in the SWIFT style, such as /NAME/ or /REMI/
+var mt940SwiftField = regexp.MustCompile(`/(NAME|REMI)/([^/]*)`)
+
+// mt940Description describes an entry by the counterparty and purpose in its :86: information,
+// which banks structure in ?nn subfields, in /CODE/ fields or not at all
+func mt940Description(details string) string {
+	details = strings.ReplaceAll(details, "\n", "")
+	if strings.TrimSpace(details) == "" {
+		return ""
+	}
+
+	var name, purpose []string
+	if matches := mt940Subfield.FindAllStringSubmatch(details, -1); matches != nil {
+		for _, m := range matches {
+			switch {
+			case m[1] >= "20" && m[1] <= "29", m[1] >= "60" && m[1] <= "63":
+				purpose = append(purpose, m[2])
+			case m[1] == "32" || m[1] == "33":
+				name = append(name, m[2])
+			}
+		}
+	} else if matches := mt940SwiftField.FindAllStringSubmatch(details, -1); matches != nil {
+		for _, m := range matches {
+			if m[1] == "NAME" {
+				name = append(name, m[2])
+			} else {
+				purpose = append(purpose, m[2])
+			}
+		}
+	} else {
+		return strings.Join(strings.Fields(details), " ")
+	}
+	return billDescription(strings.TrimSpace(strings.Join(name, "")), strings.TrimSpace(strings.Join(purpose, "")))
+}
@@ -0,0 +1,65 @@
+package importer
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseMT940(t *testing.T) {
+	statement, err := ParseMT940(readTestdata(t, "statement.mt940"), testLoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const account = "10020030/1234567890"
+	if statement.Account != account || statement.Currency != "EUR" {
+		t.Errorf("got account %q in %q, want %s in EUR", statement.Account, statement.Currency, account)
+	}
+
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, testLoc) }
+	checkRecords(t, statement.Records, []wantRecord{
+		// Structured :86: subfields; ?20 to ?29 are wrapped purpose lines
+		{line: 6, date: day(2), description: "Stadtwerke Musterstadt - Strom Januar 2024 Kunden-Nr. 4711", kind: "expense", amount: 5000, externalID: account + ":DD240102001"},
+		{line: 8, date: day(3), description: "Muster GmbH - Gehalt Januar 2024", kind: "income", amount: 250000, externalID: account + ":TR240103001"},
+		// A reversed debit puts the money back, with the name wrapped onto a continuation line
+		{line: 10, date: day(5), description: "Stadtwerke Musterstadt - Strom Januar 2024 Kunden-Nr. 4711", kind: "income", amount: 5000, externalID: account + ":DD240102001R"},
+		// A reversed credit takes it out again
+		{line: 13, date: day(8), description: "Max Mustermann - Storno Gutschrift", kind: "expense", amount: 10000, externalID: account + ":TR240108001R"},
+		// Without :86: the supplementary details describe the entry; the customer reference identifies it
+		{line: 15, date: day(10), description: "Kartenzahlung Buchladen", kind: "expense", amount: 1999, externalID: account + ":REF-77"},
+		// Without any reference the entry is identified by its content
+		{line: 17, date: day(10), description: "Kartenzahlung Supermarkt Filiale 12", kind: "expense", amount: 1999,
+			externalID: account + ":" + contentID(make(map[string]int), "240110D19,99NMSCNONREF", "Kartenzahlung Supermarkt Fil\niale 12")},
+		// From the second message of the file
+		{line: 27, date: day(11), description: "Kontofuehrung Januar", kind: "expense", amount: 1000, externalID: account + ":CH240111001"},
+	})
+	for _, r := range statement.Records {
+		if r.Currency != "EUR" {
+			t.Errorf("line %d: Currency = %q, want EUR", r.Line, r.Currency)
+		}
+	}
+	// The opening balance of the first message and the closing balance of the last
+	checkBalance(t, "opening", statement.Opening, 123456, day(1))
+	checkBalance(t, "closing", statement.Balance, 358458, day(11))
+}
+
+func TestParseMT940DebitBalance(t *testing.T) {
+	data := []byte(":20:STMT\n:25:DE89370400440532013000\n:60F:D240131EUR25,5\n:61:240201C100,NTRFNONREF//B1\n:62F:C240201EUR74,50\n")
+
+	statement, err := ParseMT940(data, testLoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := func(m time.Month, d int) time.Time { return time.Date(2024, m, d, 0, 0, 0, 0, testLoc) }
+	checkBalance(t, "opening", statement.Opening, -2550, day(time.January, 31))
+	checkBalance(t, "closing", statement.Balance, 7450, day(time.February, 1))
+	checkRecords(t, statement.Records, []wantRecord{
+		{line: 4, date: day(time.February, 1), kind: "income", amount: 10000, externalID: "DE89370400440532013000:B1"},
+	})
+}
+
+func TestParseMT940WrongFormat(t *testing.T) {
+	if _, err := ParseMT940(readTestdata(t, "checking.qif"), testLoc); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("ParseMT940 of a QIF file error = %v, want %v", err, ErrUnknownFormat)
+	}
+}
@@ -0,0 +1,144 @@
+package importer
+
+import (
+	"html"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"mini-money/internal/models"
+)
+
+// ofxElement matches an opening or closing OFX tag and the value that follows it. It reads both
+// the SGML of OFX 1.x, where values have no closing tag, and the XML of OFX 2.x.
+var ofxElement = regexp.MustCompile(`<(/?)([A-Za-z0-9.]+)>([^<]*)`)
+
+// ParseOFX reads an OFX statement from a bank or credit card. Entries are identified by their
+// FITID, prefixed with the account number when the statement has one.
+func ParseOFX(data []byte, loc *time.Location) (*Statement, error) {
+	text, _, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(strings.ToUpper(text), "<OFX>") {
+		return nil, ErrUnknownFormat
+	}
+
+	type entry struct {
+		line   int
+		fields map[string]string
+	}
+	var (
+		statement Statement
+		entries   []entry
+		current   *entry
+		ledger    bool
+		balance   = make(map[string]string)
+		line      = 1
+		offset    = 0
+	)
+	for _, m := range ofxElement.FindAllStringSubmatchIndex(text, -1) {
+		line += strings.Count(text[offset:m[0]], "\n")
+		offset = m[0]
+
+		closing := m[3] > m[2]
+		tag := strings.ToUpper(text[m[4]:m[5]])
+		value := strings.TrimSpace(html.UnescapeString(text[m[6]:m[7]]))
+		switch {
+		case tag == "STMTTRN" && !closing:
+			entries = append(entries, entry{line: line, fields: make(map[string]string)})
+			current = &entries[len(entries)-1]
+		case tag == "STMTTRN":
+			current = nil
+		case tag == "LEDGERBAL":
+			ledger = !closing
+		case closing || value == "":
+		case current != nil:
+			if _, ok := current.fields[tag]; !ok {
+				current.fields[tag] = value
+			}
+		case ledger:
+			balance[tag] = value
+		case tag == "CURDEF" && statement.Currency == "":
+			statement.Currency = value
+		case tag == "ACCTID" && statement.Account == "":
+			statement.Account = value
+		}
+	}
+	if statement.Currency != "" {
+		if statement.Currency, err = models.NormalizeCurrency(statement.Currency); err != nil {
+			return nil, err
+		}
+	}
+
+	statement.Records = make([]Record, 0, len(entries))
+	for _, e := range entries {
+		r := Record{
+			Line:        e.line,
+			Description: billDescription(e.fields["NAME"], e.fields["MEMO"]),
+			Currency:    statement.Currency,
+		}
+		if fitID := e.fields["FITID"]; fitID != "" {
+			r.ExternalID = fitID
+			if statement.Account != "" {
+				r.ExternalID = statement.Account + ":" + fitID
+			}
+		}
+
+		amount, err := ParseAmount(e.fields["TRNAMT"])
+		switch {
+		case err != nil:
+			r.addError("invalid amount %q", e.fields["TRNAMT"])
+		case amount == 0:
+			r.Skip = "zero amount"
+		case amount < 0:
+			r.Type, r.Amount = "expense", -amount
+		default:
+			r.Type, r.Amount = "income", amount
+		}
+
+		if r.Date, err = parseOFXDate(e.fields["DTPOSTED"], loc); err != nil {
+			r.addError("invalid date %q", e.fields["DTPOSTED"])
+		}
+		statement.Records = append(statement.Records, r)
+	}
+
+	if amount, err := ParseAmount(balance["BALAMT"]); err == nil {
+		date, err := parseOFXDate(balance["DTASOF"], loc)
+		if err != nil {
+			return nil, ErrInvalidDate
+		}
+		statement.Balance = &Balance{Amount: amount, Date: date}
+	}
+	return &statement, nil
+}
+
+// ofxDate matches an OFX date such as 20261015, 20261015120000.000 or 20261015120000[-5:EST]
+var ofxDate = regexp.MustCompile(`^(\d{8})(\d{6})?(?:\.\d+)?(?:\[([+-]?\d+(?:\.\d+)?)(?::[^\]]*)?\])?$`)
+
+// parseOFXDate parses an OFX date. A time without an offset is in UTC as the standard says; a
+// date alone is a calendar day in loc, the user's timezone.
+func parseOFXDate(s string, loc *time.Location) (time.Time, error) {
+	m := ofxDate.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return time.Time{}, ErrInvalidDate
+	}
+	zone := loc
+	if m[3] != "" {
+		hours, err := strconv.ParseFloat(m[3], 64)
+		if err != nil {
+			return time.Time{}, ErrInvalidDate
+		}
+		zone = time.FixedZone("", int(math.Round(hours*3600)))
+	} else if m[2] != "" {
+		zone = time.UTC
+	}
+
+	t, err := time.ParseInLocation("20060102150405", m[1]+m[2]+strings.Repeat("0", 6-len(m[2])), zone)
+	if err != nil {
+		return time.Time{}, ErrInvalidDate
+	}
+	return t, nil
+}
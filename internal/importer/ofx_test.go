@@ -0,0 +1,85 @@
+package importer
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseOFXChecking(t *testing.T) {
+	statement, err := ParseOFX(readTestdata(t, "checking.ofx"), testLoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statement.Account != "1234567890" || statement.Currency != "USD" {
+		t.Errorf("got account %q in %q, want 1234567890 in USD", statement.Account, statement.Currency)
+	}
+	checkRecords(t, statement.Records, []wantRecord{
+		// Noon at five hours behind UTC
+		{line: 39, date: time.Date(2024, 1, 5, 17, 0, 0, 0, time.UTC), description: "TRADER JOE'S #552 - POS PURCHASE", kind: "expense", amount: 4217, externalID: "1234567890:202401050001"},
+		// A date alone is a day in the user's timezone
+		{line: 47, date: time.Date(2024, 1, 15, 0, 0, 0, 0, testLoc), description: "ACME CORP PAYROLL", kind: "income", amount: 250000, externalID: "1234567890:202401150002"},
+		// A fractional offset, and a memo repeating the name isn't added twice
+		{line: 54, date: time.Date(2024, 1, 20, 4, 0, 0, 0, time.UTC), description: "Rent & Utilities", kind: "expense", amount: 120000, externalID: "1234567890:202401200003"},
+		// A time without an offset is in UTC
+		{line: 62, date: time.Date(2024, 1, 31, 23, 59, 59, 0, time.UTC), description: "ACCOUNT VERIFICATION", externalID: "1234567890:202401310004", skip: "zero amount"},
+	})
+	for _, r := range statement.Records {
+		if r.Currency != "USD" {
+			t.Errorf("line %d: Currency = %q, want USD", r.Line, r.Currency)
+		}
+	}
+	// The ledger balance is used, not the available balance
+	checkBalance(t, "closing", statement.Balance, 325783, time.Date(2024, 1, 31, 22, 0, 0, 0, time.UTC))
+	if statement.Opening != nil {
+		t.Errorf("Opening = %+v, want none", statement.Opening)
+	}
+}
+
+func TestParseOFXCreditCard(t *testing.T) {
+	statement, err := ParseOFX(readTestdata(t, "creditcard.ofx"), testLoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statement.Account != "XXXXXXXXXXXX4321" || statement.Currency != "EUR" {
+		t.Errorf("got account %q in %q, want XXXXXXXXXXXX4321 in EUR", statement.Account, statement.Currency)
+	}
+	checkRecords(t, statement.Records, []wantRecord{
+		{line: 21, date: time.Date(2024, 2, 10, 13, 30, 0, 0, time.UTC), description: "Deutsche Bahn - ICE Berlin - Hamburg", kind: "expense", amount: 8990, externalID: "XXXXXXXXXXXX4321:CC-0001"},
+		{line: 29, date: time.Date(2024, 2, 28, 0, 0, 0, 0, time.UTC), description: "PAYMENT - THANK YOU", kind: "income", amount: 50000, externalID: "XXXXXXXXXXXX4321:CC-0002"},
+	})
+	// Money owed on the card is a negative balance
+	checkBalance(t, "closing", statement.Balance, -123456, time.Date(2024, 2, 29, 22, 59, 59, 0, time.UTC))
+}
+
+func TestParseOFXDate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"20240105", time.Date(2024, 1, 5, 0, 0, 0, 0, testLoc)},
+		{"20240105120000", time.Date(2024, 1, 5, 12, 0, 0, 0, time.UTC)},
+		{"20240105120000.000", time.Date(2024, 1, 5, 12, 0, 0, 0, time.UTC)},
+		{"20240105120000.000[-5:EST]", time.Date(2024, 1, 5, 17, 0, 0, 0, time.UTC)},
+		{"20240105120000[+5.75]", time.Date(2024, 1, 5, 6, 15, 0, 0, time.UTC)},
+		{"20240105120000[-3.5:NST]", time.Date(2024, 1, 5, 15, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseOFXDate(tt.in, testLoc)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseOFXDate(%q) = %s, %v, want %s", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "2024-01-05", "20241305", "20240105120000[EST]"} {
+		if _, err := parseOFXDate(in, testLoc); !errors.Is(err, ErrInvalidDate) {
+			t.Errorf("parseOFXDate(%q) error = %v, want %v", in, err, ErrInvalidDate)
+		}
+	}
+}
+
+func TestParseOFXWrongFormat(t *testing.T) {
+	if _, err := ParseOFX(readTestdata(t, "checking.qif"), testLoc); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("ParseOFX of a QIF file error = %v, want %v", err, ErrUnknownFormat)
+	}
+}
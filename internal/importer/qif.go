@@ -0,0 +1,129 @@
+package importer
+
+import (
+	"bufio"
+	"strings"
+	"time"
+)
+
+// qifDateLayouts are the date formats of QIF files, which are usually American
+var qifDateLayouts = []string{"1/2/2006", "1/2/06", "2006-1-2", "2.1.2006", "2.1.06"}
+
+// ParseQIF reads a QIF file exported by a bank or finance program. QIF entries have no ID, so each
+// is identified by a hash of its content and how often the same content occurred before in the
+// file. Dates are parsed with layout, a Go time layout, or the common formats when it is empty.
+func ParseQIF(data []byte, layout string, loc *time.Location) (*Statement, error) {
+	text, _, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(strings.TrimSpace(text), "!") {
+		return nil, ErrUnknownFormat
+	}
+
+	var (
+		statement   Statement
+		section     string
+		fields      map[string]string
+		start       int
+		occurrences = make(map[string]int)
+	)
+	finish := func() {
+		defer func() { fields = nil }()
+		if len(fields) == 0 || !strings.HasPrefix(section, "!type:") {
+			return
+		}
+		statement.Records = append(statement.Records, qifRecord(fields, start, section, layout, loc, occurrences))
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for line := 1; scanner.Scan(); line++ {
+		s := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		if strings.HasPrefix(s, "!") {
+			finish()
+			// Options such as !Option:AutoSwitch don't start a section of their own
+			if !strings.HasPrefix(strings.ToLower(s), "!option") && !strings.HasPrefix(strings.ToLower(s), "!clear") {
+				section = strings.ToLower(strings.TrimSpace(s))
+			}
+			continue
+		}
+		if s[0] == '^' {
+			finish()
+			continue
+		}
+		if fields == nil {
+			fields = make(map[string]string)
+			start = line
+		}
+		// Split lines (S, E, $) repeat; only the first of each code is kept
+		code, value := s[:1], strings.TrimSpace(s[1:])
+		if _, ok := fields[code]; !ok {
+			fields[code] = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	finish()
+	return &statement, nil
+}
+
+// qifRecord builds a record from the fields of a QIF entry, keyed by their one-letter codes
+func qifRecord(fields map[string]string, line int, section, layout string, loc *time.Location, occurrences map[string]int) Record {
+	r := Record{
+		Line:        line,
+		Description: billDescription(fields["P"], fields["M"]),
+		Category:    fields["L"],
+	}
+
+	r.ExternalID = contentID(occurrences, fields["D"], fields["T"], fields["P"], fields["M"], fields["N"])
+
+	switch {
+	case section == "!type:invst":
+		r.Skip = "investment entry"
+	case strings.HasPrefix(r.Category, "["):
+		r.Skip = "transfer between accounts"
+	}
+
+	value := fields["T"]
+	if value == "" {
+		value = fields["U"]
+	}
+	amount, err := ParseAmount(value)
+	switch {
+	case err != nil:
+		if r.Skip == "" {
+			r.addError("invalid amount %q", value)
+		}
+	case amount == 0 && r.Skip == "":
+		r.Skip = "zero amount"
+	case amount < 0:
+		r.Type, r.Amount = "expense", -amount
+	default:
+		r.Type, r.Amount = "income", amount
+	}
+
+	date, err := parseQIFDate(fields["D"], layout, loc)
+	if err != nil && r.Skip == "" {
+		r.addError("invalid date %q", fields["D"])
+	}
+	r.Date = date
+	return r
+}
+
+// parseQIFDate parses a QIF date such as 10/15/2026, 10/15'26 or 2026-10-15
+func parseQIFDate(s, layout string, loc *time.Location) (time.Time, error) {
+	if layout != "" {
+		return ParseDate(s, layout, loc)
+	}
+	s = strings.ReplaceAll(strings.ReplaceAll(s, "'", "/"), " ", "")
+	for _, layout := range qifDateLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrInvalidDate
+}
@@ -0,0 +1,56 @@
+package importer
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseQIF(t *testing.T) {
+	statement, err := ParseQIF(readTestdata(t, "checking.qif"), "", testLoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Entries are identified by their content, in the order they appear
+	occurrences := make(map[string]int)
+	id := func(date, amount, payee, memo string) string {
+		return contentID(occurrences, date, amount, payee, memo, "")
+	}
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, testLoc) }
+	checkRecords(t, statement.Records, []wantRecord{
+		{line: 8, date: day(2), description: "Whole Foods Market - Weekly groceries", kind: "expense", amount: 4567, category: "Food:Groceries", externalID: id("01/02'24", "-45.67", "Whole Foods Market", "Weekly groceries")},
+		{line: 14, date: day(5), description: "ACME Corp", kind: "income", amount: 185000, category: "Salary", externalID: id("1/5/2024", "1,850.00", "ACME Corp", "")},
+		{line: 19, date: day(9), description: "Transfer to savings", kind: "expense", amount: 30000, category: "[Savings]", externalID: id("01/09'24", "-300.00", "Transfer to savings", ""), skip: "transfer between accounts"},
+		// Two identical entries on the same day are both kept, numbered apart
+		{line: 24, date: day(12), description: "Costco", kind: "expense", amount: 12000, externalID: id("01/12'24", "-120.00", "Costco", "")},
+		{line: 32, date: day(12), description: "Costco", kind: "expense", amount: 12000, externalID: id("01/12'24", "-120.00", "Costco", "")},
+		{line: 40, date: day(20), description: "Bank - Account check", externalID: id("01/20'24", "0.00", "Bank", "Account check"), skip: "zero amount"},
+		{line: 46, date: day(25), kind: "expense", amount: 50000, externalID: contentID(occurrences, "01/25'24", "-500.00", "", "", "Buy"), skip: "investment entry"},
+	})
+	if a, b := statement.Records[3].ExternalID, statement.Records[4].ExternalID; a == b {
+		t.Errorf("identical entries share the ID %q", a)
+	}
+}
+
+func TestParseQIFDateLayout(t *testing.T) {
+	data := []byte("!Type:CCard\nD15.01.2024\nT-9.99\nPStreaming\n^\n")
+
+	statement, err := ParseQIF(data, "02.01.2006", testLoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statement.Records) != 1 {
+		t.Fatalf("got %d records, want 1", len(statement.Records))
+	}
+	r := statement.Records[0]
+	if want := time.Date(2024, 1, 15, 0, 0, 0, 0, testLoc); !r.Date.Equal(want) || len(r.Errors) > 0 {
+		t.Errorf("got %s with errors %q, want %s", r.Date, r.Errors, want)
+	}
+}
+
+func TestParseQIFWrongFormat(t *testing.T) {
+	if _, err := ParseQIF(readTestdata(t, "checking.ofx"), "", testLoc); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("ParseQIF of an OFX file error = %v, want %v", err, ErrUnknownFormat)
+	}
+}
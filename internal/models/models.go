@@ -4,13 +4,15 @@ import "time"
 
 // User represents a user account
 type User struct {
-	ID        int64     `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	Avatar    string    `json:"avatar"` // 头像URL或base64数据
-	Password  string    `json:"-"`      // 密码不会在 JSON 中返回
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID           int64     `json:"id"`
+	Username     string    `json:"username"`
+	Email        string    `json:"email"`
+	Avatar       string    `json:"avatar"`       // 头像URL或base64数据
+	Password     string    `json:"-"`            // 密码不会在 JSON 中返回
+	BaseCurrency string    `json:"baseCurrency"` // 统计报表使用的本位币
+	Timezone     string    `json:"timezone"`     // IANA 时区，决定日期归属和统计周期的起止
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
 }
 
 // LoginRequest represents login request data
@@ -44,6 +46,16 @@ type UpdateEmailRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// UpdateBaseCurrencyRequest represents base currency update request data
+type UpdateBaseCurrencyRequest struct {
+	BaseCurrency string `json:"baseCurrency" binding:"required,len=3"`
+}
+
+// UpdateTimezoneRequest represents timezone update request data
+type UpdateTimezoneRequest struct {
+	Timezone string `json:"timezone" binding:"required"`
+}
+
 // AuthResponse represents authentication response
 type AuthResponse struct {
 	Token string       `json:"token"`
@@ -52,10 +64,12 @@ type AuthResponse struct {
 
 // UserResponse represents user data returned in API responses
 type UserResponse struct {
-	ID       int64  `json:"id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Avatar   string `json:"avatar"`
+	ID           int64  `json:"id"`
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	Avatar       string `json:"avatar"`
+	BaseCurrency string `json:"baseCurrency"`
+	Timezone     string `json:"timezone"`
 }
 
 // Transaction represents a financial transaction
@@ -63,10 +77,140 @@ type Transaction struct {
 	ID          int64     `json:"id"`
 	UserID      int64     `json:"userId"`
 	Description string    `json:"description"`
-	Amount      float64   `json:"amount"`
-	Type        string    `json:"type"`
+	Amount      Money     `json:"amount"`
+	Currency    string    `json:"currency"` // ISO 4217 code, e.g. "CNY"
+	Type        string    `json:"type"`     // "income", "expense" or "transfer"
 	CategoryKey string    `json:"categoryKey"`
 	Date        time.Time `json:"date"`
+	AssetID     *int64    `json:"assetId"`   // 关联的资产账户，可为空；转账时为转出账户
+	ToAssetID   *int64    `json:"toAssetId"` // 转账的转入账户，仅用于转账
+	// OriginalTransactionID links a refund or reimbursement (an income) to the expense it pays back;
+	// statistics net it against that expense instead of counting it as income
+	OriginalTransactionID *int64 `json:"originalTransactionId"`
+	Reimbursable          bool   `json:"reimbursable"` // 支出是否等待报销
+	PayeeID               *int64 `json:"payeeId"`      // 商户/交易对方，可为空
+	// ExternalSource and ExternalID identify a transaction imported from a bill or statement,
+	// so that importing the same file again doesn't add it twice
+	ExternalSource string `json:"externalSource,omitempty"` // 导入来源，如 alipay、wechat
+	ExternalID     string `json:"externalId,omitempty"`     // 来源中的交易号
+	// Splits attribute parts of the amount to other categories; when present they add up to Amount
+	Splits []TransactionSplit `json:"splits,omitempty"`
+	Tags   []Tag              `json:"tags,omitempty"`
+}
+
+// TransactionPage represents one page of a paginated transaction list
+type TransactionPage struct {
+	Items      []Transaction `json:"items"`
+	NextCursor string        `json:"nextCursor"` // 为空表示没有下一页
+	Total      int           `json:"total"`      // 符合筛选条件的总条数
+	Aggregates Summary       `json:"aggregates"` // 符合筛选条件的收支合计（本位币）
+}
+
+// DuplicateGroup represents transactions that look like the same entry recorded more than once
+type DuplicateGroup struct {
+	Transactions []Transaction `json:"transactions"` // 最新的在前
+}
+
+// MergeTransactionsRequest represents request to merge duplicates into a transaction
+type MergeTransactionsRequest struct {
+	DuplicateIDs []int64 `json:"duplicateIds" binding:"required,min=1"`
+}
+
+// ImportMapping describes how the columns of a CSV file map to transaction fields.
+// Columns are named by their header or by their 1-based column number.
+type ImportMapping struct {
+	Date        string `json:"date"`
+	Description string `json:"description"`
+	// Amount holds the amounts. When some of them are negative, negative amounts are expenses
+	// and the others income; otherwise all are of DefaultType. A Type column takes precedence.
+	Amount      string            `json:"amount"`
+	Income      string            `json:"income"`  // 收入金额列，与 expense 一起代替 amount
+	Expense     string            `json:"expense"` // 支出金额列
+	Type        string            `json:"type"`
+	Category    string            `json:"category"`
+	Currency    string            `json:"currency"`
+	DateFormat  string            `json:"dateFormat"`  // Go 时间格式，为空时自动识别
+	DefaultType string            `json:"defaultType"` // income 或 expense，默认 expense
+	Categories  map[string]string `json:"categories"`  // 文件中的分类名 → 分类 key，"" 对应空分类
+}
+
+// ImportRow represents one row of an import file and the transaction it becomes
+type ImportRow struct {
+	Line        int          `json:"line"` // 文件中的行号
+	Transaction *Transaction `json:"transaction,omitempty"`
+	Errors      []string     `json:"errors,omitempty"`
+	Skipped     string       `json:"skipped,omitempty"` // 跳过的原因，如未完成的交易或已导入过
+}
+
+// ImportResult represents the preview or the outcome of importing a file
+type ImportResult struct {
+	Format    string         `json:"format"`
+	Encoding  string         `json:"encoding,omitempty"`
+	Delimiter string         `json:"delimiter,omitempty"`
+	Headers   []string       `json:"headers,omitempty"`
+	Mapping   *ImportMapping `json:"mapping,omitempty"`
+	Rows      []ImportRow    `json:"rows"`
+	Valid     int            `json:"valid"`
+	Invalid   int            `json:"invalid"`
+	Skipped   int            `json:"skipped"`
+	// 对账单的期初/期末余额，保存时写入绑定资产的记录
+	OpeningBalance *AssetRecord `json:"openingBalance,omitempty"`
+	Balance        *AssetRecord `json:"balance,omitempty"`
+	Committed      bool         `json:"committed"` // 为 false 时只是预览，没有保存
+}
+
+// TransactionSplit represents a part of a transaction attributed to its own category
+type TransactionSplit struct {
+	ID            int64  `json:"id"`
+	TransactionID int64  `json:"transactionId"`
+	CategoryKey   string `json:"categoryKey"`
+	Amount        Money  `json:"amount"`
+	Note          string `json:"note"`
+}
+
+// UpdateTransactionRequest represents request to update a transaction.
+// Nil fields are left unchanged, so the same struct serves PUT and PATCH.
+type UpdateTransactionRequest struct {
+	Description *string  `json:"description"`
+	Amount      *Money   `json:"amount"`
+	Currency    *string  `json:"currency"`
+	Type        *string  `json:"type"`
+	CategoryKey *string  `json:"categoryKey"`
+	Date        *string  `json:"date"`      // YYYY-MM-DD or ISO 8601
+	AssetID     *int64   `json:"assetId"`   // 0 unlinks the asset account
+	ToAssetID   *int64   `json:"toAssetId"` // Destination asset of a transfer
+	TagIDs      *[]int64 `json:"tagIds"`    // Replaces all tags; an empty list removes them
+	// OriginalTransactionID links the transaction to the expense it refunds; 0 removes the link
+	OriginalTransactionID *int64 `json:"originalTransactionId"`
+	Reimbursable          *bool  `json:"reimbursable"`
+	PayeeID               *int64 `json:"payeeId"` // 0 removes the payee
+	// Splits replaces all split lines when present; an empty list removes them
+	Splits *[]TransactionSplit `json:"splits"`
+}
+
+// OutstandingReimbursement represents a reimbursable expense that hasn't been fully paid back
+type OutstandingReimbursement struct {
+	Transaction Transaction `json:"transaction"`
+	Reimbursed  Money       `json:"reimbursed"`  // 已关联的退款/报销金额
+	Outstanding Money       `json:"outstanding"` // 尚未报销的金额，与交易同币种
+}
+
+// OutstandingReimbursements represents all outstanding reimbursable expenses of a user
+type OutstandingReimbursements struct {
+	Items    []OutstandingReimbursement `json:"items"`
+	Total    Money                      `json:"total"` // 按交易日汇率换算为本位币的待报销总额
+	Currency string                     `json:"currency"`
+}
+
+// TransactionRevision represents a recorded change to a transaction
+type TransactionRevision struct {
+	ID            int64       `json:"id"`
+	TransactionID int64       `json:"transactionId"`
+	UserID        int64       `json:"userId"`        // 修改人
+	ChangedFields []string    `json:"changedFields"` // 被修改的字段
+	OldValue      Transaction `json:"oldValue"`
+	NewValue      Transaction `json:"newValue"`
+	CreatedAt     time.Time   `json:"createdAt"`
 }
 
 // Category represents a transaction category
@@ -86,18 +230,153 @@ type Statistics struct {
 
 // Summary represents financial summary data
 type Summary struct {
-	TotalIncome  float64 `json:"totalIncome"`
-	TotalExpense float64 `json:"totalExpense"`
-	Balance      float64 `json:"balance"`
+	TotalIncome  Money  `json:"totalIncome"`
+	TotalExpense Money  `json:"totalExpense"`
+	Balance      Money  `json:"balance"`
+	Currency     string `json:"currency"` // 本位币，所有金额已按交易日汇率换算
+	// 缺少汇率、未计入合计的金额，按类型和币种汇总
+	Unconverted []UnconvertedAmount `json:"unconverted"`
+}
+
+// UnconvertedAmount is the total of a type in a currency that couldn't be converted to the base
+// currency because an exchange rate is missing
+type UnconvertedAmount struct {
+	Type     string `json:"type"` // "income" or "expense"
+	Currency string `json:"currency"`
+	Amount   Money  `json:"amount"`
 }
 
 // CategoryStat represents statistics for a specific category
 type CategoryStat struct {
 	CategoryKey string  `json:"categoryKey"`
-	Amount      float64 `json:"amount"`
+	Amount      Money   `json:"amount"`
 	Percentage  float64 `json:"percentage"`
 }
 
+// Attachment represents a file such as a receipt attached to a transaction
+type Attachment struct {
+	ID            int64     `json:"id"`
+	TransactionID int64     `json:"transactionId"`
+	UserID        int64     `json:"userId"`
+	FileName      string    `json:"fileName"`
+	ContentType   string    `json:"contentType"`
+	Size          int64     `json:"size"`
+	StorageKey    string    `json:"-"` // 文件在存储中的位置，不对外暴露
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// TrashItem represents a deleted transaction, asset, asset record or auto transaction
+// that can still be restored
+type TrashItem struct {
+	Kind        string     `json:"kind"` // "transactions", "assets", "asset-records" or "auto-transactions"
+	ID          int64      `json:"id"`
+	Description string     `json:"description"` // 交易/自动记账的描述，资产及其记录的资产名称
+	Amount      *Money     `json:"amount,omitempty"`
+	Currency    string     `json:"currency"`
+	Date        string     `json:"date,omitempty"` // 交易或资产记录的日期 YYYY-MM-DD
+	DeletedAt   time.Time  `json:"deletedAt"`
+	PurgeAt     *time.Time `json:"purgeAt,omitempty"` // 到期后自动彻底删除，未开启自动清理时为空
+}
+
+// Payee represents a merchant or other counterparty of transactions.
+// Aliases map the raw descriptions of transactions to the payee.
+type Payee struct {
+	ID        int64        `json:"id"`
+	UserID    int64        `json:"userId"`
+	Name      string       `json:"name"`
+	Aliases   []PayeeAlias `json:"aliases"`
+	CreatedAt time.Time    `json:"createdAt"`
+	UpdatedAt time.Time    `json:"updatedAt"`
+}
+
+// PayeeAlias represents a rule matching transaction descriptions to a payee.
+// Matching ignores case, full-width characters and repeated spaces.
+type PayeeAlias struct {
+	ID        int64  `json:"id"`
+	Pattern   string `json:"pattern" binding:"required,max=100"`
+	MatchType string `json:"matchType" binding:"omitempty,oneof=exact prefix contains"` // 默认 contains
+}
+
+// PayeeRequest represents request to create or update a payee; the aliases replace the existing ones
+type PayeeRequest struct {
+	Name    string       `json:"name" binding:"required,min=1,max=100"`
+	Aliases []PayeeAlias `json:"aliases" binding:"dive"`
+}
+
+// PayeeStat represents statistics for a specific payee
+type PayeeStat struct {
+	PayeeID    int64   `json:"payeeId"`
+	Name       string  `json:"name"`
+	Amount     Money   `json:"amount"`
+	Count      int     `json:"count"` // 交易笔数
+	Percentage float64 `json:"percentage"`
+}
+
+// PayeeStatistics represents payee statistics data, mirroring Statistics
+type PayeeStatistics struct {
+	Summary          Summary     `json:"summary"`
+	ExpenseBreakdown []PayeeStat `json:"expenseBreakdown"`
+	IncomeBreakdown  []PayeeStat `json:"incomeBreakdown"`
+}
+
+// TransactionTemplate represents a frequently logged transaction that can be entered in one tap.
+// Templates used most often come first.
+type TransactionTemplate struct {
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"userId"`
+	Description string     `json:"description"`
+	Amount      Money      `json:"amount"`
+	Currency    string     `json:"currency"`
+	Type        string     `json:"type"` // "income" or "expense"
+	CategoryKey string     `json:"categoryKey"`
+	AssetID     *int64     `json:"assetId"`
+	UsageCount  int        `json:"usageCount"` // 使用次数
+	LastUsedAt  *time.Time `json:"lastUsedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+// TransactionTemplateRequest represents request to create or update a transaction template
+type TransactionTemplateRequest struct {
+	Description string `json:"description" binding:"required,max=200"`
+	Amount      Money  `json:"amount" binding:"required,gt=0"`
+	Currency    string `json:"currency"` // 默认使用资产币种或本位币
+	Type        string `json:"type" binding:"required,oneof=income expense"`
+	CategoryKey string `json:"categoryKey" binding:"required"`
+	AssetID     *int64 `json:"assetId"`
+}
+
+// Tag represents a user-defined label that can be attached to any transaction
+type Tag struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"userId"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// TagRequest represents request to create or update a tag
+type TagRequest struct {
+	Name  string `json:"name" binding:"required,min=1,max=50"`
+	Color string `json:"color" binding:"max=20"`
+}
+
+// TagStat represents statistics for a specific tag
+type TagStat struct {
+	TagID      int64   `json:"tagId"`
+	Name       string  `json:"name"`
+	Amount     Money   `json:"amount"`
+	Percentage float64 `json:"percentage"` // 占同类型总额的比例，一笔交易可带多个标签，合计可能超过 100
+}
+
+// TagStatistics represents tag statistics data, mirroring Statistics
+type TagStatistics struct {
+	Summary          Summary   `json:"summary"`
+	ExpenseBreakdown []TagStat `json:"expenseBreakdown"`
+	IncomeBreakdown  []TagStat `json:"incomeBreakdown"`
+}
+
 // Asset represents an asset account
 type Asset struct {
 	ID         int64     `json:"id"`
@@ -105,6 +384,7 @@ type Asset struct {
 	Name       string    `json:"name"`
 	Category   string    `json:"category"`   // 保持向后兼容，现在是分类名称
 	CategoryID *int64    `json:"categoryId"` // 新的分类ID关联
+	Currency   string    `json:"currency"`
 	CreatedAt  time.Time `json:"createdAt"`
 	UpdatedAt  time.Time `json:"updatedAt"`
 }
@@ -114,7 +394,7 @@ type AssetRecord struct {
 	ID        int64     `json:"id"`
 	AssetID   int64     `json:"assetId"`
 	Date      string    `json:"date"` // YYYY-MM-DD format
-	Amount    float64   `json:"amount"`
+	Amount    Money     `json:"amount"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
@@ -126,7 +406,9 @@ type AssetWithRecords struct {
 	Name       string        `json:"name"`
 	Category   string        `json:"category"`   // 分类名称
 	CategoryID *int64        `json:"categoryId"` // 分类ID
+	Currency   string        `json:"currency"`
 	Records    []AssetRecord `json:"records"`
+	Balance    Money         `json:"balance"` // 最新记录加上之后关联交易推算出的当前余额
 	CreatedAt  time.Time     `json:"createdAt"`
 	UpdatedAt  time.Time     `json:"updatedAt"`
 }
@@ -135,12 +417,36 @@ type AssetWithRecords struct {
 type CreateAssetRequest struct {
 	Name       string `json:"name" binding:"required,min=1,max=100"`
 	CategoryID int64  `json:"categoryId" binding:"required,min=1"`
+	Currency   string `json:"currency"` // 默认使用本位币
 }
 
 // CreateAssetRecordRequest represents request to create a new asset record
 type CreateAssetRecordRequest struct {
-	Date   string  `json:"date" binding:"required"`
-	Amount float64 `json:"amount" binding:"required,min=0"`
+	Date   string `json:"date" binding:"required"`
+	Amount Money  `json:"amount" binding:"required,min=0"`
+}
+
+// AssetLedgerEntry represents a transaction moving an asset account and the balance after it
+type AssetLedgerEntry struct {
+	TransactionID int64     `json:"transactionId"`
+	Date          time.Time `json:"date"`
+	Description   string    `json:"description"`
+	Type          string    `json:"type"`
+	CategoryKey   string    `json:"categoryKey"`
+	Change        Money     `json:"change"` // 对余额的影响，负数表示减少
+	// TransferAssetID is the other account of a transfer
+	TransferAssetID *int64 `json:"transferAssetId,omitempty"`
+	Balance         Money  `json:"balance"` // 该笔交易之后的余额
+}
+
+// AssetLedger represents the movements of an asset account since its latest record.
+// Balances are derived from the anchor record plus the transactions linked to the asset.
+type AssetLedger struct {
+	Asset          Asset              `json:"asset"`
+	Anchor         *AssetRecord       `json:"anchor"` // 计算起点，为空表示从 0 开始
+	OpeningBalance Money              `json:"openingBalance"`
+	Entries        []AssetLedgerEntry `json:"entries"`
+	Balance        Money              `json:"balance"`
 }
 
 // AssetCategory represents an asset category
@@ -171,9 +477,11 @@ type AutoTransaction struct {
 	ID                int64      `json:"id"`
 	UserID            int64      `json:"userId"`
 	Type              string     `json:"type"` // "income" or "expense"
-	Amount            float64    `json:"amount"`
+	Amount            Money      `json:"amount"`
+	Currency          string     `json:"currency"`
 	CategoryKey       string     `json:"categoryKey"`
 	Description       string     `json:"description"`
+	AssetID           *int64     `json:"assetId"`
 	Frequency         string     `json:"frequency"`  // "daily", "weekly", "monthly", "yearly"
 	DayOfMonth        int        `json:"dayOfMonth"` // For monthly/yearly frequency (1-31)
 	DayOfWeek         int        `json:"dayOfWeek"`  // For weekly frequency (0=Sunday, 1=Monday, etc.)
@@ -186,23 +494,110 @@ type AutoTransaction struct {
 
 // CreateAutoTransactionRequest represents request to create an auto transaction
 type CreateAutoTransactionRequest struct {
-	Type        string  `json:"type" binding:"required,oneof=income expense"`
-	Amount      float64 `json:"amount" binding:"required,gt=0"`
-	CategoryKey string  `json:"categoryKey" binding:"required"`
-	Description string  `json:"description"`
-	Frequency   string  `json:"frequency" binding:"required,oneof=daily weekly monthly yearly"`
-	DayOfMonth  int     `json:"dayOfMonth,omitempty"` // For monthly/yearly
-	DayOfWeek   int     `json:"dayOfWeek,omitempty"`  // For weekly
+	Type        string `json:"type" binding:"required,oneof=income expense"`
+	Amount      Money  `json:"amount" binding:"required,gt=0"`
+	Currency    string `json:"currency"` // 默认使用本位币
+	CategoryKey string `json:"categoryKey" binding:"required"`
+	Description string `json:"description"`
+	AssetID     *int64 `json:"assetId"`
+	Frequency   string `json:"frequency" binding:"required,oneof=daily weekly monthly yearly"`
+	DayOfMonth  int    `json:"dayOfMonth,omitempty"` // For monthly/yearly
+	DayOfWeek   int    `json:"dayOfWeek,omitempty"`  // For weekly
 }
 
 // UpdateAutoTransactionRequest represents request to update an auto transaction
 type UpdateAutoTransactionRequest struct {
-	Type        string  `json:"type" binding:"required,oneof=income expense"`
-	Amount      float64 `json:"amount" binding:"required,gt=0"`
-	CategoryKey string  `json:"categoryKey" binding:"required"`
-	Description string  `json:"description"`
-	Frequency   string  `json:"frequency" binding:"required,oneof=daily weekly monthly yearly"`
-	DayOfMonth  int     `json:"dayOfMonth,omitempty"` // For monthly/yearly
-	DayOfWeek   int     `json:"dayOfWeek,omitempty"`  // For weekly
-	IsActive    bool    `json:"isActive"`
+	Type        string `json:"type" binding:"required,oneof=income expense"`
+	Amount      Money  `json:"amount" binding:"required,gt=0"`
+	Currency    string `json:"currency"` // 默认使用本位币
+	CategoryKey string `json:"categoryKey" binding:"required"`
+	Description string `json:"description"`
+	AssetID     *int64 `json:"assetId"`
+	Frequency   string `json:"frequency" binding:"required,oneof=daily weekly monthly yearly"`
+	DayOfMonth  int    `json:"dayOfMonth,omitempty"` // For monthly/yearly
+	DayOfWeek   int    `json:"dayOfWeek,omitempty"`  // For weekly
+	IsActive    bool   `json:"isActive"`
+}
+
+// ExchangeRate represents the rate to convert one unit of FromCurrency into ToCurrency on a date
+type ExchangeRate struct {
+	ID           int64     `json:"id"`
+	UserID       int64     `json:"userId"` // 0 表示来自汇率文件的共享汇率
+	FromCurrency string    `json:"fromCurrency"`
+	ToCurrency   string    `json:"toCurrency"`
+	Rate         float64   `json:"rate"`
+	Date         string    `json:"date"`   // YYYY-MM-DD format
+	Source       string    `json:"source"` // "manual" or "csv"
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// CreateExchangeRateRequest represents request to create or replace an exchange rate
+type CreateExchangeRateRequest struct {
+	FromCurrency string  `json:"fromCurrency" binding:"required,len=3"`
+	ToCurrency   string  `json:"toCurrency" binding:"required,len=3"`
+	Rate         float64 `json:"rate" binding:"required,gt=0"`
+	Date         string  `json:"date" binding:"required"`
+}
+
+// BackupVersion is the version of the backup format written by this version of the app
+const BackupVersion = 1
+
+// Backup represents everything a user has recorded, as saved in a backup archive.
+// IDs are those of the account the backup was made from and are remapped on restore.
+type Backup struct {
+	Version               int                   `json:"version"`
+	CreatedAt             time.Time             `json:"createdAt"`
+	Profile               BackupProfile         `json:"profile"`
+	TransactionCategories []BackupCategory      `json:"transactionCategories"`
+	AssetCategories       []AssetCategory       `json:"assetCategories"`
+	Assets                []Asset               `json:"assets"`
+	AssetRecords          []AssetRecord         `json:"assetRecords"`
+	Tags                  []Tag                 `json:"tags"`
+	Payees                []Payee               `json:"payees"`
+	Transactions          []Transaction         `json:"transactions"`
+	Attachments           []BackupAttachment    `json:"attachments"`
+	Templates             []TransactionTemplate `json:"templates"`
+	AutoTransactions      []AutoTransaction     `json:"autoTransactions"`
+	ExchangeRates         []ExchangeRate        `json:"exchangeRates"` // 用户手动录入的汇率
+}
+
+// BackupProfile represents the settings of the account a backup was made from; the password is not included
+type BackupProfile struct {
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	Avatar       string `json:"avatar"`
+	BaseCurrency string `json:"baseCurrency"`
+	Timezone     string `json:"timezone"`
+}
+
+// BackupCategory represents an income or expense category in a backup
+type BackupCategory struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+	Icon string `json:"icon"`
+	Type string `json:"type"` // "income" or "expense"
+}
+
+// BackupAttachment represents an attachment in a backup; its content is a file of the archive
+type BackupAttachment struct {
+	ID            int64     `json:"id"`
+	TransactionID int64     `json:"transactionId"`
+	FileName      string    `json:"fileName"`
+	ContentType   string    `json:"contentType"`
+	Size          int64     `json:"size"`
+	File          string    `json:"file"` // 压缩包中的文件路径
+	StorageKey    string    `json:"-"`    // 恢复时写入存储的位置
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// RestoreResult represents the outcome of restoring a backup
+type RestoreResult struct {
+	Created map[string]int `json:"created"` // 按种类统计新建的条数
+	// Merged counts the categories, tags and payees that already existed and were reused
+	Merged map[string]int `json:"merged"`
+	// Skipped counts transactions imported from a bill before, which the account already has
+	Skipped int `json:"skipped"`
+	// RenamedCategories maps "type:key" of categories restored under a new key to that key
+	RenamedCategories map[string]string `json:"renamedCategories,omitempty"`
 }
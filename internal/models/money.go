@@ -0,0 +1,119 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Money represents an amount of money in minor units (分/cents).
+// It is stored in SQLite as an INTEGER so sums are exact, and it is
+// marshalled to JSON as a decimal number like 12.34 so the frontend
+// keeps working with plain numbers.
+type Money int64
+
+// MoneyScale is the number of minor units in one major unit
+const MoneyScale = 100
+
+// DefaultCurrency is the currency used when a user hasn't chosen a base currency
+const DefaultCurrency = "CNY"
+
+// ErrInvalidMoney is returned when a value can't be parsed as an amount of money
+var ErrInvalidMoney = errors.New("invalid amount")
+
+// ParseMoney parses a decimal string such as "12.34", "-5" or "1e3" into Money.
+// Values with more than two fraction digits are rounded half away from zero.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalidMoney
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, ErrInvalidMoney
+	}
+	r.Mul(r, big.NewRat(MoneyScale, 1))
+
+	// Round half away from zero using exact integer arithmetic
+	num := new(big.Int).Set(r.Num())
+	den := r.Denom()
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+
+	if !quo.IsInt64() {
+		return 0, ErrInvalidMoney
+	}
+	return Money(quo.Int64()), nil
+}
+
+// String formats the amount as a decimal string with two fraction digits
+func (m Money) String() string {
+	sign := ""
+	v := int64(m)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/MoneyScale, v%MoneyScale)
+}
+
+// Float64 returns the amount in major units. Only use it for ratios and display,
+// never for arithmetic on amounts.
+func (m Money) Float64() float64 {
+	return float64(m) / MoneyScale
+}
+
+// MarshalJSON encodes the amount as a JSON number in major units
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string in major units
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		data = data[1 : len(data)-1]
+	}
+
+	parsed, err := ParseMoney(string(data))
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidMoney, data)
+	}
+	*m = parsed
+	return nil
+}
+
+// Convert converts the amount with an exchange rate, rounding to the nearest minor unit
+func (m Money) Convert(rate float64) Money {
+	return Money(math.Round(float64(m) * rate))
+}
+
+// ErrInvalidCurrency is returned for malformed currency codes
+var ErrInvalidCurrency = errors.New("currency must be a 3-letter ISO 4217 code")
+
+// NormalizeCurrency validates a currency code and returns it in upper case
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return "", ErrInvalidCurrency
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return "", ErrInvalidCurrency
+		}
+	}
+	return code, nil
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// DefaultTimezone is the timezone used when a user hasn't chosen one
+const DefaultTimezone = "UTC"
+
+// ErrInvalidTimezone is returned for names that aren't IANA timezones
+var ErrInvalidTimezone = errors.New("timezone must be an IANA name such as Asia/Shanghai")
+
+// LoadTimezone validates an IANA timezone name and loads its location.
+// "Local" is rejected because it depends on the server.
+func LoadTimezone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "Local" {
+		return nil, ErrInvalidTimezone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+	return loc, nil
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ValidTransactionType reports whether t is a supported transaction type
+func ValidTransactionType(t string) bool {
+	return t == "income" || t == "expense" || t == "transfer"
+}
+
+// ValidateSplits checks that split lines are complete and add up to the transaction amount
+func ValidateSplits(amount Money, splits []TransactionSplit) error {
+	if len(splits) == 0 {
+		return nil
+	}
+	if len(splits) < 2 {
+		return errors.New("A split transaction needs at least two splits")
+	}
+
+	var total Money
+	for _, split := range splits {
+		if split.CategoryKey == "" {
+			return errors.New("Every split needs a categoryKey")
+		}
+		if split.Amount <= 0 {
+			return errors.New("Split amounts must be greater than 0")
+		}
+		total += split.Amount
+	}
+	if total != amount {
+		return fmt.Errorf("Splits add up to %s but the transaction amount is %s", total, amount)
+	}
+	return nil
+}
+
+// ValidateTransfer checks the accounts of a transfer as far as that can be done without looking
+// them up. A transfer moves money from AssetID to ToAssetID, which must be distinct; other
+// transaction types can't have a destination asset.
+func ValidateTransfer(t *Transaction) error {
+	if t.Type != "transfer" {
+		if t.ToAssetID != nil {
+			return errors.New("toAssetId is only allowed for transfers")
+		}
+		return nil
+	}
+
+	if t.AssetID == nil || t.ToAssetID == nil {
+		return errors.New("transfers require assetId and toAssetId")
+	}
+	if *t.AssetID == *t.ToAssetID {
+		return errors.New("cannot transfer to the same asset")
+	}
+	if len(t.Splits) > 0 {
+		return errors.New("transfers cannot be split")
+	}
+	if t.Amount <= 0 {
+		return errors.New("transfer amount must be positive")
+	}
+	return nil
+}
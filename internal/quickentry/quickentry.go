@@ -0,0 +1,523 @@
+// Package quickentry parses one line of free text, such as "午饭 35 餐饮 昨天" or
+// "salary 12000 income", into the fields of a transaction.
+package quickentry
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"mini-money/internal/models"
+)
+
+var (
+	// ErrEmpty is returned when there is no text to parse
+	ErrEmpty = errors.New("text is required")
+	// ErrNoAmount is returned when the text contains no amount
+	ErrNoAmount = errors.New("no amount found in text")
+	// ErrInvalidDate is returned for a date that doesn't exist, such as 2月30日
+	ErrInvalidDate = errors.New("invalid date in text")
+)
+
+// Category is a transaction category the text can be matched against
+type Category struct {
+	Key  string
+	Name string
+	Type string // "income" or "expense"
+}
+
+// Result holds the fields found in the text
+type Result struct {
+	Description string
+	Amount      models.Money
+	Currency    string // empty when the text names no currency
+	Type        string // "income" or "expense"
+	CategoryKey string // empty when no category matched
+	Date        string // YYYY-MM-DD, empty when the text names no date
+}
+
+// parser holds the text that is left while fields are taken out of it
+type parser struct {
+	text  string
+	today time.Time
+}
+
+// cut blanks out text[start:end] so the rest of the words stay apart
+func (p *parser) cut(start, end int) {
+	p.text = p.text[:start] + " " + p.text[end:]
+}
+
+// Parse parses text into a transaction. today is the current day in the user's timezone and
+// is used for relative dates; categories are the user's categories in their display order.
+// Without type words, a sign or a matched category the transaction is an expense.
+func Parse(text string, today time.Time, categories []Category) (*Result, error) {
+	p := &parser{text: fold(text), today: today}
+	if strings.TrimSpace(p.text) == "" {
+		return nil, ErrEmpty
+	}
+
+	result := &Result{}
+
+	date, err := p.takeDate()
+	if err != nil {
+		return nil, err
+	}
+	if !date.IsZero() {
+		result.Date = date.Format("2006-01-02")
+	}
+
+	amount, sign, err := p.takeAmount(result)
+	if err != nil {
+		return nil, err
+	}
+	result.Amount = amount
+
+	result.Type = p.takeType()
+	if result.Type == "" {
+		switch sign {
+		case "+":
+			result.Type = "income"
+		case "-":
+			result.Type = "expense"
+		}
+	}
+
+	description := cleanDescription(p.text)
+	category, term := matchCategory(description, result.Type, categories)
+	if category == nil {
+		category = hintedCategory(description, result.Type, categories)
+	}
+	if category != nil {
+		result.CategoryKey = category.Key
+		if result.Type == "" {
+			result.Type = category.Type
+		}
+	}
+	if term != "" {
+		// The category word isn't part of the description unless it is all there is
+		if rest := cleanDescription(removeTerm(description, term)); rest != "" {
+			description = rest
+		}
+	}
+	if result.Type == "" {
+		result.Type = "expense"
+	}
+	if result.CategoryKey == "" {
+		result.CategoryKey = fallbackCategory(result.Type, categories)
+	}
+	if description == "" && category != nil {
+		description = category.Name
+	}
+	result.Description = description
+	return result, nil
+}
+
+// fold turns full-width characters into their ASCII forms
+func fold(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '　':
+			return ' '
+		case r >= '！' && r <= '～':
+			return r - 0xFEE0
+		}
+		return r
+	}, s)
+}
+
+var (
+	fullDatePattern    = regexp.MustCompile(`(\d{4})[-/.年](\d{1,2})[-/.月](\d{1,2})[日号]?`)
+	monthDayPattern    = regexp.MustCompile(`(\d{1,2})月(\d{1,2})[日号]?`)
+	slashDatePattern   = regexp.MustCompile(`\b(\d{1,2})/(\d{1,2})\b`)
+	englishDatePattern = regexp.MustCompile(`(?i)\b(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.?\s+(\d{1,2})(?:st|nd|rd|th)?\b`)
+	englishDayFirst    = regexp.MustCompile(`(?i)\b(\d{1,2})(?:st|nd|rd|th)?\s+(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\b`)
+	daysAgoPattern     = regexp.MustCompile(`(?i)(\d+)\s*天前|\b(\d+)\s+days?\s+ago\b`)
+	chineseWeekday     = regexp.MustCompile(`(上上|上|这|本|下)?(?:周|星期|礼拜)([一二三四五六日天1-7])`)
+	englishWeekday     = regexp.MustCompile(`(?i)\b(?:(last|this|next)\s+)?(monday|tuesday|wednesday|thursday|friday|saturday|sunday)\b`)
+	// A day of the current month such as 15号; 3号线 is a metro line and 12日元 an amount, not a date
+	dayOnlyPattern = regexp.MustCompile(`(^|\D)(\d{1,2})[日号]([^线楼元]|$)`)
+)
+
+// relativeDays maps words for nearby days to their offset from today, longest words first
+var relativeDays = []struct {
+	pattern *regexp.Regexp
+	offset  int
+}{
+	{regexp.MustCompile(`大前天`), -3},
+	{regexp.MustCompile(`(?i)\bday before yesterday\b`), -2},
+	{regexp.MustCompile(`前天`), -2},
+	{regexp.MustCompile(`昨天|昨日|(?i)\byesterday\b`), -1},
+	{regexp.MustCompile(`今天|今日|(?i)\btoday\b`), 0},
+	{regexp.MustCompile(`明天|(?i)\btomorrow\b`), 1},
+	{regexp.MustCompile(`后天`), 2},
+}
+
+var englishMonths = map[string]time.Month{
+	"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April,
+	"may": time.May, "jun": time.June, "jul": time.July, "aug": time.August,
+	"sep": time.September, "oct": time.October, "nov": time.November, "dec": time.December,
+}
+
+var chineseWeekdays = map[string]time.Weekday{
+	"一": time.Monday, "二": time.Tuesday, "三": time.Wednesday, "四": time.Thursday,
+	"五": time.Friday, "六": time.Saturday, "日": time.Sunday, "天": time.Sunday,
+	"1": time.Monday, "2": time.Tuesday, "3": time.Wednesday, "4": time.Thursday,
+	"5": time.Friday, "6": time.Saturday, "7": time.Sunday,
+}
+
+var englishWeekdays = map[string]time.Weekday{
+	"monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday, "thursday": time.Thursday,
+	"friday": time.Friday, "saturday": time.Saturday, "sunday": time.Sunday,
+}
+
+// takeDate takes the first date out of the text. It returns the zero time when there is none.
+func (p *parser) takeDate() (time.Time, error) {
+	if m := fullDatePattern.FindStringSubmatchIndex(p.text); m != nil {
+		year, month, day := p.number(m, 1), p.number(m, 2), p.number(m, 3)
+		p.cut(m[0], m[1])
+		return p.date(year, time.Month(month), day)
+	}
+	if m := monthDayPattern.FindStringSubmatchIndex(p.text); m != nil {
+		month, day := p.number(m, 1), p.number(m, 2)
+		p.cut(m[0], m[1])
+		return p.pastDate(time.Month(month), day)
+	}
+	if m := slashDatePattern.FindStringSubmatchIndex(p.text); m != nil {
+		month, day := p.number(m, 1), p.number(m, 2)
+		p.cut(m[0], m[1])
+		return p.pastDate(time.Month(month), day)
+	}
+	if m := englishDatePattern.FindStringSubmatchIndex(p.text); m != nil {
+		month := englishMonths[strings.ToLower(p.text[m[2]:m[3]])]
+		day := p.number(m, 2)
+		p.cut(m[0], m[1])
+		return p.pastDate(month, day)
+	}
+	if m := englishDayFirst.FindStringSubmatchIndex(p.text); m != nil {
+		day := p.number(m, 1)
+		month := englishMonths[strings.ToLower(p.text[m[4]:m[5]])]
+		p.cut(m[0], m[1])
+		return p.pastDate(month, day)
+	}
+	for _, r := range relativeDays {
+		if m := r.pattern.FindStringIndex(p.text); m != nil {
+			p.cut(m[0], m[1])
+			return p.today.AddDate(0, 0, r.offset), nil
+		}
+	}
+	if m := daysAgoPattern.FindStringSubmatchIndex(p.text); m != nil {
+		days := p.number(m, 1) + p.number(m, 2)
+		p.cut(m[0], m[1])
+		return p.today.AddDate(0, 0, -days), nil
+	}
+	if m := chineseWeekday.FindStringSubmatchIndex(p.text); m != nil {
+		qualifier := ""
+		if m[2] >= 0 {
+			qualifier = p.text[m[2]:m[3]]
+		}
+		weekday := chineseWeekdays[p.text[m[4]:m[5]]]
+		p.cut(m[0], m[1])
+		switch qualifier {
+		case "上上":
+			return p.weekdayInWeek(weekday, -2), nil
+		case "上":
+			return p.weekdayInWeek(weekday, -1), nil
+		case "这", "本":
+			return p.weekdayInWeek(weekday, 0), nil
+		case "下":
+			return p.weekdayInWeek(weekday, 1), nil
+		}
+		return p.lastWeekday(weekday, true), nil
+	}
+	if m := englishWeekday.FindStringSubmatchIndex(p.text); m != nil {
+		qualifier := ""
+		if m[2] >= 0 {
+			qualifier = strings.ToLower(p.text[m[2]:m[3]])
+		}
+		weekday := englishWeekdays[strings.ToLower(p.text[m[4]:m[5]])]
+		p.cut(m[0], m[1])
+		switch qualifier {
+		case "last":
+			return p.lastWeekday(weekday, false), nil
+		case "this":
+			return p.weekdayInWeek(weekday, 0), nil
+		case "next":
+			return p.weekdayInWeek(weekday, 1), nil
+		}
+		return p.lastWeekday(weekday, true), nil
+	}
+	if m := dayOnlyPattern.FindStringSubmatchIndex(p.text); m != nil {
+		day := p.number(m, 2)
+		p.cut(m[4], m[6])
+		date, err := p.date(p.today.Year(), p.today.Month(), day)
+		if err == nil && date.After(p.today) {
+			date, err = p.date(p.today.Year(), p.today.Month()-1, day)
+		}
+		return date, err
+	}
+	return time.Time{}, nil
+}
+
+// number returns submatch i of a match as an integer, 0 when it didn't take part in the match
+func (p *parser) number(m []int, i int) int {
+	if m[2*i] < 0 {
+		return 0
+	}
+	n, _ := strconv.Atoi(p.text[m[2*i]:m[2*i+1]])
+	return n
+}
+
+// date returns the given day in the timezone of today, checking that it exists
+func (p *parser) date(year int, month time.Month, day int) (time.Time, error) {
+	date := time.Date(year, month, day, 0, 0, 0, 0, p.today.Location())
+	// time.Date normalizes overflowing days and months
+	if date.Day() != day || (month >= time.January && month <= time.December && date.Month() != month) {
+		return time.Time{}, ErrInvalidDate
+	}
+	return date, nil
+}
+
+// pastDate returns the most recent occurrence of a month and day; entries are rarely for the future
+func (p *parser) pastDate(month time.Month, day int) (time.Time, error) {
+	date, err := p.date(p.today.Year(), month, day)
+	if err == nil && date.After(p.today) {
+		date, err = p.date(p.today.Year()-1, month, day)
+	}
+	return date, err
+}
+
+// weekdayInWeek returns a day of the week weeks away from the current one; weeks start on Monday
+func (p *parser) weekdayInWeek(weekday time.Weekday, weeks int) time.Time {
+	monday := p.today.AddDate(0, 0, -((int(p.today.Weekday()) + 6) % 7))
+	return monday.AddDate(0, 0, weeks*7+(int(weekday)+6)%7)
+}
+
+// lastWeekday returns the most recent given day of the week, which may be today if includeToday
+func (p *parser) lastWeekday(weekday time.Weekday, includeToday bool) time.Time {
+	days := (int(p.today.Weekday()) - int(weekday) + 7) % 7
+	if days == 0 && !includeToday {
+		days = 7
+	}
+	return p.today.AddDate(0, 0, -days)
+}
+
+// amountPattern matches an amount with an optional sign, currency symbol, multiplier and unit
+var amountPattern = regexp.MustCompile(`(?i)(^|[^\w.])([+-]?)\s*([¥$€£])?\s*(\d+(?:,\d{3})*(?:\.\d+)?)\s*(万|千|k\b)?\s*` +
+	`(块钱|人民币|美元|美金|欧元|英镑|港币|港元|日元|元|块|圆|` +
+	`(?:rmb|yuan|dollars?|bucks?|euros?|pounds?|cny|usd|eur|gbp|jpy|hkd|twd|krw|sgd|aud|cad|chf|mop|thb)\b)?`)
+
+// currencyWords maps currency symbols and units to ISO 4217 codes
+var currencyWords = map[string]string{
+	"¥": "CNY", "元": "CNY", "块": "CNY", "块钱": "CNY", "圆": "CNY", "人民币": "CNY", "rmb": "CNY", "yuan": "CNY",
+	"$": "USD", "美元": "USD", "美金": "USD", "dollar": "USD", "dollars": "USD", "buck": "USD", "bucks": "USD",
+	"€": "EUR", "欧元": "EUR", "euro": "EUR", "euros": "EUR",
+	"£": "GBP", "英镑": "GBP", "pound": "GBP", "pounds": "GBP",
+	"港币": "HKD", "港元": "HKD", "日元": "JPY",
+}
+
+// multipliers maps the amount multipliers to their factors
+var multipliers = map[string]models.Money{"万": 10000, "千": 1000, "k": 1000}
+
+// takeAmount takes the amount out of the text and sets the currency of the result when the
+// text names one. An amount with a currency wins over bare numbers; otherwise the first number is used.
+// It also returns the sign written before the amount.
+func (p *parser) takeAmount(result *Result) (models.Money, string, error) {
+	matches := amountPattern.FindAllStringSubmatchIndex(p.text, -1)
+	if len(matches) == 0 {
+		return 0, "", ErrNoAmount
+	}
+	m := matches[0]
+	for _, candidate := range matches {
+		if candidate[6] >= 0 || candidate[12] >= 0 {
+			m = candidate
+			break
+		}
+	}
+
+	group := func(i int) string {
+		if m[2*i] < 0 {
+			return ""
+		}
+		return p.text[m[2*i]:m[2*i+1]]
+	}
+	amount, err := models.ParseMoney(strings.ReplaceAll(group(4), ",", ""))
+	if err != nil || amount == 0 {
+		return 0, "", ErrNoAmount
+	}
+	if factor, ok := multipliers[strings.ToLower(group(5))]; ok {
+		if amount > math.MaxInt64/factor {
+			return 0, "", ErrNoAmount
+		}
+		amount *= factor
+	}
+
+	currency := group(3)
+	if currency == "" {
+		currency = group(6)
+	}
+	if currency != "" {
+		if code, ok := currencyWords[strings.ToLower(currency)]; ok {
+			result.Currency = code
+		} else {
+			result.Currency = strings.ToUpper(currency)
+		}
+	}
+
+	sign := group(2)
+	// Keep the character before the amount, it isn't part of it
+	p.cut(m[3], m[1])
+	return amount, sign, nil
+}
+
+// typeWords are the words that say whether money came in or went out
+var typeWords = []struct {
+	pattern *regexp.Regexp
+	kind    string
+}{
+	{regexp.MustCompile(`(?i)收入|进账|入账|收到|收款|赚了|\b(?:income|earned|received|got paid)\b`), "income"},
+	{regexp.MustCompile(`(?i)支出|花了|花费|消费|付款|付了|买了|\b(?:expense|spent|spend|paid|pay|bought|buy)\b`), "expense"},
+}
+
+// takeType takes the type words out of the text and returns the type named first
+func (p *parser) takeType() string {
+	kind, first := "", len(p.text)
+	for _, w := range typeWords {
+		if m := w.pattern.FindStringIndex(p.text); m != nil && m[0] < first {
+			kind, first = w.kind, m[0]
+		}
+	}
+	for _, w := range typeWords {
+		p.text = w.pattern.ReplaceAllString(p.text, " ")
+	}
+	return kind
+}
+
+// fillerWords are left out at the start and end of a description
+var fillerWords = map[string]bool{"on": true, "for": true, "at": true, "in": true, "of": true, "the": true, "a": true, "an": true, "to": true}
+
+// cleanDescription trims punctuation and filler words off the words left in the text
+func cleanDescription(s string) string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '\n' || strings.ContainsRune(",，.。;；:：、!！?？", r)
+	})
+	for len(words) > 0 && fillerWords[strings.ToLower(words[0])] {
+		words = words[1:]
+	}
+	for len(words) > 0 && fillerWords[strings.ToLower(words[len(words)-1])] {
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, " ")
+}
+
+// matchCategory finds the category named in the text, preferring the longest match.
+// Only categories of the given type are considered unless it is empty.
+// It returns the matched category and the term that matched it.
+func matchCategory(text, kind string, categories []Category) (*Category, string) {
+	lower := strings.ToLower(text)
+	var best *Category
+	bestTerm := ""
+	for i := range categories {
+		c := &categories[i]
+		if kind != "" && c.Type != kind {
+			continue
+		}
+		for _, term := range categoryTerms(*c) {
+			if utf8.RuneCountInString(term) <= utf8.RuneCountInString(bestTerm) || !containsTerm(lower, term) {
+				continue
+			}
+			best, bestTerm = c, term
+		}
+	}
+	return best, bestTerm
+}
+
+// categoryTerms returns the lower-cased words that name a category: its name and its key
+func categoryTerms(c Category) []string {
+	var terms []string
+	for _, term := range []string{c.Name, c.Key, strings.ReplaceAll(c.Key, "_", " ")} {
+		if term = strings.ToLower(strings.TrimSpace(term)); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// containsTerm reports whether text contains a term; terms with ASCII letters must be whole words
+func containsTerm(text, term string) bool {
+	return termPattern(term).MatchString(text)
+}
+
+// termPattern matches a category term, as a whole word when it has ASCII letters or digits
+func termPattern(term string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(term)
+	if strings.IndexFunc(term, func(r rune) bool { return r < utf8.RuneSelf }) >= 0 {
+		quoted = `\b` + quoted + `\b`
+	}
+	return regexp.MustCompile(`(?i)` + quoted)
+}
+
+// removeTerm removes the first occurrence of a category term from text
+func removeTerm(text, term string) string {
+	m := termPattern(term).FindStringIndex(text)
+	if m == nil {
+		return text
+	}
+	return text[:m[0]] + " " + text[m[1]:]
+}
+
+// categoryHints maps common words to the keys of the default categories, for descriptions
+// that don't name a category themselves
+var categoryHints = []struct {
+	pattern *regexp.Regexp
+	key     string
+}{
+	{regexp.MustCompile(`(?i)早餐|早饭|午餐|午饭|晚餐|晚饭|夜宵|外卖|咖啡|奶茶|\b(?:breakfast|lunch|dinner|coffee|meal|snacks?)\b`), "food"},
+	{regexp.MustCompile(`(?i)打车|地铁|公交|出租车|滴滴|加油|停车|高铁|火车|\b(?:taxi|uber|subway|metro|bus|train|parking|gas)\b`), "transport"},
+	{regexp.MustCompile(`(?i)超市|淘宝|京东|\b(?:groceries|grocery|supermarket)\b`), "shopping"},
+	{regexp.MustCompile(`(?i)房租|水电|物业|\b(?:rent|utilities)\b`), "housing"},
+	{regexp.MustCompile(`(?i)话费|流量|\b(?:phone bill)\b`), "communication"},
+	{regexp.MustCompile(`(?i)电影|游戏|\b(?:movie|cinema|game)\b`), "entertainment"},
+	{regexp.MustCompile(`(?i)\b(?:paycheck|wage|wages)\b`), "salary"},
+}
+
+// hintedCategory finds a category from the words of the description using categoryHints.
+// Only categories of the given type are considered unless it is empty.
+func hintedCategory(text, kind string, categories []Category) *Category {
+	for _, hint := range categoryHints {
+		if !hint.pattern.MatchString(text) {
+			continue
+		}
+		for i := range categories {
+			if categories[i].Key == hint.key && (kind == "" || categories[i].Type == kind) {
+				return &categories[i]
+			}
+		}
+	}
+	return nil
+}
+
+// SuggestCategory picks the category of the given type that a description most likely belongs to,
+// by the category it names or by common words. It returns "" when nothing fits.
+func SuggestCategory(text, kind string, categories []Category) string {
+	if c, _ := matchCategory(text, kind, categories); c != nil {
+		return c.Key
+	}
+	if c := hintedCategory(text, kind, categories); c != nil {
+		return c.Key
+	}
+	return ""
+}
+
+// fallbackCategory picks the catch-all category of a type, such as other_income, if the user has one
+func fallbackCategory(kind string, categories []Category) string {
+	for _, c := range categories {
+		if c.Type == kind && strings.HasPrefix(c.Key, "other") {
+			return c.Key
+		}
+	}
+	return ""
+}
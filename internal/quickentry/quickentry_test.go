@@ -0,0 +1,88 @@
+package quickentry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"mini-money/internal/models"
+)
+
+// testCategories are some of the default categories
+var testCategories = []Category{
+	{Key: "food", Name: "餐饮", Type: "expense"},
+	{Key: "shopping", Name: "购物", Type: "expense"},
+	{Key: "transport", Name: "交通", Type: "expense"},
+	{Key: "other_expense", Name: "其他", Type: "expense"},
+	{Key: "salary", Name: "工资", Type: "income"},
+	{Key: "bonus", Name: "奖金", Type: "income"},
+	{Key: "other_income", Name: "其他", Type: "income"},
+}
+
+func TestParse(t *testing.T) {
+	// A Wednesday
+	today := time.Date(2024, time.June, 12, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		text string
+		want Result
+	}{
+		{"午饭 35 餐饮 昨天", Result{Description: "午饭", Amount: 3500, Type: "expense", CategoryKey: "food", Date: "2024-06-11"}},
+		{"salary 12000 income", Result{Description: "salary", Amount: 1200000, Type: "income", CategoryKey: "salary"}},
+		{"上周五 打车 28.5", Result{Description: "打车", Amount: 2850, Type: "expense", CategoryKey: "transport", Date: "2024-06-07"}},
+		{"地铁3号线 4元", Result{Description: "地铁3号线", Amount: 400, Currency: "CNY", Type: "expense", CategoryKey: "transport"}},
+		{"奖金 1.5万", Result{Description: "奖金", Amount: 1500000, Type: "income", CategoryKey: "bonus"}},
+		{"$12 yesterday coffee", Result{Description: "coffee", Amount: 1200, Currency: "USD", Type: "expense", CategoryKey: "food", Date: "2024-06-11"}},
+		{"+200 红包", Result{Description: "红包", Amount: 20000, Type: "income", CategoryKey: "other_income"}},
+		{"15号 超市 88", Result{Description: "超市", Amount: 8800, Type: "expense", CategoryKey: "shopping", Date: "2024-05-15"}},
+		{"拉面 1200日元", Result{Description: "拉面", Amount: 120000, Currency: "JPY", Type: "expense", CategoryKey: "other_expense"}},
+		{"寿司 12日元", Result{Description: "寿司", Amount: 1200, Currency: "JPY", Type: "expense", CategoryKey: "other_expense"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := Parse(tt.text, today, testCategories)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.text, err)
+			}
+			if *got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.text, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	today := time.Date(2024, time.June, 12, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		text string
+		want error
+	}{
+		{"  ", ErrEmpty},
+		{"午饭", ErrNoAmount},
+		{"午饭 0", ErrNoAmount},
+		// The multiplier would overflow the amount
+		{"922337203685477万 午饭", ErrNoAmount},
+		{"2月30日 午饭 20", ErrInvalidDate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			_, err := Parse(tt.text, today, testCategories)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Parse(%q) error = %v, want %v", tt.text, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLargestAmount(t *testing.T) {
+	today := time.Date(2024, time.June, 12, 0, 0, 0, 0, time.UTC)
+
+	got, err := Parse("9223372036854万", today, testCategories)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if want := models.Money(922337203685400 * 10000); got.Amount != want {
+		t.Errorf("Amount = %d, want %d", got.Amount, want)
+	}
+}
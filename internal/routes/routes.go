@@ -19,6 +19,7 @@ func SetupRoutes() *gin.Engine {
 	{
 		auth.POST("/register", handlers.Register)
 		auth.POST("/login", handlers.Login)
+		auth.POST("/restore", handlers.RestoreAccount)
 	}
 
 	// Protected API routes
@@ -29,20 +30,54 @@ func SetupRoutes() *gin.Engine {
 		api.PUT("/user/avatar", handlers.UpdateUserAvatar)
 		api.PUT("/user/password", handlers.UpdateUserPassword)
 		api.PUT("/user/email", handlers.UpdateUserEmail)
+		api.PUT("/user/base-currency", handlers.UpdateUserBaseCurrency)
+		api.PUT("/user/timezone", handlers.UpdateUserTimezone)
 		api.GET("/transactions", handlers.GetTransactions)
 		api.POST("/transactions", handlers.AddTransaction)
+		api.POST("/transactions/batch", handlers.BatchTransactions)
+		api.POST("/transactions/parse", handlers.ParseTransaction)
+		api.GET("/transactions/duplicates", handlers.GetDuplicateTransactions)
+		api.PUT("/transactions/:id", handlers.UpdateTransaction)
+		api.PATCH("/transactions/:id", handlers.UpdateTransaction)
 		api.DELETE("/transactions/:id", handlers.DeleteTransaction)
+		api.GET("/transactions/:id/history", handlers.GetTransactionHistory)
+		api.POST("/transactions/:id/merge", handlers.MergeTransactions)
+		api.GET("/transactions/:id/attachments", handlers.GetAttachments)
+		api.POST("/transactions/:id/attachments", handlers.UploadAttachment)
+		api.GET("/transactions/:id/attachments/:attachmentId", handlers.DownloadAttachment)
+		api.DELETE("/transactions/:id/attachments/:attachmentId", handlers.DeleteAttachment)
 		api.GET("/summary", handlers.GetSummary)
 		api.GET("/statistics", handlers.GetStatistics)
+		api.GET("/statistics/tags", handlers.GetTagStatistics)
+		api.GET("/statistics/payees", handlers.GetPayeeStatistics)
+		api.GET("/reimbursements/outstanding", handlers.GetOutstandingReimbursements)
 		// Transaction category routes
 		api.GET("/categories", handlers.GetCategories)
 		api.POST("/categories", handlers.CreateTransactionCategory)
 		api.PUT("/categories/:key", handlers.UpdateTransactionCategory)
 		api.DELETE("/categories/:key", handlers.DeleteTransactionCategory)
+		// Tag routes
+		api.GET("/tags", handlers.GetTags)
+		api.POST("/tags", handlers.CreateTag)
+		api.PUT("/tags/:id", handlers.UpdateTag)
+		api.DELETE("/tags/:id", handlers.DeleteTag)
+		// Payee routes
+		api.GET("/payees", handlers.GetPayees)
+		api.POST("/payees", handlers.CreatePayee)
+		api.POST("/payees/apply", handlers.ApplyPayeeRules)
+		api.PUT("/payees/:id", handlers.UpdatePayee)
+		api.DELETE("/payees/:id", handlers.DeletePayee)
+		// Transaction template routes
+		api.GET("/templates", handlers.GetTemplates)
+		api.POST("/templates", handlers.CreateTemplate)
+		api.PUT("/templates/:id", handlers.UpdateTemplate)
+		api.DELETE("/templates/:id", handlers.DeleteTemplate)
+		api.POST("/templates/:id/apply", handlers.ApplyTemplate)
 		// Asset routes
 		api.GET("/assets", handlers.GetAssets)
 		api.POST("/assets", handlers.CreateAsset)
 		api.DELETE("/assets/:id", handlers.DeleteAsset)
+		api.GET("/assets/:id/ledger", handlers.GetAssetLedger)
 		api.POST("/assets/:id/records", handlers.CreateAssetRecord)
 		api.PUT("/assets/:id/records/:recordId", handlers.UpdateAssetRecord)
 		api.DELETE("/assets/:id/records/:recordId", handlers.DeleteAssetRecord)
@@ -52,12 +87,35 @@ func SetupRoutes() *gin.Engine {
 		api.POST("/asset-categories/initialize-defaults", handlers.InitializeDefaultCategories)
 		api.PUT("/asset-categories/:id", handlers.UpdateAssetCategory)
 		api.DELETE("/asset-categories/:id", handlers.DeleteAssetCategory)
+		// Exchange rate routes
+		api.GET("/exchange-rates", handlers.GetExchangeRates)
+		api.POST("/exchange-rates", handlers.CreateExchangeRate)
+		api.POST("/exchange-rates/import", handlers.ImportExchangeRates)
+		api.DELETE("/exchange-rates/:id", handlers.DeleteExchangeRate)
+		// Import routes
+		api.POST("/import/csv", handlers.ImportCSV)
+		api.POST("/import/alipay", handlers.ImportAlipay)
+		api.POST("/import/wechat", handlers.ImportWechat)
+		api.POST("/import/ofx", handlers.ImportOFX)
+		api.POST("/import/qif", handlers.ImportQIF)
+		api.POST("/import/mt940", handlers.ImportMT940)
+		api.POST("/import/camt053", handlers.ImportCamt053)
+		// Export routes
+		api.GET("/export/transactions", handlers.ExportTransactions)
+		// Backup routes
+		api.GET("/backup", handlers.ExportBackup)
+		api.POST("/backup/restore", handlers.RestoreBackup)
 		// Auto transaction routes
 		api.GET("/auto-transactions", handlers.GetAutoTransactions)
 		api.POST("/auto-transactions", handlers.CreateAutoTransaction)
 		api.PUT("/auto-transactions/:id", handlers.UpdateAutoTransaction)
 		api.DELETE("/auto-transactions/:id", handlers.DeleteAutoTransaction)
 		api.PUT("/auto-transactions/:id/toggle", handlers.ToggleAutoTransaction)
+		// Trash routes
+		api.GET("/trash", handlers.GetTrash)
+		api.DELETE("/trash", handlers.EmptyTrash)
+		api.POST("/trash/:kind/:id/restore", handlers.RestoreTrashItem)
+		api.DELETE("/trash/:kind/:id", handlers.PurgeTrashItem)
 	}
 
 	// Serve frontend for all other routes
@@ -57,14 +57,25 @@ func (s *AutoBillingScheduler) processAutoTransactions() {
 		return
 	}
 
+	locations := make(map[int64]*time.Location)
 	for _, autoTx := range dueTransactions {
+		loc, ok := locations[autoTx.UserID]
+		if !ok {
+			loc, err = database.GetUserLocation(autoTx.UserID)
+			if err != nil {
+				log.Printf("Error getting timezone of user %d: %v", autoTx.UserID, err)
+				continue
+			}
+			locations[autoTx.UserID] = loc
+		}
+
 		if err := s.executeAutoTransaction(autoTx); err != nil {
 			log.Printf("Error executing auto transaction %d: %v", autoTx.ID, err)
 			continue
 		}
 
 		// Update next execution date
-		nextDate := s.calculateNextExecutionDate(autoTx)
+		nextDate := s.calculateNextExecutionDate(autoTx, loc)
 		if err := database.UpdateAutoTransactionExecution(autoTx.ID, nextDate); err != nil {
 			log.Printf("Error updating auto transaction %d execution date: %v", autoTx.ID, err)
 		}
@@ -83,27 +94,51 @@ func (s *AutoBillingScheduler) executeAutoTransaction(autoTx models.AutoTransact
 		UserID:      autoTx.UserID,
 		Description: autoTx.Description,
 		Amount:      autoTx.Amount,
+		Currency:    autoTx.Currency,
 		Type:        autoTx.Type,
 		CategoryKey: autoTx.CategoryKey,
-		Date:        time.Now(),
+		Date:        time.Now().UTC(),
+		AssetID:     autoTx.AssetID,
+	}
+
+	payeeID, err := database.MatchPayee(autoTx.UserID, autoTx.Description)
+	if err != nil {
+		return err
 	}
+	transaction.PayeeID = payeeID
 
-	err := database.InsertTransaction(&transaction)
+	err = database.InsertTransaction(&transaction)
 	return err
 }
 
-// calculateNextExecutionDate calculates the next execution date based on frequency
-func (s *AutoBillingScheduler) calculateNextExecutionDate(autoTx models.AutoTransaction) time.Time {
+// calculateNextExecutionDate calculates the next execution date based on frequency.
+// The schedule follows the calendar of loc, the user's timezone; the result is in UTC.
+func (s *AutoBillingScheduler) calculateNextExecutionDate(autoTx models.AutoTransaction, loc *time.Location) time.Time {
+	current := autoTx.NextExecutionDate.In(loc)
+
 	switch autoTx.Frequency {
 	case "daily":
-		return autoTx.NextExecutionDate.AddDate(0, 0, 1)
+		return current.AddDate(0, 0, 1).UTC()
 	case "weekly":
-		return autoTx.NextExecutionDate.AddDate(0, 0, 7)
+		return current.AddDate(0, 0, 7).UTC()
 	case "monthly":
-		return autoTx.NextExecutionDate.AddDate(0, 1, 0)
+		return addMonths(current, 1, autoTx.DayOfMonth).UTC()
 	case "yearly":
-		return autoTx.NextExecutionDate.AddDate(1, 0, 0)
+		return addMonths(current, 12, autoTx.DayOfMonth).UTC()
 	default:
-		return autoTx.NextExecutionDate.AddDate(0, 0, 1) // Default to daily
+		return current.AddDate(0, 0, 1).UTC() // Default to daily
+	}
+}
+
+// addMonths moves t forward by a number of months in its own timezone and keeps it on
+// dayOfMonth (t's day when 0), clamped to the length of the target month
+func addMonths(t time.Time, months, dayOfMonth int) time.Time {
+	if dayOfMonth <= 0 {
+		dayOfMonth = t.Day()
+	}
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), 0, t.Location())
+	if lastDay := first.AddDate(0, 1, -1).Day(); dayOfMonth > lastDay {
+		dayOfMonth = lastDay
 	}
+	return first.AddDate(0, 0, dayOfMonth-1)
 }
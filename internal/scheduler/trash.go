@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"log"
+	"time"
+
+	"mini-money/internal/database"
+	"mini-money/internal/storage"
+)
+
+// TrashPurgeScheduler permanently deletes trashed items once their retention period has passed
+type TrashPurgeScheduler struct {
+	retention time.Duration
+	store     storage.BlobStore
+	stopCh    chan struct{}
+}
+
+// NewTrashPurgeScheduler creates a purge scheduler; attachment contents are removed from store
+func NewTrashPurgeScheduler(retentionDays int, store storage.BlobStore) *TrashPurgeScheduler {
+	return &TrashPurgeScheduler{
+		retention: time.Duration(retentionDays) * 24 * time.Hour,
+		store:     store,
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Start begins the purge scheduler
+func (s *TrashPurgeScheduler) Start() {
+	log.Println("Starting trash purge scheduler...")
+
+	// Check every hour for expired trash
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	// Run immediately on startup
+	s.purgeExpired()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.purgeExpired()
+		case <-s.stopCh:
+			log.Println("Trash purge scheduler stopped")
+			return
+		}
+	}
+}
+
+// Stop stops the purge scheduler
+func (s *TrashPurgeScheduler) Stop() {
+	close(s.stopCh)
+}
+
+// purgeExpired purges everything that was deleted longer ago than the retention period
+func (s *TrashPurgeScheduler) purgeExpired() {
+	purged, attachments, err := database.PurgeTrash(0, time.Now().Add(-s.retention))
+	if err != nil {
+		log.Printf("Error purging trash: %v", err)
+		return
+	}
+
+	for _, a := range attachments {
+		if err := s.store.Delete(a.StorageKey); err != nil {
+			log.Printf("Error deleting attachment blob %s: %v", a.StorageKey, err)
+		}
+	}
+
+	if purged > 0 {
+		log.Printf("Purged %d expired trash items", purged)
+	}
+}
@@ -2,11 +2,16 @@ package main
 
 import (
 	"log"
+	"time"
+	_ "time/tzdata" // timezone data for user timezones on hosts without a zoneinfo database
 
 	"mini-money/internal/config"
 	"mini-money/internal/database"
+	"mini-money/internal/exchange"
+	"mini-money/internal/handlers"
 	"mini-money/internal/routes"
 	"mini-money/internal/scheduler"
+	"mini-money/internal/storage"
 )
 
 func main() {
@@ -19,11 +24,31 @@ func main() {
 	}
 	defer database.Close()
 
+	// Store transaction attachments on the local filesystem
+	attachmentStore, err := storage.NewLocalStore(cfg.Storage.Path)
+	if err != nil {
+		log.Fatal("Failed to initialize attachment storage:", err)
+	}
+	handlers.SetAttachmentStore(attachmentStore, cfg.Storage)
+	handlers.SetTrashConfig(cfg.Trash)
+
 	// Start auto billing scheduler
 	autoBillingScheduler := scheduler.NewAutoBillingScheduler()
 	go autoBillingScheduler.Start()
 	defer autoBillingScheduler.Stop()
 
+	// Purge deleted items once their retention period has passed
+	if cfg.Trash.RetentionDays > 0 {
+		trashPurgeScheduler := scheduler.NewTrashPurgeScheduler(cfg.Trash.RetentionDays, attachmentStore)
+		go trashPurgeScheduler.Start()
+		defer trashPurgeScheduler.Stop()
+	}
+
+	// Import the shared exchange rate feed and keep it up to date
+	stopExchangeFeed := make(chan struct{})
+	go exchange.WatchFeed(cfg.ExchangeRates.FeedPath, time.Hour, stopExchangeFeed)
+	defer close(stopExchangeFeed)
+
 	// Setup routes
 	router := routes.SetupRoutes()
 